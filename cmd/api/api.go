@@ -1,19 +1,232 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/astro"
+	"github.com/cgiraldoz/geo-ip-info/internal/histogram"
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
 	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/cgiraldoz/geo-ip-info/internal/timezone"
 	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
 )
 
 type IPDetails struct {
-	CountryName           string                       `json:"country_name"`
-	Cca2                  string                       `json:"cca2"`
-	Currencies            map[string]services.Currency `json:"currencies"`
-	RelativeRates         map[string]float64           `json:"relative_rates"`
-	CurrentTimeByTimezone map[string]string            `json:"current_time_by_timezone"`
-	DistanceToBuenosAires float64                      `json:"distance_to_buenos_aires"`
+	CountryName           string                        `json:"country_name"`
+	Cca2                  string                        `json:"cca2"`
+	Currencies            map[string]services.Currency  `json:"currencies"`
+	BaseCurrency          string                        `json:"base_currency"`
+	RelativeRates         map[string]float64            `json:"relative_rates"`
+	RatesMatrix           map[string]map[string]float64 `json:"rates_matrix"`
+	CurrentTimeByTimezone map[string]string             `json:"current_time_by_timezone"`
+	CurrentTimeByZone     map[string]string             `json:"current_time_by_zone"`
+	TimeZone              string                        `json:"timezone"`
+	Timezones             []TimezoneResponse            `json:"timezones"`
+	Coordinates           *CoordinatesResponse          `json:"coordinates,omitempty"`
+	Sun                   *SunResponse                  `json:"sun,omitempty"`
+	Distances             map[string]DistanceResponse   `json:"distances"`
+	DistanceToBuenosAires float64                       `json:"distance_to_buenos_aires"`
+	RatesInfo             RatesInfoResponse             `json:"rates_info"`
+	Conversions           []ConversionResponse          `json:"conversions,omitempty"`
+}
+
+type TimezoneResponse struct {
+	Name         string `json:"name"`
+	LegacyKey    string `json:"legacy_key"`
+	UTCOffset    string `json:"utc_offset"`
+	Abbreviation string `json:"abbreviation"`
+	IsDST        bool   `json:"is_dst"`
+	CurrentTime  string `json:"current_time"`
+
+	Weekday             string   `json:"weekday"`
+	IsBusinessHours     bool     `json:"is_business_hours"`
+	HomeTimeZone        string   `json:"home_timezone,omitempty"`
+	OffsetFromHome      string   `json:"offset_from_home,omitempty"`
+	OffsetFromHomeHours *float64 `json:"offset_from_home_hours,omitempty"`
+}
+
+type CoordinatesResponse struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	Source           string  `json:"source"`
+	AccuracyRadiusKm float64 `json:"accuracy_radius_km"`
+}
+
+type DistanceResponse struct {
+	Name       string  `json:"name"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	DistanceKm float64 `json:"distance_km"`
+	MinKm      float64 `json:"min_km"`
+	MaxKm      float64 `json:"max_km"`
+}
+
+type SunResponse struct {
+	Date       string `json:"date"`
+	Sunrise    string `json:"sunrise,omitempty"`
+	Sunset     string `json:"sunset,omitempty"`
+	SolarNoon  string `json:"solar_noon"`
+	CivilDawn  string `json:"civil_dawn,omitempty"`
+	CivilDusk  string `json:"civil_dusk,omitempty"`
+	PolarDay   bool   `json:"polar_day"`
+	PolarNight bool   `json:"polar_night"`
+	IsDaylight bool   `json:"is_daylight"`
+	Phase      string `json:"phase"`
+}
+
+type RatesInfoResponse struct {
+	Provider   string `json:"provider"`
+	Date       string `json:"date"`
+	Timestamp  string `json:"timestamp"`
+	AgeSeconds int64  `json:"age_seconds"`
+}
+
+type ConversionResponse struct {
+	From          string            `json:"from"`
+	To            string            `json:"to"`
+	Amount        string            `json:"amount"`
+	Result        string            `json:"result"`
+	Rate          float64           `json:"rate"`
+	RateTimestamp string            `json:"rate_timestamp"`
+	RatesInfo     RatesInfoResponse `json:"rates_info"`
+}
+
+type PricingResponse struct {
+	CountryName  string               `json:"country_name"`
+	Cca2         string               `json:"cca2"`
+	Locale       string               `json:"locale"`
+	BaseCurrency string               `json:"base_currency"`
+	Rounding     string               `json:"rounding"`
+	Prices       []PriceQuoteResponse `json:"prices"`
+	RatesInfo    RatesInfoResponse    `json:"rates_info"`
+}
+
+type PriceQuoteResponse struct {
+	BasePrice string `json:"base_price"`
+	Currency  string `json:"currency"`
+	Converted string `json:"converted"`
+	Price     string `json:"price"`
+	Formatted string `json:"formatted"`
+}
+
+type TimePlanResponse struct {
+	WorkStart    string                `json:"work_start"`
+	WorkEnd      string                `json:"work_end"`
+	Days         int                   `json:"days"`
+	Participants []ParticipantResponse `json:"participants"`
+	Windows      []WindowResponse      `json:"windows"`
+}
+
+type ParticipantResponse struct {
+	IP          string `json:"ip"`
+	CountryName string `json:"country_name"`
+	City        string `json:"city"`
+	TimeZone    string `json:"timezone"`
+	CurrentTime string `json:"current_time"`
+}
+
+type WindowResponse struct {
+	Start           string            `json:"start"`
+	End             string            `json:"end"`
+	DurationMinutes int               `json:"duration_minutes"`
+	LocalTimes      map[string]string `json:"local_times"`
+}
+
+type GeodesicDistanceResponse struct {
+	From           DistanceEndpointResponse `json:"from"`
+	To             DistanceEndpointResponse `json:"to"`
+	Unit           string                   `json:"unit"`
+	Method         string                   `json:"method"`
+	Distance       float64                  `json:"distance"`
+	MinDistance    float64                  `json:"min_distance"`
+	MaxDistance    float64                  `json:"max_distance"`
+	InitialBearing float64                  `json:"initial_bearing"`
+	InitialCompass string                   `json:"initial_compass"`
+	FinalBearing   float64                  `json:"final_bearing"`
+	FinalCompass   string                   `json:"final_compass"`
+	Midpoint       PointResponse            `json:"midpoint"`
+}
+
+type DistanceEndpointResponse struct {
+	Input       string              `json:"input"`
+	CountryCode string              `json:"country_code,omitempty"`
+	CountryName string              `json:"country_name,omitempty"`
+	City        string              `json:"city,omitempty"`
+	Coordinates CoordinatesResponse `json:"coordinates"`
+}
+
+type PointResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type NearestResponse struct {
+	Origin DistanceEndpointResponse `json:"origin"`
+	Places []NearestPlaceResponse   `json:"places"`
+}
+
+type NearestPlaceResponse struct {
+	Rank        int     `json:"rank"`
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	CountryCode string  `json:"country_code,omitempty"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	DistanceKm  float64 `json:"distance_km"`
+	Bearing     float64 `json:"bearing"`
+	Compass     string  `json:"compass"`
+}
+
+type PoPSelectionResponse struct {
+	Origin   DistanceEndpointResponse `json:"origin"`
+	Weighted bool                     `json:"weighted"`
+	Selected string                   `json:"selected"`
+	PoPs     []RankedPoPResponse      `json:"pops"`
+}
+
+type RankedPoPResponse struct {
+	Rank       int     `json:"rank"`
+	Name       string  `json:"name"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Weight     float64 `json:"weight"`
+	DistanceKm float64 `json:"distance_km"`
+	Score      float64 `json:"score"`
+	Pinned     bool    `json:"pinned"`
+}
+
+type DiagnosticsResponse struct {
+	Origin          DistanceEndpointResponse `json:"origin"`
+	RefractiveIndex float64                  `json:"fiber_refractive_index"`
+	Latency         []LatencyBoundResponse   `json:"latency"`
+}
+
+type LatencyBoundResponse struct {
+	Reference     string  `json:"reference"`
+	Name          string  `json:"name"`
+	DistanceKm    float64 `json:"distance_km"`
+	MinKm         float64 `json:"min_km"`
+	MaxKm         float64 `json:"max_km"`
+	VacuumRTTMs   float64 `json:"vacuum_rtt_ms"`
+	FiberRTTMs    float64 `json:"fiber_rtt_ms"`
+	MinFiberRTTMs float64 `json:"min_fiber_rtt_ms"`
+	MaxFiberRTTMs float64 `json:"max_fiber_rtt_ms"`
+}
+
+type TravelCheckResponse struct {
+	Events int                    `json:"events"`
+	Alerts int                    `json:"alerts"`
+	Checks []services.TravelCheck `json:"checks"`
 }
 
 type DistanceStatsResponse struct {
@@ -23,34 +236,570 @@ type DistanceStatsResponse struct {
 	ClosestCountry   string                         `json:"closest_country"`
 	TotalDistance    float64                        `json:"total_distance"`
 	TotalRequests    int                            `json:"total_requests"`
+	UniqueVisitors   int                            `json:"unique_visitors"`
 	AverageDistance  float64                        `json:"average_distance"`
 	CountryDistances map[string]CountryDistanceData `json:"country_distances"`
+	Histogram        *HistogramResponse             `json:"histogram,omitempty"`
+	Window           *StatsWindowResponse           `json:"window,omitempty"`
+}
+
+type StatsWindowResponse struct {
+	From        string                `json:"from"`
+	To          string                `json:"to"`
+	Granularity string                `json:"granularity"`
+	Retention   string                `json:"retention"`
+	Total       StatsBucketResponse   `json:"total"`
+	Buckets     []StatsBucketResponse `json:"buckets"`
+}
+
+type StatsBucketResponse struct {
+	Start            string  `json:"start"`
+	TotalRequests    int     `json:"total_requests"`
+	UniqueVisitors   int     `json:"unique_visitors"`
+	TotalDistance    float64 `json:"total_distance"`
+	AverageDistance  float64 `json:"average_distance"`
+	FarthestDistance float64 `json:"farthest_distance"`
+	FarthestCountry  string  `json:"farthest_country,omitempty"`
+	ClosestDistance  float64 `json:"closest_distance"`
+	ClosestCountry   string  `json:"closest_country,omitempty"`
+}
+
+type LeaderboardResponse struct {
+	Dimension   string                     `json:"dimension"`
+	From        string                     `json:"from,omitempty"`
+	To          string                     `json:"to,omitempty"`
+	Granularity string                     `json:"granularity,omitempty"`
+	Entries     []LeaderboardEntryResponse `json:"entries"`
+}
+
+type LeaderboardEntryResponse struct {
+	Rank     int    `json:"rank"`
+	Member   string `json:"member"`
+	Requests int    `json:"requests"`
 }
 
 type CountryDistanceData struct {
-	TotalDistance float64 `json:"total_distance"`
-	Requests      int     `json:"requests"`
+	TotalDistance  float64              `json:"total_distance"`
+	Requests       int                  `json:"requests"`
+	UniqueVisitors int                  `json:"unique_visitors"`
+	Percentiles    *PercentilesResponse `json:"percentiles,omitempty"`
+}
+
+type PercentilesResponse struct {
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+	Partial bool    `json:"partial,omitempty"`
+}
+
+// HistogramResponse is partial when it counts fewer lookups than the totals,
+// which happens for the totals migrated from the legacy distance_stats blob:
+// they have no distribution, so percentiles only cover later lookups.
+type HistogramResponse struct {
+	Count       uint64                    `json:"count"`
+	Partial     bool                      `json:"partial"`
+	Note        string                    `json:"note,omitempty"`
+	Percentiles PercentilesResponse       `json:"percentiles"`
+	Buckets     []HistogramBucketResponse `json:"buckets"`
+}
+
+type HistogramBucketResponse struct {
+	Lower float64 `json:"lower_km"`
+	Upper float64 `json:"upper_km"`
+	Count uint64  `json:"count"`
 }
 
 func StartAPI(redisCache interfaces.Cache, httpClient interfaces.Client) {
-	app := fiber.New()
+	// c.IP() only reads proxy_header from the trusted proxies, so clients
+	// cannot spoof their address in the caller leaderboard.
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             viper.GetString("app.proxy_header"),
+		EnableTrustedProxyCheck: viper.GetString("app.proxy_header") != "",
+		TrustedProxies:          viper.GetStringSlice("app.trusted_proxies"),
+		EnableIPValidation:      true,
+	})
+
+	app.Use("/api", func(c *fiber.Ctx) error {
+		services.RecordCaller(redisCache, c.IP())
+		return c.Next()
+	})
 
 	app.Get("/api/ip/:ip", func(c *fiber.Ctx) error {
-		ipDetails, err := services.GetIPLocationDetails(redisCache, httpClient, c.Params("ip"))
+		timeFormat := c.Query("time_format")
+		if err := services.ValidateTimeFormat(timeFormat); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		homeLocation, err := services.ResolveHomeLocation(httpClient, c.Query("home_tz"), c.IP())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		ipDetails, err := services.GetIPLocationDetails(redisCache, httpClient, c.Params("ip"), services.IPLocationOptions{
+			BaseCurrencies: splitList(c.Query("base")),
+			RatesDate:      c.Query("date"),
+			TimeFormat:     timeFormat,
+			HomeLocation:   homeLocation,
+			References:     splitReferences(c.Query("from")),
+		})
+		if isRatesError(err) {
+			return conversionError(c, err)
+		}
+		if errors.Is(err, services.ErrInvalidReference) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		if err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
-		return c.JSON(IPDetails{
+		response := IPDetails{
 			CountryName:           ipDetails.CountryName,
 			Cca2:                  ipDetails.Cca2,
 			Currencies:            ipDetails.Currencies,
+			BaseCurrency:          ipDetails.BaseCurrency,
 			RelativeRates:         ipDetails.RelativeRates,
+			RatesMatrix:           ipDetails.RatesMatrix,
+			RatesInfo:             toRatesInfoResponse(ipDetails.RatesInfo),
 			CurrentTimeByTimezone: ipDetails.CurrentTimeByTimezone,
+			CurrentTimeByZone:     ipDetails.CurrentTimeByZone,
+			TimeZone:              ipDetails.TimeZone,
 			DistanceToBuenosAires: ipDetails.DistanceToBuenosAires,
+		}
+
+		response.Distances = make(map[string]DistanceResponse, len(ipDetails.Distances))
+		for _, distance := range ipDetails.Distances {
+			response.Distances[distance.Reference.Key] = DistanceResponse{
+				Name:       distance.Reference.Name,
+				Latitude:   distance.Reference.Latitude,
+				Longitude:  distance.Reference.Longitude,
+				DistanceKm: distance.DistanceKm,
+				MinKm:      distance.MinKm,
+				MaxKm:      distance.MaxKm,
+			}
+		}
+
+		if ipDetails.Coordinates.Source != "" {
+			response.Coordinates = &CoordinatesResponse{
+				Latitude:         ipDetails.Coordinates.Latitude,
+				Longitude:        ipDetails.Coordinates.Longitude,
+				Source:           ipDetails.Coordinates.Source,
+				AccuracyRadiusKm: ipDetails.Coordinates.AccuracyRadiusKm,
+			}
+		}
+		if ipDetails.Sun != nil {
+			response.Sun = toSunResponse(*ipDetails.Sun, timeFormat)
+		}
+
+		for _, info := range ipDetails.Timezones {
+			timezoneResponse := TimezoneResponse{
+				Name:            info.Name,
+				LegacyKey:       info.LegacyKey,
+				UTCOffset:       info.UTCOffset,
+				Abbreviation:    info.Abbreviation,
+				IsDST:           info.IsDST,
+				CurrentTime:     services.FormatTime(info.CurrentTime, timeFormat),
+				Weekday:         info.Weekday,
+				IsBusinessHours: info.IsBusinessHours,
+			}
+			if info.HomeTimeZone != "" {
+				offsetHours := float64(info.OffsetFromHomeSeconds) / 3600
+				timezoneResponse.HomeTimeZone = info.HomeTimeZone
+				timezoneResponse.OffsetFromHome = timezone.FormatOffset(info.OffsetFromHomeSeconds)
+				timezoneResponse.OffsetFromHomeHours = &offsetHours
+			}
+			response.Timezones = append(response.Timezones, timezoneResponse)
+		}
+
+		if c.Query("amount") != "" {
+			amount, err := money.NewDecimal(c.Query("amount"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "amount must be a number",
+				})
+			}
+
+			targets := splitList(c.Query("to"))
+			if len(targets) == 0 {
+				for code := range ipDetails.Currencies {
+					targets = append(targets, code)
+				}
+				sort.Strings(targets)
+			}
+
+			conversions, err := services.ConvertToCurrencies(redisCache, c.Query("currency", "USD"), targets, amount, c.Query("date"))
+			if err != nil {
+				return conversionError(c, err)
+			}
+
+			for _, conversion := range conversions {
+				response.Conversions = append(response.Conversions, toConversionResponse(conversion))
+			}
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/convert", func(c *fiber.Ctx) error {
+		from, to := c.Query("from"), c.Query("to")
+		if from == "" || to == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "from and to query parameters are required",
+			})
+		}
+
+		amount, err := money.NewDecimal(c.Query("amount", "1"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "amount must be a number",
+			})
+		}
+
+		conversion, err := services.ConvertCurrency(redisCache, from, to, amount, c.Query("date"))
+		if err != nil {
+			return conversionError(c, err)
+		}
+
+		return c.JSON(toConversionResponse(*conversion))
+	})
+
+	app.Get("/api/pricing", func(c *fiber.Ctx) error {
+		if c.Query("ip") == "" && c.Query("country") == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "ip or country query parameter is required",
+			})
+		}
+
+		var prices []money.Decimal
+		for _, value := range splitList(c.Query("prices")) {
+			price, err := money.NewDecimal(value)
+			if err != nil || price.Sign() < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "prices must be a comma separated list of non-negative numbers",
+				})
+			}
+			prices = append(prices, price)
+		}
+		if len(prices) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "prices query parameter is required",
+			})
+		}
+
+		localized, err := services.GetLocalizedPrices(redisCache, httpClient, services.PricingRequest{
+			IP:           c.Query("ip"),
+			CountryCode:  c.Query("country"),
+			Prices:       prices,
+			BaseCurrency: c.Query("currency"),
+			Rounding:     c.Query("rounding"),
 		})
+		if isRatesError(err) || errors.Is(err, money.ErrUnknownRounding) || errors.Is(err, services.ErrNoLocalCurrency) {
+			return conversionError(c, err)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := PricingResponse{
+			CountryName:  localized.CountryName,
+			Cca2:         localized.Cca2,
+			Locale:       localized.Locale,
+			BaseCurrency: localized.BaseCurrency,
+			Rounding:     localized.Rounding,
+			RatesInfo:    toRatesInfoResponse(localized.RatesInfo),
+		}
+		for _, quote := range localized.Quotes {
+			response.Prices = append(response.Prices, PriceQuoteResponse{
+				BasePrice: quote.BasePrice.String(),
+				Currency:  quote.Currency,
+				Converted: quote.Converted.StringFixed(money.MinorUnits(quote.Currency)),
+				Price:     quote.Price.StringFixed(money.MinorUnits(quote.Currency)),
+				Formatted: quote.Formatted,
+			})
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/timeplanner", func(c *fiber.Ctx) error {
+		timeFormat := c.Query("time_format")
+		if err := services.ValidateTimeFormat(timeFormat); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		plan, err := services.PlanMeeting(httpClient, services.TimePlanRequest{
+			IPs:       splitList(c.Query("ips")),
+			Days:      c.QueryInt("days"),
+			WorkStart: c.Query("start"),
+			WorkEnd:   c.Query("end"),
+		})
+		if errors.Is(err, services.ErrInvalidPlan) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := TimePlanResponse{
+			WorkStart:    plan.WorkStart,
+			WorkEnd:      plan.WorkEnd,
+			Days:         plan.Days,
+			Participants: []ParticipantResponse{},
+			Windows:      []WindowResponse{},
+		}
+		for _, participant := range plan.Participants {
+			response.Participants = append(response.Participants, ParticipantResponse{
+				IP:          participant.IP,
+				CountryName: participant.CountryName,
+				City:        participant.City,
+				TimeZone:    participant.TimeZone,
+				CurrentTime: services.FormatTime(participant.CurrentTime, timeFormat),
+			})
+		}
+		for _, window := range plan.Windows {
+			localTimes := make(map[string]string)
+			for _, participant := range plan.Participants {
+				localTimes[participant.IP] = window.Start.In(participant.Location).Format("Mon 15:04") +
+					" - " + window.End.In(participant.Location).Format("Mon 15:04 MST")
+			}
+			response.Windows = append(response.Windows, WindowResponse{
+				Start:           window.Start.Format(time.RFC3339),
+				End:             window.End.Format(time.RFC3339),
+				DurationMinutes: int(window.End.Sub(window.Start).Minutes()),
+				LocalTimes:      localTimes,
+			})
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/distance", func(c *fiber.Ctx) error {
+		result, err := services.CalculateDistanceBetween(redisCache, httpClient, services.DistanceRequest{
+			From: c.Query("from"),
+			To:   c.Query("to"),
+			Unit: c.Query("unit"),
+		})
+		if errors.Is(err, services.ErrInvalidDistanceRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(GeodesicDistanceResponse{
+			From:           toDistanceEndpointResponse(result.From),
+			To:             toDistanceEndpointResponse(result.To),
+			Unit:           string(result.Unit),
+			Method:         result.Method,
+			Distance:       result.Distance,
+			MinDistance:    result.MinDistance,
+			MaxDistance:    result.MaxDistance,
+			InitialBearing: result.InitialBearing,
+			InitialCompass: result.InitialCompass,
+			FinalBearing:   result.FinalBearing,
+			FinalCompass:   result.FinalCompass,
+			Midpoint:       PointResponse{Latitude: result.Midpoint.Latitude, Longitude: result.Midpoint.Longitude},
+		})
+	})
+
+	app.Get("/api/nearest", func(c *fiber.Ctx) error {
+		result, err := services.FindNearest(redisCache, httpClient, services.NearestRequest{
+			Origin: c.Query("ip"),
+			N:      c.QueryInt("n"),
+			Kinds:  splitList(c.Query("kind")),
+		})
+		if errors.Is(err, services.ErrInvalidNearestRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := NearestResponse{
+			Origin: toDistanceEndpointResponse(result.Origin),
+			Places: []NearestPlaceResponse{},
+		}
+		for i, place := range result.Places {
+			response.Places = append(response.Places, NearestPlaceResponse{
+				Rank:        i + 1,
+				Name:        place.Name,
+				Kind:        place.Kind,
+				CountryCode: place.CountryCode,
+				Latitude:    place.Latitude,
+				Longitude:   place.Longitude,
+				DistanceKm:  place.DistanceKm,
+				Bearing:     place.Bearing,
+				Compass:     place.Compass,
+			})
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/pops", func(c *fiber.Ctx) error {
+		request := services.PoPRequest{
+			Origin: c.Query("ip"),
+			Limit:  c.QueryInt("n"),
+		}
+		if value := c.Query("weighted"); value != "" {
+			weighted, err := strconv.ParseBool(value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "weighted must be true or false",
+				})
+			}
+			request.Weighted = &weighted
+		}
+
+		selection, err := services.RankPoPs(redisCache, httpClient, request)
+		if errors.Is(err, services.ErrInvalidPoPRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrNoPoPs) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := PoPSelectionResponse{
+			Origin:   toDistanceEndpointResponse(selection.Origin),
+			Weighted: selection.Weighted,
+			Selected: selection.PoPs[0].PoP.Name,
+		}
+		for i, ranked := range selection.PoPs {
+			response.PoPs = append(response.PoPs, RankedPoPResponse{
+				Rank:       i + 1,
+				Name:       ranked.PoP.Name,
+				Latitude:   ranked.PoP.Latitude,
+				Longitude:  ranked.PoP.Longitude,
+				Weight:     ranked.PoP.Weight,
+				DistanceKm: ranked.DistanceKm,
+				Score:      ranked.Score,
+				Pinned:     ranked.Pinned,
+			})
+		}
+
+		c.Set("X-GIP-PoP", response.Selected)
+		return c.JSON(response)
+	})
+
+	app.Get("/api/diagnostics/:ip", func(c *fiber.Ctx) error {
+		diagnostics, err := services.GetLatencyDiagnostics(redisCache, httpClient, c.Params("ip"), splitReferences(c.Query("from")))
+		if errors.Is(err, services.ErrInvalidDiagnosticsRequest) || errors.Is(err, services.ErrInvalidReference) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := DiagnosticsResponse{
+			Origin:          toDistanceEndpointResponse(diagnostics.Origin),
+			RefractiveIndex: diagnostics.RefractiveIndex,
+			Latency:         []LatencyBoundResponse{},
+		}
+		for _, bound := range diagnostics.Bounds {
+			response.Latency = append(response.Latency, LatencyBoundResponse{
+				Reference:     bound.Distance.Reference.Key,
+				Name:          bound.Distance.Reference.Name,
+				DistanceKm:    bound.Distance.DistanceKm,
+				MinKm:         bound.Distance.MinKm,
+				MaxKm:         bound.Distance.MaxKm,
+				VacuumRTTMs:   bound.VacuumRTTMs,
+				FiberRTTMs:    bound.FiberRTTMs,
+				MinFiberRTTMs: bound.MinFiberRTTMs,
+				MaxFiberRTTMs: bound.MaxFiberRTTMs,
+			})
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Post("/api/travel/check", requireAdminToken, func(c *fiber.Ctx) error {
+		format := c.Query("format")
+		if format == "" {
+			switch {
+			case strings.Contains(c.Get(fiber.HeaderContentType), "ndjson"):
+				format = services.LoginEventsNDJSON
+			case strings.Contains(c.Get(fiber.HeaderContentType), "csv"):
+				format = services.LoginEventsCSV
+			}
+		}
+
+		events, err := services.ParseLoginEvents(bytes.NewReader(c.Body()), format)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		checks, err := services.CheckLoginEvents(redisCache, httpClient, events)
+		if errors.Is(err, services.ErrInvalidLoginEvent) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		response := TravelCheckResponse{Events: len(events), Checks: checks}
+		for _, check := range checks {
+			if check.Alert {
+				response.Alerts++
+			}
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/travel/alerts", requireAdminToken, func(c *fiber.Ctx) error {
+		alerts, err := services.GetTravelAlerts(context.Background(), redisCache)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if alerts == nil {
+			alerts = []services.TravelCheck{}
+		}
+
+		return c.JSON(alerts)
 	})
 
 	app.Get("/api/stats", func(c *fiber.Ctx) error {
@@ -66,20 +815,157 @@ func StartAPI(redisCache interfaces.Cache, httpClient interfaces.Client) {
 		countryDistances := make(map[string]CountryDistanceData)
 		for country, data := range stats.CountryDistances {
 			countryDistances[country] = CountryDistanceData{
-				TotalDistance: data.TotalDistance,
-				Requests:      data.Requests,
+				TotalDistance:  data.TotalDistance,
+				Requests:       data.Requests,
+				UniqueVisitors: data.UniqueVisitors,
+				Percentiles:    toPercentilesResponse(data.Histogram, data.Requests),
 			}
 		}
 
-		return c.JSON(DistanceStatsResponse{
+		response := DistanceStatsResponse{
 			FarthestDistance: stats.FarthestDistance,
 			FarthestCountry:  stats.FarthestCountryName,
 			ClosestDistance:  stats.ClosestDistance,
 			ClosestCountry:   stats.ClosestCountryName,
 			TotalDistance:    stats.TotalDistance,
 			TotalRequests:    stats.TotalRequests,
+			UniqueVisitors:   stats.UniqueVisitors,
 			AverageDistance:  averageDistance,
 			CountryDistances: countryDistances,
+			Histogram:        toHistogramResponse(stats.Histogram, stats.TotalRequests),
+		}
+
+		if c.Query("from") != "" || c.Query("to") != "" || c.Query("granularity") != "" {
+			now := time.Now()
+			to := now
+			if c.Query("to") != "" {
+				if to, err = services.ParseStatsTime(c.Query("to"), now); err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": err.Error(),
+					})
+				}
+			}
+
+			from := to.Add(-24 * time.Hour)
+			if c.Query("from") != "" {
+				if from, err = services.ParseStatsTime(c.Query("from"), now); err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": err.Error(),
+					})
+				}
+			}
+
+			window, err := services.GetStatsWindow(redisCache, from, to, c.Query("granularity"))
+			if errors.Is(err, services.ErrInvalidStatsWindow) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Error retrieving stats",
+				})
+			}
+			response.Window = toStatsWindowResponse(window)
+		}
+
+		return c.JSON(response)
+	})
+
+	app.Get("/api/stats/top", func(c *fiber.Ctx) error {
+		leaderboard, err := services.GetLeaderboard(redisCache, services.LeaderboardRequest{
+			Dimension: c.Query("dimension", services.LeaderboardCountry),
+			N:         c.QueryInt("n"),
+			Window:    c.Query("window"),
+		})
+		if errors.Is(err, services.ErrInvalidLeaderboard) || errors.Is(err, services.ErrInvalidStatsWindow) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Error retrieving leaderboard",
+			})
+		}
+
+		return c.JSON(toLeaderboardResponse(leaderboard))
+	})
+
+	admin := app.Group("/api/admin", requireAdminToken)
+
+	admin.Get("/stats/export", func(c *fiber.Ctx) error {
+		format := c.Query("format", services.StatsFormatJSON)
+
+		records, err := services.ExportStats(redisCache)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Error exporting stats",
+			})
+		}
+
+		var body bytes.Buffer
+		if err := services.WriteStatsRecords(&body, format, records); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="stats.`+strings.ToLower(format)+`"`)
+		switch strings.ToLower(format) {
+		case services.StatsFormatCSV:
+			c.Set(fiber.HeaderContentType, "text/csv")
+		case services.StatsFormatNDJSON:
+			c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		default:
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		}
+		return c.Send(body.Bytes())
+	})
+
+	admin.Post("/stats/import", func(c *fiber.Ctx) error {
+		records, err := services.ReadStatsRecords(bytes.NewReader(c.Body()), c.Query("format", services.StatsFormatJSON))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		mode := c.Query("mode", services.StatsImportMerge)
+		err = services.ImportStats(redisCache, records, mode)
+		if errors.Is(err, services.ErrInvalidStatsBackup) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Error importing stats",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"imported": len(records),
+			"mode":     mode,
+		})
+	})
+
+	admin.Delete("/stats", func(c *fiber.Ctx) error {
+		if c.Query("confirm") != "true" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Resetting the stats deletes them permanently, repeat with confirm=true",
+			})
+		}
+
+		deleted, err := services.ResetStats(redisCache)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Error resetting stats",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"deleted_keys": deleted,
 		})
 	})
 
@@ -88,3 +974,206 @@ func StartAPI(redisCache interfaces.Cache, httpClient interfaces.Client) {
 		panic(err)
 	}
 }
+
+func toConversionResponse(conversion services.Conversion) ConversionResponse {
+	return ConversionResponse{
+		From:          conversion.From,
+		To:            conversion.To,
+		Amount:        conversion.Amount.String(),
+		Result:        conversion.Result.StringFixed(money.MinorUnits(conversion.To)),
+		Rate:          conversion.Rate,
+		RateTimestamp: conversion.RatesInfo.Timestamp.Format(time.RFC3339),
+		RatesInfo:     toRatesInfoResponse(conversion.RatesInfo),
+	}
+}
+
+func toDistanceEndpointResponse(endpoint services.DistanceEndpoint) DistanceEndpointResponse {
+	return DistanceEndpointResponse{
+		Input:       endpoint.Input,
+		CountryCode: endpoint.CountryCode,
+		CountryName: endpoint.CountryName,
+		City:        endpoint.City,
+		Coordinates: CoordinatesResponse{
+			Latitude:         endpoint.Coordinates.Latitude,
+			Longitude:        endpoint.Coordinates.Longitude,
+			Source:           endpoint.Coordinates.Source,
+			AccuracyRadiusKm: endpoint.Coordinates.AccuracyRadiusKm,
+		},
+	}
+}
+
+func toPercentilesResponse(h *histogram.Histogram, requests int) *PercentilesResponse {
+	if h == nil || h.Count() == 0 {
+		return nil
+	}
+	return &PercentilesResponse{
+		P50:     h.Quantile(0.5),
+		P90:     h.Quantile(0.9),
+		P99:     h.Quantile(0.99),
+		Partial: h.Count() < uint64(requests),
+	}
+}
+
+func toHistogramResponse(h *histogram.Histogram, requests int) *HistogramResponse {
+	percentiles := toPercentilesResponse(h, requests)
+	if percentiles == nil {
+		return nil
+	}
+
+	response := &HistogramResponse{Count: h.Count(), Partial: percentiles.Partial, Percentiles: *percentiles}
+	if response.Partial {
+		response.Note = fmt.Sprintf("percentiles cover %d of %d requests; totals migrated from earlier versions have no distribution", h.Count(), requests)
+	}
+	for _, bucket := range h.Buckets() {
+		response.Buckets = append(response.Buckets, HistogramBucketResponse{
+			Lower: bucket.Lower,
+			Upper: bucket.Upper,
+			Count: bucket.Count,
+		})
+	}
+	return response
+}
+
+func toLeaderboardResponse(leaderboard *services.Leaderboard) LeaderboardResponse {
+	response := LeaderboardResponse{
+		Dimension:   leaderboard.Dimension,
+		Granularity: leaderboard.Granularity,
+		Entries:     []LeaderboardEntryResponse{},
+	}
+	if !leaderboard.From.IsZero() {
+		response.From = leaderboard.From.UTC().Format(time.RFC3339)
+		response.To = leaderboard.To.UTC().Format(time.RFC3339)
+	}
+	for _, entry := range leaderboard.Entries {
+		response.Entries = append(response.Entries, LeaderboardEntryResponse{
+			Rank:     entry.Rank,
+			Member:   entry.Member,
+			Requests: entry.Requests,
+		})
+	}
+	return response
+}
+
+func toStatsWindowResponse(window *services.StatsWindow) *StatsWindowResponse {
+	response := &StatsWindowResponse{
+		From:        window.From.UTC().Format(time.RFC3339),
+		To:          window.To.UTC().Format(time.RFC3339),
+		Granularity: window.Granularity,
+		Retention:   window.Retention.String(),
+		Total:       toStatsBucketResponse(window.Total),
+		Buckets:     []StatsBucketResponse{},
+	}
+	for _, bucket := range window.Buckets {
+		response.Buckets = append(response.Buckets, toStatsBucketResponse(bucket))
+	}
+	return response
+}
+
+func toStatsBucketResponse(bucket services.StatsBucket) StatsBucketResponse {
+	return StatsBucketResponse{
+		Start:            bucket.Start.UTC().Format(time.RFC3339),
+		TotalRequests:    bucket.TotalRequests,
+		UniqueVisitors:   bucket.UniqueVisitors,
+		TotalDistance:    bucket.TotalDistance,
+		AverageDistance:  bucket.AverageDistance(),
+		FarthestDistance: bucket.FarthestDistance,
+		FarthestCountry:  bucket.FarthestCountryName,
+		ClosestDistance:  bucket.ClosestDistance,
+		ClosestCountry:   bucket.ClosestCountryName,
+	}
+}
+
+func toSunResponse(sun astro.SunTimes, timeFormat string) *SunResponse {
+	formatOptional := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return services.FormatTime(*t, timeFormat)
+	}
+
+	return &SunResponse{
+		Date:       sun.Date,
+		Sunrise:    formatOptional(sun.Sunrise),
+		Sunset:     formatOptional(sun.Sunset),
+		SolarNoon:  services.FormatTime(sun.SolarNoon, timeFormat),
+		CivilDawn:  formatOptional(sun.CivilDawn),
+		CivilDusk:  formatOptional(sun.CivilDusk),
+		PolarDay:   sun.PolarDay,
+		PolarNight: sun.PolarNight,
+		IsDaylight: sun.IsDaylight,
+		Phase:      sun.Phase,
+	}
+}
+
+func toRatesInfoResponse(info services.RatesInfo) RatesInfoResponse {
+	return RatesInfoResponse{
+		Provider:   info.Provider,
+		Date:       info.Date,
+		Timestamp:  info.Timestamp.Format(time.RFC3339),
+		AgeSeconds: int64(info.Age.Seconds()),
+	}
+}
+
+func conversionError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrRatesNotFound):
+		status = fiber.StatusNotFound
+	case isRatesError(err), errors.Is(err, money.ErrUnknownRounding), errors.Is(err, services.ErrNoLocalCurrency):
+		status = fiber.StatusBadRequest
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// requireAdminToken only lets through requests carrying the admin.token
+// bearer token. The admin endpoints are disabled while no token is set.
+func requireAdminToken(c *fiber.Ctx) error {
+	token := viper.GetString("admin.token")
+	if token == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Admin API disabled, set admin.token",
+		})
+	}
+
+	expected := []byte("Bearer " + token)
+	if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or missing admin token",
+		})
+	}
+
+	return c.Next()
+}
+
+func isRatesError(err error) bool {
+	return errors.Is(err, services.ErrCurrencyNotFound) ||
+		errors.Is(err, services.ErrInvalidAmount) ||
+		errors.Is(err, services.ErrInvalidDate) ||
+		errors.Is(err, services.ErrRatesNotFound)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitReferences splits on ";" because a reference may itself be a
+// "lat,lng" pair.
+func splitReferences(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
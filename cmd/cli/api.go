@@ -1,9 +1,13 @@
 package cli
 
 import (
+	"context"
+
 	"github.com/cgiraldoz/geo-ip-info/cmd/api"
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func NewApiCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
@@ -13,6 +17,10 @@ func NewApiCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra
 		Long:    "Start the Geo IP Info API server to query IP address geolocation data.",
 		Example: "gip api",
 		Run: func(cmd *cobra.Command, args []string) {
+			if interval := viper.GetDuration("prefetch.refresh_interval"); interval > 0 {
+				refresher := services.NewDefaultPrefetchDataService(redisCache, httpClient)
+				go refresher.RefreshPeriodically(context.Background(), interval)
+			}
 			api.StartAPI(redisCache, httpClient)
 		},
 	}
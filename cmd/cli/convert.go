@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewConvertCmd(redisCache interfaces.Cache) *cobra.Command {
+	var date string
+
+	cmd := &cobra.Command{
+		Use:     "convert [amount] [from] [to]",
+		Short:   "Convert an amount between two currencies",
+		Long:    `Convert an amount between two currencies using the cached exchange rate table.`,
+		Args:    cobra.ExactArgs(3),
+		Example: "gip convert 100 EUR ARS",
+		Run: func(cmd *cobra.Command, args []string) {
+			amount, err := money.NewDecimal(args[0])
+			if err != nil {
+				cmd.PrintErrln("Error: amount must be a number")
+				return
+			}
+
+			conversion, err := services.ConvertCurrency(redisCache, args[1], args[2], amount, date)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			printConversion(cmd, *conversion)
+			printRatesInfo(cmd, conversion.RatesInfo)
+		},
+	}
+
+	cmd.Flags().StringVar(&date, "date", "", "Use the exchange rates stored for this date (YYYY-MM-DD)")
+
+	return cmd
+}
+
+func printConversion(cmd *cobra.Command, conversion services.Conversion) {
+	cmd.Printf("  - %s %s = %s %s (rate: %.6f, as of %s)\n",
+		conversion.Amount, conversion.From,
+		conversion.Result.StringFixed(money.MinorUnits(conversion.To)), conversion.To,
+		conversion.Rate, conversion.RatesInfo.Timestamp.Format(time.RFC3339))
+}
+
+func printRatesInfo(cmd *cobra.Command, info services.RatesInfo) {
+	provider := info.Provider
+	if provider == "" {
+		provider = "unknown provider"
+	}
+	cmd.Printf("\nExchange rates from %s, as of %s (%s old)\n", provider, info.Timestamp.Format(time.RFC3339), info.Age)
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewDistanceCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	var unit string
+
+	cmd := &cobra.Command{
+		Use:     "distance [from] [to]",
+		Short:   "Measure the distance between two IP addresses or coordinates",
+		Long:    `Measure the ellipsoidal (WGS84) distance, bearings and midpoint between two endpoints, each given as an IP address or a lat,lng pair.`,
+		Args:    cobra.ExactArgs(2),
+		Example: "gip distance 8.8.8.8 40.42,-3.70 --unit mi\ngip distance --unit nmi -- -34.61,-58.38 40.42,-3.70",
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := services.CalculateDistanceBetween(redisCache, httpClient, services.DistanceRequest{
+				From: args[0],
+				To:   args[1],
+				Unit: unit,
+			})
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			printDistanceEndpoint(cmd, "From", result.From)
+			printDistanceEndpoint(cmd, "To", result.To)
+
+			cmd.Printf("\nDistance: %.2f %s (%s)\n", result.Distance, result.Unit, result.Method)
+			if result.MaxDistance > result.MinDistance {
+				cmd.Printf("Range: %.2f - %.2f %s\n", result.MinDistance, result.MaxDistance, result.Unit)
+			}
+			cmd.Printf("Initial bearing: %.1f° (%s)\n", result.InitialBearing, result.InitialCompass)
+			cmd.Printf("Final bearing: %.1f° (%s)\n", result.FinalBearing, result.FinalCompass)
+			cmd.Printf("Midpoint: %.4f, %.4f\n", result.Midpoint.Latitude, result.Midpoint.Longitude)
+		},
+	}
+
+	cmd.Flags().StringVar(&unit, "unit", "km", "Distance unit: km, mi or nmi")
+
+	return cmd
+}
+
+func printDistanceEndpoint(cmd *cobra.Command, label string, endpoint services.DistanceEndpoint) {
+	cmd.Printf("%s: %s (%.4f, %.4f, %s coordinates", label, endpoint.Input,
+		endpoint.Coordinates.Latitude, endpoint.Coordinates.Longitude, endpoint.Coordinates.Source)
+	if endpoint.CountryName != "" {
+		cmd.Printf(", %s", endpoint.CountryName)
+	}
+	if endpoint.City != "" {
+		cmd.Printf(", %s", endpoint.City)
+	}
+	cmd.Println(")")
+}
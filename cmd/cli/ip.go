@@ -1,13 +1,28 @@
 package cli
 
 import (
+	"sort"
+	"time"
+
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
 	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/cgiraldoz/geo-ip-info/internal/timezone"
 	"github.com/spf13/cobra"
 )
 
 func NewIPCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
-	return &cobra.Command{
+	var amount string
+	var currency string
+	var to []string
+	var base []string
+	var date string
+	var timeFormat string
+	var homeTimezone string
+	var references []string
+	var latency bool
+
+	cmd := &cobra.Command{
 		Use:     "ip [ip address]",
 		Short:   "Get information about an IP address",
 		Long:    `Get information about an IP address, such as the country, currency, and timezone.`,
@@ -15,7 +30,24 @@ func NewIPCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.
 		Example: "gip ip 8.8.8.8",
 		Run: func(cmd *cobra.Command, args []string) {
 			ip := args[0]
-			ipDetails, err := services.GetIPLocationDetails(redisCache, httpClient, ip)
+			if err := services.ValidateTimeFormat(timeFormat); err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			homeLocation, err := services.ResolveHomeLocation(httpClient, homeTimezone, "")
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			ipDetails, err := services.GetIPLocationDetails(redisCache, httpClient, ip, services.IPLocationOptions{
+				BaseCurrencies: base,
+				RatesDate:      date,
+				TimeFormat:     timeFormat,
+				HomeLocation:   homeLocation,
+				References:     references,
+			})
 
 			if err != nil {
 				cmd.PrintErrln(err)
@@ -31,17 +63,126 @@ func NewIPCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.
 				cmd.Printf("  - %s: %s (%s)\n", code, currency.Name, currency.Symbol)
 			}
 
-			cmd.Println("\nRelative Exchange Rates (compared to USD):")
-			for code, rate := range ipDetails.RelativeRates {
-				cmd.Printf("  - %s: %.2f\n", code, rate)
+			bases := make([]string, 0, len(ipDetails.RatesMatrix))
+			for baseCurrency := range ipDetails.RatesMatrix {
+				bases = append(bases, baseCurrency)
+			}
+			sort.Strings(bases)
+
+			for _, baseCurrency := range bases {
+				cmd.Printf("\nRelative Exchange Rates (compared to %s):\n", baseCurrency)
+				for code, rate := range ipDetails.RatesMatrix[baseCurrency] {
+					cmd.Printf("  - %s: %.2f\n", code, rate)
+				}
 			}
 
+			printRatesInfo(cmd, ipDetails.RatesInfo)
+
+			cmd.Printf("\nTime Zone: %s\n", ipDetails.TimeZone)
+
 			cmd.Println("\nCurrent Time by Timezone:")
-			for timezone, currentTime := range ipDetails.CurrentTimeByTimezone {
-				cmd.Printf("  - %s: %s\n", timezone, currentTime)
+			for _, info := range ipDetails.Timezones {
+				dst := ""
+				if info.IsDST {
+					dst = ", DST"
+				}
+				cmd.Printf("  - %s (%s, %s%s): %s, %s", info.Name, info.LegacyKey, info.Abbreviation, dst,
+					services.FormatTime(info.CurrentTime, timeFormat), info.Weekday)
+				if info.IsBusinessHours {
+					cmd.Print(", business hours")
+				}
+				if info.HomeTimeZone != "" {
+					cmd.Printf(", %s from %s", timezone.FormatOffset(info.OffsetFromHomeSeconds), info.HomeTimeZone)
+				}
+				cmd.Println()
+			}
+
+			if ipDetails.Sun != nil {
+				printSun(cmd, ipDetails, timeFormat)
 			}
 
 			cmd.Printf("\nDistance to Buenos Aires: %.2f km\n", ipDetails.DistanceToBuenosAires)
+
+			cmd.Printf("\nDistances (from %s coordinates", ipDetails.Coordinates.Source)
+			if ipDetails.Coordinates.AccuracyRadiusKm > 0 {
+				cmd.Printf(", accuracy radius %.0f km", ipDetails.Coordinates.AccuracyRadiusKm)
+			}
+			cmd.Println("):")
+			for _, distance := range ipDetails.Distances {
+				cmd.Printf("  - %s: %.2f km (%.2f - %.2f km)\n", distance.Reference.Name, distance.DistanceKm, distance.MinKm, distance.MaxKm)
+			}
+
+			if latency {
+				cmd.Println("\nMinimum RTT over fiber (speed-of-light bound):")
+				for _, bound := range services.LatencyBounds(ipDetails.Distances) {
+					cmd.Printf("  - %s: %.1f ms (%.1f - %.1f ms, %.1f ms in vacuum)\n", bound.Distance.Reference.Name,
+						bound.FiberRTTMs, bound.MinFiberRTTMs, bound.MaxFiberRTTMs, bound.VacuumRTTMs)
+				}
+			}
+
+			if cmd.Flags().Changed("amount") {
+				targets := to
+				if len(targets) == 0 {
+					for code := range ipDetails.Currencies {
+						targets = append(targets, code)
+					}
+					sort.Strings(targets)
+				}
+
+				price, err := money.NewDecimal(amount)
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+
+				conversions, err := services.ConvertToCurrencies(redisCache, currency, targets, price, date)
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+
+				cmd.Println("\nPrice Conversions:")
+				for _, conversion := range conversions {
+					printConversion(cmd, conversion)
+				}
+			}
 		},
 	}
+
+	cmd.Flags().StringVar(&amount, "amount", "", "Price to convert into the local currencies")
+	cmd.Flags().StringVar(&currency, "currency", "USD", "Currency of the price given with --amount")
+	cmd.Flags().StringSliceVar(&to, "to", nil, "Target currencies (defaults to the local currencies)")
+	cmd.Flags().StringVar(&timeFormat, "time-format", "", "Time format: rfc1123, rfc3339, unix or a Go layout")
+	cmd.Flags().StringVar(&homeTimezone, "home-tz", "", "Home time zone for offsets (defaults to time.home_timezone)")
+	cmd.Flags().StringArrayVar(&references, "from", nil, "Reference location for distances: a configured name or lat,lng (repeatable, defaults to all reference_locations)")
+	cmd.Flags().BoolVar(&latency, "latency", false, "Show the theoretical minimum fiber RTT to each reference location")
+	cmd.Flags().StringVar(&date, "date", "", "Use the exchange rates stored for this date (YYYY-MM-DD)")
+	cmd.Flags().StringSliceVar(&base, "base", nil, "Reference currencies for relative rates (defaults to rates.base_currencies)")
+
+	return cmd
+}
+
+func printSun(cmd *cobra.Command, ipDetails *services.IPLocationDetails, timeFormat string) {
+	sun := ipDetails.Sun
+	formatOptional := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return services.FormatTime(*t, timeFormat)
+	}
+
+	cmd.Printf("\nSun (%s, %.4f, %.4f from %s coordinates):\n", sun.Date,
+		ipDetails.Coordinates.Latitude, ipDetails.Coordinates.Longitude, ipDetails.Coordinates.Source)
+	switch {
+	case sun.PolarDay:
+		cmd.Println("  - Polar day: the sun does not set")
+	case sun.PolarNight:
+		cmd.Println("  - Polar night: the sun does not rise")
+	}
+	cmd.Printf("  - Civil dawn: %s\n", formatOptional(sun.CivilDawn))
+	cmd.Printf("  - Sunrise: %s\n", formatOptional(sun.Sunrise))
+	cmd.Printf("  - Solar noon: %s\n", services.FormatTime(sun.SolarNoon, timeFormat))
+	cmd.Printf("  - Sunset: %s\n", formatOptional(sun.Sunset))
+	cmd.Printf("  - Civil dusk: %s\n", formatOptional(sun.CivilDusk))
+	cmd.Printf("  - Now: %s\n", sun.Phase)
 }
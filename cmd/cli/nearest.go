@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewNearestCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	var n int
+	var kinds []string
+
+	cmd := &cobra.Command{
+		Use:     "nearest [ip address or lat,lng]",
+		Short:   "List the countries, capitals and custom points closest to an IP address",
+		Long:    `Rank the country centroids, capitals and the points of nearest.points_file by distance from an IP address or coordinates.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "gip nearest 8.8.8.8 -n 10 --kind capital,office",
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := services.FindNearest(redisCache, httpClient, services.NearestRequest{
+				Origin: args[0],
+				N:      n,
+				Kinds:  kinds,
+			})
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			printDistanceEndpoint(cmd, "Origin", result.Origin)
+			cmd.Println()
+			for i, place := range result.Places {
+				cmd.Printf("%2d. %s (%s", i+1, place.Name, place.Kind)
+				if place.CountryCode != "" {
+					cmd.Printf(", %s", place.CountryCode)
+				}
+				cmd.Printf("): %.2f km, %.1f° %s\n", place.DistanceKm, place.Bearing, place.Compass)
+			}
+		},
+	}
+
+	cmd.Flags().IntVarP(&n, "n", "n", 0, "Number of results (defaults to nearest.default_n)")
+	cmd.Flags().StringSliceVar(&kinds, "kind", nil, "Only include these kinds: country, capital or a kind from the points file")
+
+	return cmd
+}
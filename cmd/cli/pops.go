@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewPoPsCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	var weighted bool
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:     "pops [ip address or lat,lng]",
+		Short:   "Rank the configured points of presence for an IP address",
+		Long:    `Rank the enabled PoPs of pops.list by distance from an IP address, applying the country pinning rules and, optionally, capacity weighting.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "gip pops 8.8.8.8 --weighted",
+		Run: func(cmd *cobra.Command, args []string) {
+			request := services.PoPRequest{Origin: args[0], Limit: limit}
+			if cmd.Flags().Changed("weighted") {
+				request.Weighted = &weighted
+			}
+
+			selection, err := services.RankPoPs(redisCache, httpClient, request)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			printDistanceEndpoint(cmd, "Origin", selection.Origin)
+			cmd.Printf("\nPoPs (capacity weighting: %t):\n", selection.Weighted)
+			for i, ranked := range selection.PoPs {
+				cmd.Printf("%2d. %s: %.2f km, score %.2f, weight %g", i+1, ranked.PoP.Name, ranked.DistanceKm, ranked.Score, ranked.PoP.Weight)
+				if ranked.Pinned {
+					cmd.Print(", pinned")
+				}
+				cmd.Println()
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&weighted, "weighted", false, "Divide distances by the PoP weight (defaults to pops.capacity_weighting)")
+	cmd.Flags().IntVarP(&limit, "n", "n", 0, "Number of PoPs to list (defaults to all)")
+
+	return cmd
+}
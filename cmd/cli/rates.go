@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewRatesCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rates",
+		Short: "Inspect and manage stored exchange rates",
+		Long:  `Inspect and manage the exchange rate tables stored by the service.`,
+	}
+
+	cmd.AddCommand(newRatesHistoryCmd(redisCache))
+	cmd.AddCommand(newRatesBackfillCmd(redisCache, httpClient))
+	cmd.AddCommand(newRatesAlertsCmd(redisCache))
+
+	return cmd
+}
+
+func newRatesAlertsCmd(redisCache interfaces.Cache) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "Inspect exchange-rate change alerts",
+		Long:  `Dry-run the exchange-rate alert rules and inspect the webhook delivery log.`,
+	}
+
+	var previous string
+	testCmd := &cobra.Command{
+		Use:     "test",
+		Short:   "Evaluate the alert rules against stored rates without sending webhooks",
+		Long:    `Evaluate the alert rules comparing the current rate table with a stored daily table, without delivering any webhook.`,
+		Example: "gip rates alerts test --previous 2024-01-01",
+		Run: func(cmd *cobra.Command, args []string) {
+			alerts, err := services.DryRunRateAlerts(redisCache, previous)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			cmd.Println("Alert Rules:")
+			for _, alert := range alerts {
+				status := "ok"
+				if alert.Triggered {
+					status = "TRIGGERED"
+				}
+				cmd.Printf("  - %s (%s/%s): %.6f -> %.6f (%+.2f%%, threshold %.2f%%) %s\n",
+					alert.Rule, alert.Base, alert.Quote, alert.PreviousRate, alert.CurrentRate,
+					alert.ChangePercent, alert.Threshold, status)
+			}
+		},
+	}
+	testCmd.Flags().StringVar(&previous, "previous", "", "Date of the stored table to compare against (YYYY-MM-DD, defaults to the day before the current table)")
+
+	logCmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show the webhook delivery log",
+		Run: func(cmd *cobra.Command, args []string) {
+			deliveries, err := services.GetRateAlertDeliveries(context.Background(), redisCache)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			cmd.Println("Alert Deliveries:")
+			for _, delivery := range deliveries {
+				status := "delivered"
+				if !delivery.Success {
+					status = "failed: " + delivery.Error
+				}
+				cmd.Printf("  - %s %s -> %s (attempts: %d, status: %d) %s\n",
+					delivery.DeliveredAt, delivery.Rule, delivery.URL, delivery.Attempts, delivery.StatusCode, status)
+			}
+		},
+	}
+
+	cmd.AddCommand(testCmd)
+	cmd.AddCommand(logCmd)
+
+	return cmd
+}
+
+func newRatesHistoryCmd(redisCache interfaces.Cache) *cobra.Command {
+	var from, to, base string
+
+	cmd := &cobra.Command{
+		Use:     "history [currency]",
+		Short:   "Show the stored daily rates of a currency",
+		Long:    `Show the time series of daily exchange rates stored for a currency against a reference currency.`,
+		Args:    cobra.ExactArgs(1),
+		Example: "gip rates history EUR --from 2024-01-01 --to 2024-01-31",
+		Run: func(cmd *cobra.Command, args []string) {
+			fromDate, toDate, err := parseDateRange(from, to)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			points, err := services.GetRatesHistory(redisCache, args[0], base, fromDate, toDate)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			if len(points) == 0 {
+				cmd.Println("No stored rates found for the given range.")
+				return
+			}
+
+			cmd.Printf("%s rates (compared to %s):\n", args[0], base)
+			for _, point := range points {
+				cmd.Printf("  - %s: %.6f\n", point.Date, point.Rate)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "First date of the series (YYYY-MM-DD, defaults to 30 days ago)")
+	cmd.Flags().StringVar(&to, "to", "", "Last date of the series (YYYY-MM-DD, defaults to today)")
+	cmd.Flags().StringVar(&base, "base", "USD", "Reference currency")
+
+	return cmd
+}
+
+func newRatesBackfillCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	var from, to string
+	var files []string
+
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Backfill stored daily rates",
+		Long:  `Backfill stored daily exchange rates from the provider's historical endpoint or from local JSON files.`,
+		Example: `gip rates backfill --from 2024-01-01 --to 2024-01-31
+gip rates backfill --file rates-2024-01-01.json --file rates-2024-01-02.json`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var saved int
+			var err error
+
+			if len(files) > 0 {
+				saved, err = services.BackfillRatesHistoryFromFiles(redisCache, files)
+			} else {
+				var fromDate, toDate time.Time
+				fromDate, toDate, err = parseDateRange(from, to)
+				if err == nil {
+					saved, err = services.BackfillRatesHistory(redisCache, httpClient, fromDate, toDate)
+				}
+			}
+
+			cmd.Printf("Stored %d daily rate tables\n", saved)
+			if err != nil {
+				cmd.PrintErrln(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "First date to backfill (YYYY-MM-DD, defaults to 30 days ago)")
+	cmd.Flags().StringVar(&to, "to", "", "Last date to backfill (YYYY-MM-DD, defaults to today)")
+	cmd.Flags().StringArrayVar(&files, "file", nil, "Local rates file in fixer format (repeatable)")
+
+	return cmd
+}
+
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	fromDate, toDate := today.AddDate(0, 0, -30), today
+
+	var err error
+	if from != "" {
+		if fromDate, err = services.ParseRatesDate(from); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if to != "" {
+		if toDate, err = services.ParseRatesDate(to); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return fromDate, toDate, nil
+}
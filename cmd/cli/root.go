@@ -15,6 +15,13 @@ func InitializeCommands(redisCache interfaces.Cache, httpClient interfaces.Clien
 	rootCmd.AddCommand(NewStatsCmd(redisCache))
 	rootCmd.AddCommand(NewApiCmd(redisCache, httpClient))
 	rootCmd.AddCommand(NewIPCmd(redisCache, httpClient))
+	rootCmd.AddCommand(NewConvertCmd(redisCache))
+	rootCmd.AddCommand(NewRatesCmd(redisCache, httpClient))
+	rootCmd.AddCommand(NewTimeCmd(httpClient))
+	rootCmd.AddCommand(NewDistanceCmd(redisCache, httpClient))
+	rootCmd.AddCommand(NewNearestCmd(redisCache, httpClient))
+	rootCmd.AddCommand(NewPoPsCmd(redisCache, httpClient))
+	rootCmd.AddCommand(NewTravelCmd(redisCache, httpClient))
 }
 
 func Execute(redisCache interfaces.Cache, httpClient interfaces.Client) error {
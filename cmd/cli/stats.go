@@ -2,17 +2,28 @@ package cli
 
 import (
 	"context"
+	"time"
+
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
 	"github.com/cgiraldoz/geo-ip-info/internal/services"
 	"github.com/spf13/cobra"
 )
 
 func NewStatsCmd(redisCache interfaces.Cache) *cobra.Command {
-	return &cobra.Command{
+	var since string
+	var granularity string
+
+	cmd := &cobra.Command{
 		Use:   "stats",
 		Short: "View usage distance statistics of the service",
 		Long:  `Display distance statistics for service usage, including farthest, closest, and average distances from Buenos Aires.`,
+		Example: "gip stats\n" +
+			"gip stats --since 24h --granularity hour",
 		Run: func(cmd *cobra.Command, args []string) {
+			if since != "" || granularity != "" {
+				printStatsWindow(cmd, redisCache, since, granularity)
+				return
+			}
 
 			stats, err := services.GetDistanceStatsFromCache(context.Background(), redisCache)
 			if err != nil {
@@ -24,15 +35,124 @@ func NewStatsCmd(redisCache interfaces.Cache) *cobra.Command {
 			cmd.Printf("  Farthest Distance: %.2f km (Country: %s)\n", stats.FarthestDistance, stats.FarthestCountryName)
 			cmd.Printf("  Closest Distance: %.2f km (Country: %s)\n", stats.ClosestDistance, stats.ClosestCountryName)
 			cmd.Printf("  Total Distance: %.2f km\n", stats.TotalDistance)
-			cmd.Printf("  Total Requests: %d\n", stats.TotalRequests)
+			cmd.Printf("  Total Requests: %d (Unique IPs: %d)\n", stats.TotalRequests, stats.UniqueVisitors)
 
 			averageDistance := services.CalculateWeightedAverageDistance(stats)
 			cmd.Printf("  Average Distance: %.2f km\n", averageDistance)
 
+			if stats.Histogram != nil && stats.Histogram.Count() > 0 {
+				cmd.Printf("  Percentiles: p50 %.0f km, p90 %.0f km, p99 %.0f km\n",
+					stats.Histogram.Quantile(0.5), stats.Histogram.Quantile(0.9), stats.Histogram.Quantile(0.99))
+				if count := stats.Histogram.Count(); count < uint64(stats.TotalRequests) {
+					cmd.Printf("  (percentiles cover %d of %d requests; migrated totals have no distribution)\n", count, stats.TotalRequests)
+				}
+
+				cmd.Println("\nDistance Distribution:")
+				for _, bucket := range stats.Histogram.Buckets() {
+					cmd.Printf("  %8.0f - %8.0f km: %d\n", bucket.Lower, bucket.Upper, bucket.Count)
+				}
+			}
+
 			cmd.Println("\nDistance by Country:")
 			for country, data := range stats.CountryDistances {
-				cmd.Printf("  - %s: %.2f km (Requests: %d)\n", country, data.TotalDistance/float64(data.Requests), data.Requests)
+				cmd.Printf("  - %s: %.2f km (Requests: %d, Unique IPs: %d", country, data.TotalDistance/float64(data.Requests), data.Requests, data.UniqueVisitors)
+				if data.Histogram != nil && data.Histogram.Count() > 0 {
+					cmd.Printf(", p50 %.0f km, p90 %.0f km, p99 %.0f km",
+						data.Histogram.Quantile(0.5), data.Histogram.Quantile(0.9), data.Histogram.Quantile(0.99))
+				}
+				cmd.Println(")")
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only show the last period, e.g. 90m, 24h or 7d")
+	cmd.Flags().StringVar(&granularity, "granularity", "", "Bucket size: minute, hour or day (chosen from --since by default)")
+
+	cmd.AddCommand(newStatsTopCmd(redisCache))
+	cmd.AddCommand(newStatsExportCmd(redisCache))
+	cmd.AddCommand(newStatsImportCmd(redisCache))
+	cmd.AddCommand(newStatsResetCmd(redisCache))
+
+	return cmd
+}
+
+func newStatsTopCmd(redisCache interfaces.Cache) *cobra.Command {
+	var dimension string
+	var n int
+	var window string
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show the countries, networks, ASNs or API callers with the most requests",
+		Long:  `Rank the members of a leaderboard by request count, either since the statistics were created or for a recent window.`,
+		Args:  cobra.NoArgs,
+		Example: "gip stats top\n" +
+			"gip stats top --dimension network -n 20 --window 24h",
+		Run: func(cmd *cobra.Command, args []string) {
+			leaderboard, err := services.GetLeaderboard(redisCache, services.LeaderboardRequest{
+				Dimension: dimension,
+				N:         n,
+				Window:    window,
+			})
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			if leaderboard.From.IsZero() {
+				cmd.Printf("Top %s by requests:\n", leaderboard.Dimension)
+			} else {
+				cmd.Printf("Top %s by requests since %s (%s buckets):\n", leaderboard.Dimension, leaderboard.From.Format(time.RFC1123), leaderboard.Granularity)
+			}
+			if len(leaderboard.Entries) == 0 {
+				cmd.Println("  No requests recorded")
+			}
+			for _, entry := range leaderboard.Entries {
+				cmd.Printf("%3d. %s: %d\n", entry.Rank, entry.Member, entry.Requests)
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&dimension, "dimension", services.LeaderboardCountry, "Leaderboard: country, network, asn or caller")
+	cmd.Flags().IntVarP(&n, "n", "n", 0, "Number of results (default 10)")
+	cmd.Flags().StringVar(&window, "window", "", "Only count the last period, e.g. 1h, 24h or 7d")
+
+	return cmd
+}
+
+func printStatsWindow(cmd *cobra.Command, redisCache interfaces.Cache, since, granularity string) {
+	if since == "" {
+		since = "24h"
+	}
+
+	now := time.Now()
+	from, err := services.ParseStatsTime(since, now)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	window, err := services.GetStatsWindow(redisCache, from, now, granularity)
+	if err != nil {
+		cmd.PrintErrln("Error retrieving stats:", err)
+		return
+	}
+
+	total := window.Total
+	cmd.Printf("Distance Statistics since %s (%s buckets, retention %s):\n", window.From.Format(time.RFC1123), window.Granularity, window.Retention)
+	cmd.Printf("  Total Requests: %d (Unique IPs: %d)\n", total.TotalRequests, total.UniqueVisitors)
+	cmd.Printf("  Total Distance: %.2f km\n", total.TotalDistance)
+	cmd.Printf("  Average Distance: %.2f km\n", total.AverageDistance())
+	if total.TotalRequests > 0 {
+		cmd.Printf("  Farthest Distance: %.2f km (Country: %s)\n", total.FarthestDistance, total.FarthestCountryName)
+		cmd.Printf("  Closest Distance: %.2f km (Country: %s)\n", total.ClosestDistance, total.ClosestCountryName)
+	}
+
+	cmd.Println("\nRequests per bucket:")
+	for _, bucket := range window.Buckets {
+		if bucket.TotalRequests == 0 {
+			continue
+		}
+		cmd.Printf("  - %s: %d requests, %d unique IPs, %.2f km average\n", bucket.Start.Local().Format("2006-01-02 15:04"), bucket.TotalRequests, bucket.UniqueVisitors, bucket.AverageDistance())
+	}
 }
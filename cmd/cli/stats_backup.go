@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func newStatsExportCmd(redisCache interfaces.Cache) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export the usage statistics as json, ndjson or csv",
+		Long:  `Write the all-time statistics, histograms, unique visitor sketches and leaderboards to a file, or to stdout when no file or "-" is given.`,
+		Args:  cobra.MaximumNArgs(1),
+		Example: "gip stats export stats.json\n" +
+			"gip stats export --format csv > stats.csv",
+		Run: func(cmd *cobra.Command, args []string) {
+			path := "-"
+			if len(args) == 1 {
+				path = args[0]
+			}
+			if format == "" && path != "-" {
+				format = strings.TrimPrefix(filepath.Ext(path), ".")
+			}
+
+			records, err := services.ExportStats(redisCache)
+			if err != nil {
+				cmd.PrintErrln("Error exporting stats:", err)
+				return
+			}
+
+			var output bytes.Buffer
+			if err := services.WriteStatsRecords(&output, format, records); err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			if path == "-" {
+				cmd.Print(output.String())
+				return
+			}
+			if err := os.WriteFile(path, output.Bytes(), 0o644); err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+			cmd.Printf("Exported %d records to %s\n", len(records), path)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "json, ndjson or csv (from the file extension by default)")
+
+	return cmd
+}
+
+func newStatsImportCmd(redisCache interfaces.Cache) *cobra.Command {
+	var format string
+	var mode string
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import usage statistics exported with gip stats export",
+		Long:  `Read statistics from a file (or stdin with "-") and add them to the stored ones, or replace the stored ones with --mode replace.`,
+		Args:  cobra.ExactArgs(1),
+		Example: "gip stats import stats.json\n" +
+			"gip stats import stats.csv --mode replace --yes",
+		Run: func(cmd *cobra.Command, args []string) {
+			// The confirmation is read from stdin, which already holds the
+			// statistics, so it could never be answered.
+			if args[0] == "-" && mode == services.StatsImportReplace && !yes {
+				cmd.PrintErrln(`Reading from stdin ("-") with --mode replace requires --yes`)
+				return
+			}
+
+			var reader io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+				defer file.Close()
+				reader = file
+
+				if format == "" {
+					format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+				}
+			}
+
+			records, err := services.ReadStatsRecords(reader, format)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			if mode == services.StatsImportReplace && !yes && !confirm(cmd, "This deletes the current all-time stats and leaderboards before importing.") {
+				cmd.Println("Import cancelled")
+				return
+			}
+
+			if err := services.ImportStats(redisCache, records, mode); err != nil {
+				cmd.PrintErrln("Error importing stats:", err)
+				return
+			}
+			cmd.Printf("Imported %d records (%s)\n", len(records), mode)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "json, ndjson or csv (from the file extension by default)")
+	cmd.Flags().StringVar(&mode, "mode", services.StatsImportMerge, "merge adds to the stored stats, replace deletes the exported ones first")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation with --mode replace (required when reading stdin)")
+
+	return cmd
+}
+
+func newStatsResetCmd(redisCache interfaces.Cache) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Delete all usage statistics",
+		Long:  `Delete the all-time statistics, the time-windowed buckets, the leaderboards and the legacy distance_stats data.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !yes && !confirm(cmd, "This permanently deletes all usage statistics.") {
+				cmd.Println("Reset cancelled")
+				return
+			}
+
+			deleted, err := services.ResetStats(redisCache)
+			if err != nil {
+				cmd.PrintErrln("Error resetting stats:", err)
+				return
+			}
+			cmd.Printf("Deleted %d keys\n", deleted)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+func confirm(cmd *cobra.Command, warning string) bool {
+	cmd.Printf("%s Continue? [y/N] ", warning)
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
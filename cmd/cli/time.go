@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewTimeCmd(httpClient interfaces.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "time",
+		Short: "Time zone utilities for IP addresses",
+		Long:  `Time zone utilities based on the local time of IP addresses.`,
+	}
+
+	cmd.AddCommand(newTimePlanCmd(httpClient))
+
+	return cmd
+}
+
+func newTimePlanCmd(httpClient interfaces.Client) *cobra.Command {
+	var days int
+	var start, end string
+
+	cmd := &cobra.Command{
+		Use:     "plan [ip address...]",
+		Short:   "Find overlapping business hours between IP addresses",
+		Long:    `Resolve the local time zone of each IP address and list the windows where their working hours overlap during the next days.`,
+		Args:    cobra.MinimumNArgs(2),
+		Example: "gip time plan 8.8.8.8 81.2.69.142 --days 3 --start 09:00 --end 17:00",
+		Run: func(cmd *cobra.Command, args []string) {
+			plan, err := services.PlanMeeting(httpClient, services.TimePlanRequest{
+				IPs:       args,
+				Days:      days,
+				WorkStart: start,
+				WorkEnd:   end,
+			})
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			cmd.Println("Participants:")
+			for _, participant := range plan.Participants {
+				cmd.Printf("  - %s: %s, %s (%s) - %s\n", participant.IP, participant.City, participant.CountryName,
+					participant.TimeZone, participant.CurrentTime.Format(time.RFC1123))
+			}
+
+			cmd.Printf("\nOverlapping working hours (%s-%s, next %d days):\n", plan.WorkStart, plan.WorkEnd, plan.Days)
+			if len(plan.Windows) == 0 {
+				cmd.Println("  No overlapping windows found.")
+				return
+			}
+
+			for _, window := range plan.Windows {
+				cmd.Printf("  - %s to %s UTC (%s)\n", window.Start.Format("Mon 02 Jan 15:04"), window.End.Format("15:04"), window.End.Sub(window.Start))
+				for _, participant := range plan.Participants {
+					cmd.Printf("      %s: %s - %s\n", participant.IP,
+						window.Start.In(participant.Location).Format("Mon 15:04"),
+						window.End.In(participant.Location).Format("Mon 15:04 MST"))
+				}
+			}
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 0, "Number of days to search (defaults to timeplanner.days)")
+	cmd.Flags().StringVar(&start, "start", "", "Start of the working day, HH:MM (defaults to timeplanner.work_start)")
+	cmd.Flags().StringVar(&end, "end", "", "End of the working day, HH:MM (defaults to timeplanner.work_end)")
+
+	return cmd
+}
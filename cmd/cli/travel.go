@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/services"
+	"github.com/spf13/cobra"
+)
+
+func NewTravelCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "travel",
+		Short: "Impossible-travel detection for login events",
+		Long:  `Detect logins of the same subject from places too far apart for the elapsed time.`,
+	}
+
+	cmd.AddCommand(newTravelCheckCmd(redisCache, httpClient))
+	cmd.AddCommand(newTravelAlertsCmd(redisCache))
+
+	return cmd
+}
+
+func newTravelCheckCmd(redisCache interfaces.Cache, httpClient interfaces.Client) *cobra.Command {
+	var format string
+	var alertsOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "check [file]",
+		Short: "Check a stream of subject, IP and timestamp events",
+		Long:  `Read login events from a file (or stdin with "-") as json, ndjson or csv and report the ones whose implied travel speed exceeds travel.max_speed_kmh. Each event is checked as soon as it is read, in the order it arrives.`,
+		Args:  cobra.ExactArgs(1),
+		Example: "gip travel check logins.csv\n" +
+			"tail -f logins.ndjson | gip travel check - --format ndjson",
+		Run: func(cmd *cobra.Command, args []string) {
+			var reader io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+				defer file.Close()
+				reader = file
+
+				if format == "" {
+					format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+				}
+			}
+
+			checker, err := services.NewTravelChecker(redisCache, httpClient)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			// Events are checked as they are decoded, in arrival order, so
+			// a stream that never ends is reported line by line.
+			checked, alerts := 0, 0
+			err = services.DecodeLoginEvents(reader, format, func(event services.LoginEvent) error {
+				check, err := checker.Check(event)
+				if err != nil {
+					return err
+				}
+
+				checked++
+				if check.Alert {
+					alerts++
+				} else if alertsOnly {
+					return nil
+				}
+				printTravelCheck(cmd, check)
+				return nil
+			})
+			if err != nil {
+				cmd.PrintErrln(err)
+			}
+			cmd.Printf("\n%d events checked, %d alerts\n", checked, alerts)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "Input format: json, ndjson or csv (defaults to the file extension, or json)")
+	cmd.Flags().BoolVar(&alertsOnly, "alerts-only", false, "Only print events that raised an alert")
+
+	return cmd
+}
+
+func newTravelAlertsCmd(redisCache interfaces.Cache) *cobra.Command {
+	return &cobra.Command{
+		Use:   "alerts",
+		Short: "Show the latest impossible-travel alerts",
+		Run: func(cmd *cobra.Command, args []string) {
+			alerts, err := services.GetTravelAlerts(context.Background(), redisCache)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			cmd.Println("Impossible-travel alerts:")
+			for _, alert := range alerts {
+				printTravelCheck(cmd, alert)
+			}
+		},
+	}
+}
+
+func printTravelCheck(cmd *cobra.Command, check services.TravelCheck) {
+	status := "ok"
+	if check.Alert {
+		status = "ALERT: " + check.Reason
+	}
+
+	cmd.Printf("  - %s %s %s (%s)", check.Current.Timestamp.Format(time.RFC3339), check.Subject, check.Current.IP, check.Current.CountryCode)
+	if check.Previous != nil {
+		cmd.Printf(" from %s (%s): %.0f km (at least %.0f km) in %s, %.0f km/h",
+			check.Previous.IP, check.Previous.CountryCode, check.DistanceKm, check.MinDistanceKm,
+			time.Duration(check.ElapsedSecs*float64(time.Second)), check.SpeedKmh)
+	}
+	cmd.Printf(" - %s\n", status)
+}
@@ -33,11 +33,34 @@ func LoadConfigurations() error {
 		log.Fatal("IPAPI_API_KEY is not set")
 	}
 
+	replacer := strings.NewReplacer(
+		"FIXER_API_KEY", fixerApiKey,
+		"IPAPI_API_KEY", ipapiApiKey,
+		"OPENEXCHANGERATES_APP_ID", viper.GetString("OPENEXCHANGERATES_APP_ID"),
+		"ADMIN_API_TOKEN", viper.GetString("ADMIN_API_TOKEN"),
+	)
+
 	for _, key := range viper.AllKeys() {
-		value := viper.GetString(key)
-		value = strings.ReplaceAll(value, "FIXER_API_KEY", fixerApiKey)
-		value = strings.ReplaceAll(value, "IPAPI_API_KEY", ipapiApiKey)
-		viper.Set(key, value)
+		viper.Set(key, replacePlaceholders(viper.Get(key), replacer))
 	}
 	return nil
 }
+
+func replacePlaceholders(value interface{}, replacer *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replacer.Replace(v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = replacePlaceholders(item, replacer)
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = replacePlaceholders(item, replacer)
+		}
+		return v
+	default:
+		return value
+	}
+}
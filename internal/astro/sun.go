@@ -0,0 +1,129 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch   = 2440587.5
+	julian2000        = 2451545.0
+	earthObliquity    = 23.4397
+	sunriseAltitude   = -0.833
+	civilTwilightSun  = -6.0
+	secondsPerDay     = 86400.0
+	perihelionLongDeg = 102.9372
+)
+
+const (
+	PhaseDay           = "day"
+	PhaseCivilTwilight = "civil_twilight"
+	PhaseNight         = "night"
+)
+
+type SunTimes struct {
+	Date       string
+	SolarNoon  time.Time
+	Sunrise    *time.Time
+	Sunset     *time.Time
+	CivilDawn  *time.Time
+	CivilDusk  *time.Time
+	PolarDay   bool
+	PolarNight bool
+	IsDaylight bool
+	Phase      string
+}
+
+// Compute evaluates the sunrise equation (accurate to about a minute outside
+// polar regions) for the local calendar day of now at the given coordinates.
+func Compute(now time.Time, latitude, longitude float64, location *time.Location) SunTimes {
+	local := now.In(location)
+	noonUTC := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, time.UTC)
+
+	n := math.Round(toJulian(noonUTC) - julian2000)
+	meanSolarTime := n - longitude/360
+
+	meanAnomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360)
+	m := radians(meanAnomaly)
+	center := 1.9148*math.Sin(m) + 0.0200*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	eclipticLongitude := radians(math.Mod(meanAnomaly+center+180+perihelionLongDeg, 360))
+
+	transit := julian2000 + meanSolarTime + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*eclipticLongitude)
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(radians(earthObliquity)))
+
+	times := SunTimes{
+		Date:      local.Format("2006-01-02"),
+		SolarNoon: fromJulian(transit).In(location),
+	}
+
+	sunrise, sunset, alwaysUp, alwaysDown := hourAngleEvents(transit, latitude, declination, sunriseAltitude)
+	times.PolarDay, times.PolarNight = alwaysUp, alwaysDown
+	times.Sunrise, times.Sunset = inLocation(sunrise, location), inLocation(sunset, location)
+
+	dawn, dusk, _, _ := hourAngleEvents(transit, latitude, declination, civilTwilightSun)
+	times.CivilDawn, times.CivilDusk = inLocation(dawn, location), inLocation(dusk, location)
+
+	altitude := solarAltitude(now, latitude, longitude, declination, transit)
+	switch {
+	case altitude >= sunriseAltitude:
+		times.Phase = PhaseDay
+	case altitude >= civilTwilightSun:
+		times.Phase = PhaseCivilTwilight
+	default:
+		times.Phase = PhaseNight
+	}
+	times.IsDaylight = times.Phase == PhaseDay
+
+	return times
+}
+
+func hourAngleEvents(transit, latitude, declination, altitude float64) (*time.Time, *time.Time, bool, bool) {
+	phi := radians(latitude)
+	cosOmega := (math.Sin(radians(altitude)) - math.Sin(phi)*math.Sin(declination)) / (math.Cos(phi) * math.Cos(declination))
+
+	if cosOmega < -1 {
+		return nil, nil, true, false
+	}
+	if cosOmega > 1 {
+		return nil, nil, false, true
+	}
+
+	omega := degrees(math.Acos(cosOmega))
+	rise := fromJulian(transit - omega/360)
+	set := fromJulian(transit + omega/360)
+	return &rise, &set, false, false
+}
+
+// solarAltitude approximates the current sun altitude from the hour angle
+// relative to the day's solar transit.
+func solarAltitude(now time.Time, latitude, longitude, declination, transit float64) float64 {
+	hourAngle := radians((toJulian(now) - transit) * 360)
+	phi := radians(latitude)
+	sinAltitude := math.Sin(phi)*math.Sin(declination) + math.Cos(phi)*math.Cos(declination)*math.Cos(hourAngle)
+	return degrees(math.Asin(sinAltitude))
+}
+
+func inLocation(t *time.Time, location *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(location)
+	return &local
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.UnixNano())/1e9/secondsPerDay + julianUnixEpoch
+}
+
+func fromJulian(julian float64) time.Time {
+	seconds := (julian - julianUnixEpoch) * secondsPerDay
+	return time.Unix(0, int64(seconds*1e9)).UTC().Truncate(time.Second)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,119 @@
+package astro
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return location
+}
+
+func TestComputeSunriseSunset(t *testing.T) {
+	tests := []struct {
+		name      string
+		zone      string
+		latitude  float64
+		longitude float64
+		date      string
+		sunrise   string
+		sunset    string
+	}{
+		{"London midsummer", "Europe/London", 51.5074, -0.1278, "2024-06-21", "04:43", "21:21"},
+		{"London midwinter", "Europe/London", 51.5074, -0.1278, "2024-12-21", "08:04", "15:54"},
+		{"Buenos Aires winter", "America/Argentina/Buenos_Aires", -34.6037, -58.3816, "2024-06-21", "08:00", "17:50"},
+		{"Tokyo", "Asia/Tokyo", 35.6762, 139.6503, "2024-03-20", "05:45", "17:53"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := mustLoadLocation(t, tt.zone)
+			day, _ := time.ParseInLocation("2006-01-02 15:04", tt.date+" 12:00", location)
+
+			times := Compute(day, tt.latitude, tt.longitude, location)
+			if times.Date != tt.date {
+				t.Fatalf("date = %s, want %s", times.Date, tt.date)
+			}
+			if times.Sunrise == nil || times.Sunset == nil {
+				t.Fatalf("expected sunrise and sunset, got %+v", times)
+			}
+
+			assertClock(t, "sunrise", *times.Sunrise, tt.sunrise)
+			assertClock(t, "sunset", *times.Sunset, tt.sunset)
+			if !times.CivilDawn.Before(*times.Sunrise) || !times.CivilDusk.After(*times.Sunset) {
+				t.Fatalf("civil twilight should surround the day: %+v", times)
+			}
+			if times.Phase != PhaseDay || !times.IsDaylight {
+				t.Fatalf("expected daylight at noon, got %s", times.Phase)
+			}
+		})
+	}
+}
+
+// assertClock allows three minutes of error, above the accuracy of the
+// sunrise equation.
+func assertClock(t *testing.T, event string, got time.Time, want string) {
+	t.Helper()
+	expected, _ := time.ParseInLocation("2006-01-02 15:04", got.Format("2006-01-02")+" "+want, got.Location())
+	if diff := got.Sub(expected); diff > 3*time.Minute || diff < -3*time.Minute {
+		t.Fatalf("%s = %s, want about %s", event, got.Format("15:04"), want)
+	}
+}
+
+func TestComputePolarRegions(t *testing.T) {
+	location := mustLoadLocation(t, "Europe/Oslo")
+
+	tests := []struct {
+		date       string
+		polarDay   bool
+		polarNight bool
+		phase      string
+	}{
+		{"2024-06-21", true, false, PhaseDay},
+		{"2024-12-21", false, true, PhaseCivilTwilight},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			day, _ := time.ParseInLocation("2006-01-02 15:04", tt.date+" 12:00", location)
+			times := Compute(day, 69.6492, 18.9553, location)
+
+			if times.PolarDay != tt.polarDay || times.PolarNight != tt.polarNight {
+				t.Fatalf("polar day %v, polar night %v", times.PolarDay, times.PolarNight)
+			}
+			if times.Sunrise != nil || times.Sunset != nil {
+				t.Fatalf("expected no sunrise or sunset, got %v %v", times.Sunrise, times.Sunset)
+			}
+			if times.Phase != tt.phase {
+				t.Fatalf("phase = %s, want %s", times.Phase, tt.phase)
+			}
+		})
+	}
+}
+
+func TestComputePhases(t *testing.T) {
+	location := time.UTC
+
+	tests := []struct {
+		clock string
+		phase string
+	}{
+		{"00:00", PhaseNight},
+		{"05:50", PhaseCivilTwilight},
+		{"12:00", PhaseDay},
+		{"18:25", PhaseCivilTwilight},
+		{"23:00", PhaseNight},
+	}
+
+	for _, tt := range tests {
+		now, _ := time.Parse("2006-01-02 15:04", "2024-03-20 "+tt.clock)
+		if got := Compute(now, 0, 0, location).Phase; got != tt.phase {
+			t.Errorf("phase at %s = %s, want %s", tt.clock, got, tt.phase)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -29,5 +31,80 @@ func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, exp
 }
 
 func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
-	return r.client.Get(ctx, key).Bytes()
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, interfaces.ErrCacheMiss
+	}
+	return data, err
+}
+
+func (r *RedisCache) GetSet(ctx context.Context, key string, value interface{}) ([]byte, error) {
+	data, err := r.client.GetSet(ctx, key, value).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, interfaces.ErrCacheMiss
+	}
+	return data, err
+}
+
+func (r *RedisCache) Del(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// Keys iterates with SCAN rather than KEYS so that large databases are not
+// blocked while matching.
+func (r *RedisCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (r *RedisCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.client.Expire(ctx, key, expiration).Err()
+}
+
+func (r *RedisCache) HIncrByFloat(ctx context.Context, key, field string, increment float64) (float64, error) {
+	return r.client.HIncrByFloat(ctx, key, field, increment).Result()
+}
+
+func (r *RedisCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, key).Result()
+}
+
+func (r *RedisCache) ZIncrBy(ctx context.Context, key string, increment float64, member string) (float64, error) {
+	return r.client.ZIncrBy(ctx, key, increment, member).Result()
+}
+
+func (r *RedisCache) ZRangeWithScores(ctx context.Context, key string) ([]interfaces.ScoredMember, error) {
+	values, err := r.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]interfaces.ScoredMember, 0, len(values))
+	for _, value := range values {
+		member, _ := value.Member.(string)
+		members = append(members, interfaces.ScoredMember{Member: member, Score: value.Score})
+	}
+	return members, nil
+}
+
+func (r *RedisCache) PFAdd(ctx context.Context, key string, elements ...interface{}) error {
+	return r.client.PFAdd(ctx, key, elements...).Err()
+}
+
+// PFCount estimates the cardinality of the union of the given HyperLogLogs.
+func (r *RedisCache) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	return r.client.PFCount(ctx, keys...).Result()
+}
+
+func (r *RedisCache) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	return r.client.PFMerge(ctx, dest, keys...).Err()
+}
+
+// Eval runs a Lua script through EVALSHA, loading it on the first call.
+func (r *RedisCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return redis.NewScript(script).Run(ctx, r.client, keys, args...).Result()
 }
@@ -0,0 +1,193 @@
+package geodesy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ErrUnknownUnit = errors.New("unknown distance unit")
+
+// WGS84 ellipsoid.
+const (
+	semiMajorAxis = 6378137.0
+	flattening    = 1 / 298.257223563
+	semiMinorAxis = semiMajorAxis * (1 - flattening)
+
+	meanEarthRadius   = 6371008.8
+	vincentyMaxIter   = 200
+	vincentyTolerance = 1e-12
+)
+
+type Unit string
+
+const (
+	Kilometers    Unit = "km"
+	Miles         Unit = "mi"
+	NauticalMiles Unit = "nmi"
+)
+
+var metersPerUnit = map[Unit]float64{
+	Kilometers:    1000,
+	Miles:         1609.344,
+	NauticalMiles: 1852,
+}
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Inverse struct {
+	DistanceMeters float64
+	InitialBearing float64
+	FinalBearing   float64
+	// Converged is false when Vincenty's iteration fails for nearly antipodal
+	// points; the result then falls back to the spherical solution.
+	Converged bool
+}
+
+func ParseUnit(value string) (Unit, error) {
+	if value == "" {
+		return Kilometers, nil
+	}
+
+	unit := Unit(strings.ToLower(value))
+	if _, ok := metersPerUnit[unit]; !ok {
+		return "", fmt.Errorf("%w: %q (use km, mi or nmi)", ErrUnknownUnit, value)
+	}
+	return unit, nil
+}
+
+func (u Unit) FromMeters(meters float64) float64 {
+	return meters / metersPerUnit[u]
+}
+
+// Distance solves the inverse geodesic problem on the WGS84 ellipsoid with
+// Vincenty's formulae. Vincenty does not converge for some nearly antipodal
+// points; those get the haversine distance on the mean sphere instead, which
+// can be off by up to about 0.5%.
+func Distance(from, to Point) Inverse {
+	if from == to {
+		return Inverse{Converged: true}
+	}
+
+	phi1, phi2 := radians(from.Latitude), radians(to.Latitude)
+	l := radians(to.Longitude - from.Longitude)
+
+	u1 := math.Atan((1 - flattening) * math.Tan(phi1))
+	u2 := math.Atan((1 - flattening) * math.Tan(phi2))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM, sinLambda, cosLambda float64
+
+	for i := 0; i < vincentyMaxIter; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return Inverse{Converged: true}
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+
+		c := flattening / 16 * cosSqAlpha * (4 + flattening*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = l + (1-c)*flattening*sinAlpha*
+			(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+		if math.Abs(lambda-previous) < vincentyTolerance {
+			uSq := cosSqAlpha * (semiMajorAxis*semiMajorAxis - semiMinorAxis*semiMinorAxis) / (semiMinorAxis * semiMinorAxis)
+			a := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+			b := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+			deltaSigma := b * sinSigma * (cos2SigmaM + b/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+				b/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+			return Inverse{
+				DistanceMeters: semiMinorAxis * a * (sigma - deltaSigma),
+				InitialBearing: normalizeBearing(degrees(math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda))),
+				FinalBearing:   normalizeBearing(degrees(math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda))),
+				Converged:      true,
+			}
+		}
+	}
+
+	return sphericalInverse(from, to)
+}
+
+func sphericalInverse(from, to Point) Inverse {
+	return Inverse{
+		DistanceMeters: Haversine(from, to),
+		InitialBearing: sphericalBearing(from, to),
+		FinalBearing:   normalizeBearing(sphericalBearing(to, from) + 180),
+	}
+}
+
+func Haversine(from, to Point) float64 {
+	phi1, phi2 := radians(from.Latitude), radians(to.Latitude)
+	dPhi := phi2 - phi1
+	dLambda := radians(to.Longitude - from.Longitude)
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return meanEarthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func sphericalBearing(from, to Point) float64 {
+	phi1, phi2 := radians(from.Latitude), radians(to.Latitude)
+	dLambda := radians(to.Longitude - from.Longitude)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return normalizeBearing(degrees(math.Atan2(y, x)))
+}
+
+// Midpoint returns the point halfway along the great circle between from and to.
+func Midpoint(from, to Point) Point {
+	phi1, phi2 := radians(from.Latitude), radians(to.Latitude)
+	lambda1 := radians(from.Longitude)
+	dLambda := radians(to.Longitude - from.Longitude)
+
+	bx := math.Cos(phi2) * math.Cos(dLambda)
+	by := math.Cos(phi2) * math.Sin(dLambda)
+
+	phi := math.Atan2(math.Sin(phi1)+math.Sin(phi2), math.Hypot(math.Cos(phi1)+bx, by))
+	lambda := lambda1 + math.Atan2(by, math.Cos(phi1)+bx)
+
+	return Point{Latitude: degrees(phi), Longitude: normalizeLongitude(degrees(lambda))}
+}
+
+var compassPoints = []string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// Compass maps a bearing in degrees to one of the 16 compass points.
+func Compass(bearing float64) string {
+	index := int(math.Round(normalizeBearing(bearing)/22.5)) % len(compassPoints)
+	return compassPoints[index]
+}
+
+func normalizeBearing(bearing float64) float64 {
+	return math.Mod(bearing+360, 360)
+}
+
+func normalizeLongitude(longitude float64) float64 {
+	return math.Mod(longitude+540, 360) - 180
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
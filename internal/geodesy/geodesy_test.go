@@ -0,0 +1,174 @@
+package geodesy
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func dms(degrees, minutes, seconds float64) float64 {
+	sign := 1.0
+	if degrees < 0 {
+		sign, degrees = -1, -degrees
+	}
+	return sign * (degrees + minutes/60 + seconds/3600)
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name           string
+		from, to       Point
+		meters         float64
+		initialBearing float64
+		finalBearing   float64
+	}{
+		{
+			// Vincenty's own test line, Flinders Peak to Buninyong.
+			name:           "flinders peak to buninyong",
+			from:           Point{Latitude: dms(-37, 57, 3.72030), Longitude: dms(144, 25, 29.52440)},
+			to:             Point{Latitude: dms(-37, 39, 10.15610), Longitude: dms(143, 55, 35.38390)},
+			meters:         54972.271,
+			initialBearing: dms(306, 52, 5.37),
+			finalBearing:   dms(307, 10, 25.07),
+		},
+		{
+			name:           "one degree along the equator",
+			from:           Point{Latitude: 0, Longitude: 0},
+			to:             Point{Latitude: 0, Longitude: 1},
+			meters:         111319.491,
+			initialBearing: 90,
+			finalBearing:   90,
+		},
+		{
+			name:           "meridian quadrant",
+			from:           Point{Latitude: 0, Longitude: 0},
+			to:             Point{Latitude: 90, Longitude: 0},
+			meters:         10001965.729,
+			initialBearing: 0,
+			finalBearing:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inverse := Distance(tt.from, tt.to)
+			if !inverse.Converged {
+				t.Fatal("expected Vincenty to converge")
+			}
+			if math.Abs(inverse.DistanceMeters-tt.meters) > 0.001 {
+				t.Fatalf("distance = %.4f m, want %.3f m", inverse.DistanceMeters, tt.meters)
+			}
+			if bearingDiff(inverse.InitialBearing, tt.initialBearing) > 1e-4 {
+				t.Fatalf("initial bearing = %.6f, want %.6f", inverse.InitialBearing, tt.initialBearing)
+			}
+			if bearingDiff(inverse.FinalBearing, tt.finalBearing) > 1e-4 {
+				t.Fatalf("final bearing = %.6f, want %.6f", inverse.FinalBearing, tt.finalBearing)
+			}
+		})
+	}
+}
+
+func bearingDiff(a, b float64) float64 {
+	diff := math.Abs(a - b)
+	return math.Min(diff, 360-diff)
+}
+
+func TestDistanceSamePoint(t *testing.T) {
+	point := Point{Latitude: -34.6, Longitude: -58.4}
+	if inverse := Distance(point, point); inverse.DistanceMeters != 0 || !inverse.Converged {
+		t.Fatalf("got %+v", inverse)
+	}
+}
+
+func TestDistanceNearlyAntipodalFallsBack(t *testing.T) {
+	from := Point{Latitude: 0, Longitude: 0}
+	to := Point{Latitude: 0.5, Longitude: 179.7}
+
+	inverse := Distance(from, to)
+	if inverse.Converged {
+		t.Fatal("expected Vincenty not to converge")
+	}
+	if inverse.DistanceMeters != Haversine(from, to) {
+		t.Fatalf("expected the spherical distance, got %f", inverse.DistanceMeters)
+	}
+	if inverse.DistanceMeters < 19_900_000 || inverse.DistanceMeters > 20_100_000 {
+		t.Fatalf("distance %f is not close to half the circumference", inverse.DistanceMeters)
+	}
+}
+
+func TestHaversineAgreesWithVincenty(t *testing.T) {
+	buenosAires := Point{Latitude: -34.6037, Longitude: -58.3816}
+	madrid := Point{Latitude: 40.4168, Longitude: -3.7038}
+
+	spherical := Haversine(buenosAires, madrid)
+	ellipsoidal := Distance(buenosAires, madrid).DistanceMeters
+	if math.Abs(spherical-ellipsoidal)/ellipsoidal > 0.005 {
+		t.Fatalf("haversine %f and vincenty %f differ by more than 0.5%%", spherical, ellipsoidal)
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	tests := []struct {
+		from, to, want Point
+	}{
+		{Point{0, 0}, Point{0, 90}, Point{0, 45}},
+		{Point{0, 170}, Point{0, -170}, Point{0, 180}},
+		{Point{-10, 20}, Point{10, 20}, Point{0, 20}},
+	}
+
+	for _, tt := range tests {
+		got := Midpoint(tt.from, tt.to)
+		if math.Abs(got.Latitude-tt.want.Latitude) > 1e-9 || bearingDiff(got.Longitude, tt.want.Longitude) > 1e-9 {
+			t.Errorf("Midpoint(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCompass(t *testing.T) {
+	tests := []struct {
+		bearing float64
+		want    string
+	}{
+		{0, "N"}, {11, "N"}, {12, "NNE"}, {45, "NE"}, {90, "E"},
+		{180, "S"}, {270, "W"}, {348.75, "N"}, {359, "N"}, {-90, "W"},
+	}
+
+	for _, tt := range tests {
+		if got := Compass(tt.bearing); got != tt.want {
+			t.Errorf("Compass(%v) = %s, want %s", tt.bearing, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Unit
+		err   error
+	}{
+		{"", Kilometers, nil},
+		{"km", Kilometers, nil},
+		{"MI", Miles, nil},
+		{"nmi", NauticalMiles, nil},
+		{"ft", "", ErrUnknownUnit},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseUnit(tt.value)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseUnit(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestFromMeters(t *testing.T) {
+	if got := Miles.FromMeters(1609.344); got != 1 {
+		t.Errorf("1609.344 m = %v mi", got)
+	}
+	if got := NauticalMiles.FromMeters(3704); got != 2 {
+		t.Errorf("3704 m = %v nmi", got)
+	}
+	if got := Kilometers.FromMeters(1500); got != 1.5 {
+		t.Errorf("1500 m = %v km", got)
+	}
+}
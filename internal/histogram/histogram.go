@@ -0,0 +1,106 @@
+package histogram
+
+import (
+	"math"
+	"sort"
+)
+
+// subBuckets per power of two bounds the relative error of a recorded value
+// to 1/subBuckets, like an HDR histogram with one significant digit.
+const subBuckets = 16
+
+// Histogram is a log-linear histogram. Bucket counts only ever add up, so
+// histograms recorded by different processes merge by summing their counts.
+type Histogram struct {
+	counts map[int]uint64
+	total  uint64
+}
+
+type Bucket struct {
+	Index int
+	Lower float64
+	Upper float64
+	Count uint64
+}
+
+func New() *Histogram {
+	return &Histogram{counts: make(map[int]uint64)}
+}
+
+// BucketIndex maps values below 1 to bucket 0 and every other value to its
+// power-of-two range and linear sub-bucket within it.
+func BucketIndex(value float64) int {
+	if value < 1 || math.IsNaN(value) {
+		return 0
+	}
+
+	exponent := int(math.Floor(math.Log2(value)))
+	sub := int((value/math.Exp2(float64(exponent)) - 1) * subBuckets)
+	sub = min(max(sub, 0), subBuckets-1)
+	return 1 + exponent*subBuckets + sub
+}
+
+func BucketBounds(index int) (float64, float64) {
+	if index <= 0 {
+		return 0, 1
+	}
+
+	exponent := (index - 1) / subBuckets
+	sub := (index - 1) % subBuckets
+	base := math.Exp2(float64(exponent))
+	width := base / subBuckets
+	return base + float64(sub)*width, base + float64(sub+1)*width
+}
+
+func (h *Histogram) Record(value float64) {
+	h.Add(BucketIndex(value), 1)
+}
+
+func (h *Histogram) Add(index int, count uint64) {
+	if count == 0 {
+		return
+	}
+	h.counts[index] += count
+	h.total += count
+}
+
+func (h *Histogram) Merge(other *Histogram) {
+	for index, count := range other.counts {
+		h.Add(index, count)
+	}
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Quantile returns the midpoint of the bucket holding the q-th quantile
+// (0 <= q <= 1).
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	rank = max(rank, 1)
+
+	var seen uint64
+	for _, bucket := range h.Buckets() {
+		seen += bucket.Count
+		if seen >= rank {
+			return (bucket.Lower + bucket.Upper) / 2
+		}
+	}
+	return 0
+}
+
+// Buckets lists the non-empty buckets in ascending order.
+func (h *Histogram) Buckets() []Bucket {
+	buckets := make([]Bucket, 0, len(h.counts))
+	for index, count := range h.counts {
+		lower, upper := BucketBounds(index)
+		buckets = append(buckets, Bucket{Index: index, Lower: lower, Upper: upper, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Index < buckets[j].Index })
+	return buckets
+}
@@ -0,0 +1,117 @@
+package histogram
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		value float64
+		index int
+	}{
+		{0, 0},
+		{0.99, 0},
+		{-5, 0},
+		{math.NaN(), 0},
+		{1, 1},
+		{1.0625, 2},
+		{1.99, 16},
+		{2, 17},
+		{3, 25},
+		{1024, 1 + 10*subBuckets},
+		{20015, 1 + 14*subBuckets + 3},
+	}
+
+	for _, tt := range tests {
+		if got := BucketIndex(tt.value); got != tt.index {
+			t.Errorf("BucketIndex(%v) = %d, want %d", tt.value, got, tt.index)
+		}
+	}
+}
+
+func TestBucketBoundsContainValue(t *testing.T) {
+	for _, value := range []float64{0.5, 1, 1.5, 2, 7.3, 100, 999.9, 12742, 20015.08} {
+		lower, upper := BucketBounds(BucketIndex(value))
+		if value < lower || value >= upper {
+			t.Errorf("%v is outside its bucket [%v, %v)", value, lower, upper)
+		}
+		if lower >= 1 && (upper-lower)/lower > 1.0/subBuckets+1e-12 {
+			t.Errorf("bucket [%v, %v) is wider than 1/%d of its lower bound", lower, upper, subBuckets)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 10000)
+	h := New()
+	for i := range values {
+		values[i] = 1 + random.Float64()*20000
+		h.Record(values[i])
+	}
+	sort.Float64s(values)
+
+	tests := []struct {
+		name string
+		q    float64
+	}{
+		{"p0", 0},
+		{"p50", 0.5},
+		{"p90", 0.9},
+		{"p99", 0.99},
+		{"p100", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank := max(int(math.Ceil(tt.q*float64(len(values))))-1, 0)
+			exact := values[rank]
+			got := h.Quantile(tt.q)
+			if math.Abs(got-exact)/exact > 1.0/subBuckets {
+				t.Fatalf("Quantile(%v) = %v, exact %v", tt.q, got, exact)
+			}
+		})
+	}
+}
+
+func TestEmptyHistogram(t *testing.T) {
+	h := New()
+	if h.Count() != 0 || h.Quantile(0.5) != 0 || len(h.Buckets()) != 0 {
+		t.Fatalf("expected an empty histogram, got count %d", h.Count())
+	}
+
+	h.Add(3, 0)
+	if h.Count() != 0 || len(h.Buckets()) != 0 {
+		t.Fatal("adding zero should not create a bucket")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, both := New(), New(), New()
+	for i, value := range []float64{1, 5, 5, 120, 3000, 3000, 3000, 19000} {
+		if i%2 == 0 {
+			a.Record(value)
+		} else {
+			b.Record(value)
+		}
+		both.Record(value)
+	}
+
+	a.Merge(b)
+	if a.Count() != both.Count() {
+		t.Fatalf("merged count %d, want %d", a.Count(), both.Count())
+	}
+
+	got, want := a.Buckets(), both.Buckets()
+	if len(got) != len(want) {
+		t.Fatalf("merged buckets %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("merged buckets %+v, want %+v", got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package hll
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// precision matches Redis: 2^14 registers give a standard error of about
+// 0.81% in 16 KiB.
+const (
+	precision = 14
+	registers = 1 << precision
+)
+
+// Sketch is a HyperLogLog estimator of the number of distinct strings added
+// to it. Sketches merge by keeping the highest rank of each register.
+type Sketch struct {
+	registers []uint8
+}
+
+func New() *Sketch {
+	return &Sketch{registers: make([]uint8, registers)}
+}
+
+func (s *Sketch) Add(value string) {
+	hash := hashString(value)
+	index := hash >> (64 - precision)
+	// The sentinel bit caps the rank when the remaining bits are all zero.
+	rest := hash<<precision | 1<<(precision-1)
+	rank := uint8(bits.LeadingZeros64(rest) + 1)
+
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+func (s *Sketch) Count() uint64 {
+	sum := 0.0
+	zeros := 0
+	for _, rank := range s.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate while many registers are still empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// hashString spreads FNV-1a with the splitmix64 finalizer, since HyperLogLog
+// needs every output bit to be well mixed.
+func hashString(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,64 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// standardError is the documented error of precision 14, 1.04/sqrt(2^14).
+var standardError = 1.04 / math.Sqrt(registers)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+		repeats  int
+	}{
+		{"empty", 0, 1},
+		{"one", 1, 5},
+		{"1k", 1000, 1},
+		{"1k repeated", 1000, 3},
+		{"100k", 100000, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sketch := New()
+			for r := 0; r < tt.repeats; r++ {
+				for i := 0; i < tt.distinct; i++ {
+					sketch.Add("10.0." + strconv.Itoa(i))
+				}
+			}
+
+			got := float64(sketch.Count())
+			want := float64(tt.distinct)
+			// Three standard errors, plus one for the smallest sets.
+			if math.Abs(got-want) > 3*standardError*want+1 {
+				t.Fatalf("Count() = %v, want %v ± %.2f%%", got, want, 300*standardError)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, both := New(), New(), New()
+	for i := 0; i < 50000; i++ {
+		value := "192.168." + strconv.Itoa(i)
+		if i < 30000 {
+			a.Add(value)
+		}
+		if i >= 20000 {
+			b.Add(value)
+		}
+		both.Add(value)
+	}
+
+	a.Merge(b)
+	if a.Count() != both.Count() {
+		t.Fatalf("merged count %d, want the union's %d", a.Count(), both.Count())
+	}
+	if got := float64(a.Count()); math.Abs(got-50000) > 3*standardError*50000 {
+		t.Fatalf("merged count %v is off the 50000 distinct values", got)
+	}
+}
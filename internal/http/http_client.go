@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"time"
@@ -26,3 +27,16 @@ func (hc *DefaultHttpClient) Get(ctx context.Context, url string) (*http.Respons
 
 	return hc.client.Do(req)
 }
+
+func (hc *DefaultHttpClient) Post(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return hc.client.Do(req)
+}
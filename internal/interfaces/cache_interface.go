@@ -2,11 +2,36 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrCacheMiss is returned by Get when the key does not exist, so callers can
+// tell a miss from a cache failure.
+var ErrCacheMiss = errors.New("cache miss")
+
 type Cache interface {
 	Exists(ctx context.Context, key string) (int64, error)
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) ([]byte, error)
+	// GetSet stores value and returns the previous value of key, or
+	// ErrCacheMiss when it had none.
+	GetSet(ctx context.Context, key string, value interface{}) ([]byte, error)
+	Del(ctx context.Context, keys ...string) error
+	Keys(ctx context.Context, pattern string) ([]string, error)
+	Expire(ctx context.Context, key string, expiration time.Duration) error
+
+	HIncrByFloat(ctx context.Context, key, field string, increment float64) (float64, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	ZIncrBy(ctx context.Context, key string, increment float64, member string) (float64, error)
+	ZRangeWithScores(ctx context.Context, key string) ([]ScoredMember, error)
+	PFAdd(ctx context.Context, key string, elements ...interface{}) error
+	PFCount(ctx context.Context, keys ...string) (int64, error)
+	PFMerge(ctx context.Context, dest string, keys ...string) error
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+type ScoredMember struct {
+	Member string
+	Score  float64
 }
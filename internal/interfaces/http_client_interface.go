@@ -7,4 +7,5 @@ import (
 
 type Client interface {
 	Get(ctx context.Context, url string) (*http.Response, error)
+	Post(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error)
 }
@@ -0,0 +1,23 @@
+package money
+
+import "strings"
+
+var minorUnitExceptions = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the ISO 4217 exponent of a currency, defaulting to 2.
+func MinorUnits(currency string) int {
+	if units, exists := minorUnitExceptions[strings.ToUpper(currency)]; exists {
+		return units
+	}
+	return 2
+}
+
+// RoundToCurrency rounds an amount to the minor unit of the given currency.
+func RoundToCurrency(amount Decimal, currency string) Decimal {
+	return amount.Round(MinorUnits(currency))
+}
@@ -0,0 +1,145 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+type Decimal struct {
+	rat *big.Rat
+}
+
+var One = Decimal{rat: big.NewRat(1, 1)}
+
+// maxDecimalDigits bounds the digits NewDecimal accepts, so that amounts
+// read from requests stay cheap to compute with and to print.
+const maxDecimalDigits = 32
+
+// decimalPattern only accepts plain decimals: no exponent, fraction or
+// hexadecimal, octal and binary literals.
+var decimalPattern = regexp.MustCompile(`^[-+]?(\d+(\.\d*)?|\.\d+)$`)
+
+// NewDecimal parses a plain decimal such as "12", "-0.5" or "1234.5678" with
+// at most maxDecimalDigits digits.
+func NewDecimal(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	if !decimalPattern.MatchString(value) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+
+	digits := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits > maxDecimalDigits {
+		return Decimal{}, fmt.Errorf("%w: %q has more than %d digits", ErrInvalidDecimal, value, maxDecimalDigits)
+	}
+
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+// NewDecimalFromFloat converts the shortest decimal representation of value,
+// so that 0.1 stays 0.1. It is meant for trusted values such as rates and is
+// not bound by maxDecimalDigits.
+func NewDecimalFromFloat(value float64) (Decimal, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Decimal{}, fmt.Errorf("%w: %v", ErrInvalidDecimal, value)
+	}
+	rat, ok := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %v", ErrInvalidDecimal, value)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+func (d Decimal) value() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Mul(d.value(), other.value())}
+}
+
+func (d Decimal) Div(other Decimal) (Decimal, error) {
+	if other.value().Sign() == 0 {
+		return Decimal{}, fmt.Errorf("%w: division by zero", ErrInvalidDecimal)
+	}
+	return Decimal{rat: new(big.Rat).Quo(d.value(), other.value())}, nil
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Add(d.value(), other.value())}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Sub(d.value(), other.value())}
+}
+
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	return d.value().Cmp(other.value())
+}
+
+// Round rounds half away from zero to the given number of decimal places.
+func (d Decimal) Round(places int) Decimal {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(d.value(), new(big.Rat).SetInt(scale))
+
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	doubled := new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2))
+	if doubled.Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(scaled.Sign())))
+	}
+
+	return Decimal{rat: new(big.Rat).SetFrac(quotient, scale)}
+}
+
+func (d Decimal) StringFixed(places int) string {
+	return d.Round(places).value().FloatString(places)
+}
+
+func (d Decimal) String() string {
+	s := d.value().FloatString(12)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := d.value().Float64()
+	return f
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	parsed, err := NewDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
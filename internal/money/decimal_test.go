@@ -0,0 +1,193 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustDecimal(t *testing.T, value string) Decimal {
+	t.Helper()
+	d, err := NewDecimal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestNewDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "12", want: "12"},
+		{value: " 12.50 ", want: "12.5"},
+		{value: "-0.5", want: "-0.5"},
+		{value: "+3", want: "3"},
+		{value: ".25", want: "0.25"},
+		{value: "7.", want: "7"},
+		{value: "0.000000000001", want: "0.000000000001"},
+		{value: strings.Repeat("9", maxDecimalDigits), want: strings.Repeat("9", maxDecimalDigits)},
+		{value: strings.Repeat("9", maxDecimalDigits+1), wantErr: true},
+		{value: "1e1000000", wantErr: true},
+		{value: "1E3", wantErr: true},
+		{value: "0x10", wantErr: true},
+		{value: "0b101", wantErr: true},
+		{value: "0o17", wantErr: true},
+		{value: "1/3", wantErr: true},
+		{value: "1_000", wantErr: true},
+		{value: "1,5", wantErr: true},
+		{value: "--1", wantErr: true},
+		{value: ".", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := NewDecimal(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDecimal) {
+					t.Fatalf("expected ErrInvalidDecimal, got %v (%s)", err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("NewDecimal(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDecimalFromFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0.1, "0.1"},
+		{1.10, "1.1"},
+		{890.5, "890.5"},
+		{1e-7, "0.0000001"},
+		{1e20, "100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		got, err := NewDecimalFromFloat(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("NewDecimalFromFloat(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		value  string
+		places int
+		want   string
+	}{
+		{"1.005", 2, "1.01"},
+		{"1.004", 2, "1"},
+		{"-1.005", 2, "-1.01"},
+		{"-1.004", 2, "-1"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"0.0005", 3, "0.001"},
+		{"123.456", 1, "123.5"},
+		{"0", 2, "0"},
+	}
+
+	for _, tt := range tests {
+		if got := mustDecimal(t, tt.value).Round(tt.places).String(); got != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.value, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	third, err := One.Div(mustDecimal(t, "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value Decimal
+		want  string
+		fixed string
+	}{
+		{"integer", mustDecimal(t, "100"), "100", "100.00"},
+		{"trailing zeros", mustDecimal(t, "1.2300"), "1.23", "1.23"},
+		{"negative", mustDecimal(t, "-0.5"), "-0.5", "-0.50"},
+		{"zero value", Decimal{}, "0", "0.00"},
+		{"repeating", third, "0.333333333333", "0.33"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.value.String(); got != tt.want {
+				t.Fatalf("String() = %s, want %s", got, tt.want)
+			}
+			if got := tt.value.StringFixed(2); got != tt.fixed {
+				t.Fatalf("StringFixed(2) = %s, want %s", got, tt.fixed)
+			}
+		})
+	}
+}
+
+func TestDecimalDiv(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    string
+		wantErr bool
+	}{
+		{a: "10", b: "4", want: "2.5"},
+		{a: "1", b: "8", want: "0.125"},
+		{a: "-9", b: "3", want: "-3"},
+		{a: "100", b: "0.1", want: "1000"},
+		{a: "1", b: "0", wantErr: true},
+		{a: "0", b: "0.00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := mustDecimal(t, tt.a).Div(mustDecimal(t, tt.b))
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidDecimal) {
+				t.Errorf("%s / %s: expected ErrInvalidDecimal, got %v", tt.a, tt.b, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s / %s = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var got struct{ Amount Decimal }
+	if err := json.Unmarshal([]byte(`{"Amount":"12.50"}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Amount":"12.5"}` {
+		t.Fatalf("got %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Amount":"1e1000000"}`), &got); !errors.Is(err, ErrInvalidDecimal) {
+		t.Fatalf("expected ErrInvalidDecimal, got %v", err)
+	}
+}
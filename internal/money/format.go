@@ -0,0 +1,159 @@
+package money
+
+import (
+	"sort"
+	"strings"
+)
+
+type LocaleFormat struct {
+	Locale         string
+	DecimalSep     string
+	GroupSep       string
+	SymbolFirst    bool
+	SymbolSpace    bool
+	IndianGrouping bool
+}
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+var defaultLocale = LocaleFormat{Locale: "en", DecimalSep: ".", GroupSep: ",", SymbolFirst: true}
+
+// localeFormats follows the CLDR standard currency patterns, keyed by the
+// ISO 639-3 language codes used by restcountries and optionally refined by
+// country.
+var localeFormats = map[string]LocaleFormat{
+	"eng":    defaultLocale,
+	"eng-IN": {Locale: "en-IN", DecimalSep: ".", GroupSep: ",", SymbolFirst: true, IndianGrouping: true},
+	"hin":    {Locale: "hi", DecimalSep: ".", GroupSep: ",", SymbolFirst: true, IndianGrouping: true},
+	"por":    {Locale: "pt", DecimalSep: ",", GroupSep: ".", SymbolFirst: true, SymbolSpace: true},
+	"por-PT": {Locale: "pt-PT", DecimalSep: ",", GroupSep: narrowNbsp, SymbolSpace: true},
+	"spa":    {Locale: "es", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"spa-AR": {Locale: "es-AR", DecimalSep: ",", GroupSep: ".", SymbolFirst: true, SymbolSpace: true},
+	"spa-CL": {Locale: "es-CL", DecimalSep: ",", GroupSep: ".", SymbolFirst: true},
+	"spa-CO": {Locale: "es-CO", DecimalSep: ",", GroupSep: ".", SymbolFirst: true, SymbolSpace: true},
+	"spa-MX": {Locale: "es-MX", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"spa-US": {Locale: "es-US", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"deu":    {Locale: "de", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"deu-CH": {Locale: "de-CH", DecimalSep: ".", GroupSep: "’", SymbolFirst: true, SymbolSpace: true},
+	"deu-AT": {Locale: "de-AT", DecimalSep: ",", GroupSep: nbsp, SymbolFirst: true, SymbolSpace: true},
+	"fra":    {Locale: "fr", DecimalSep: ",", GroupSep: narrowNbsp, SymbolSpace: true},
+	"fra-CH": {Locale: "fr-CH", DecimalSep: ",", GroupSep: narrowNbsp, SymbolSpace: true},
+	"ita":    {Locale: "it", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"nld":    {Locale: "nl", DecimalSep: ",", GroupSep: ".", SymbolFirst: true, SymbolSpace: true},
+	"rus":    {Locale: "ru", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"ukr":    {Locale: "uk", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"pol":    {Locale: "pl", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"ces":    {Locale: "cs", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"swe":    {Locale: "sv", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"nor":    {Locale: "nb", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"nob":    {Locale: "nb", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"dan":    {Locale: "da", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"fin":    {Locale: "fi", DecimalSep: ",", GroupSep: nbsp, SymbolSpace: true},
+	"tur":    {Locale: "tr", DecimalSep: ",", GroupSep: ".", SymbolFirst: true},
+	"jpn":    {Locale: "ja", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"kor":    {Locale: "ko", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"zho":    {Locale: "zh", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"ind":    {Locale: "id", DecimalSep: ",", GroupSep: ".", SymbolFirst: true},
+	"vie":    {Locale: "vi", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"tha":    {Locale: "th", DecimalSep: ".", GroupSep: ",", SymbolFirst: true},
+	"heb":    {Locale: "he", DecimalSep: ".", GroupSep: ",", SymbolSpace: true},
+	"ell":    {Locale: "el", DecimalSep: ",", GroupSep: ".", SymbolSpace: true},
+	"ara":    {Locale: "ar", DecimalSep: ".", GroupSep: ",", SymbolSpace: true},
+}
+
+// primaryLanguages picks the language used for formatting in countries where
+// restcountries lists more than one official language.
+var primaryLanguages = map[string]string{
+	"BE": "nld", "CA": "eng", "CH": "deu", "IN": "eng", "LU": "fra", "PY": "spa",
+	"BO": "spa", "PE": "spa", "SG": "eng", "ZA": "eng", "PH": "eng", "IE": "eng",
+	"FI": "fin", "IL": "heb", "PK": "eng", "NZ": "eng", "MT": "eng", "CY": "ell",
+}
+
+// LocaleForCountry resolves the currency format of a country from its
+// languages, falling back to English conventions.
+func LocaleForCountry(countryCode string, languages map[string]string) LocaleFormat {
+	countryCode = strings.ToUpper(countryCode)
+
+	language, exists := primaryLanguages[countryCode]
+	if !exists {
+		codes := make([]string, 0, len(languages))
+		for code := range languages {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			if _, known := localeFormats[code]; known {
+				language = code
+				break
+			}
+		}
+	}
+
+	if format, exists := localeFormats[language+"-"+countryCode]; exists {
+		return format
+	}
+	if format, exists := localeFormats[language]; exists {
+		return format
+	}
+	return defaultLocale
+}
+
+// Format renders an amount with the currency symbol using the locale's
+// separators and symbol placement.
+func (lf LocaleFormat) Format(amount Decimal, symbol, currency string) string {
+	if symbol == "" {
+		symbol = currency
+	}
+
+	digits := amount.StringFixed(MinorUnits(currency))
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	integer, fraction, _ := strings.Cut(digits, ".")
+	number := lf.group(integer)
+	if fraction != "" {
+		number += lf.DecimalSep + fraction
+	}
+
+	separator := ""
+	if lf.SymbolSpace {
+		separator = nbsp
+	}
+
+	var formatted string
+	if lf.SymbolFirst {
+		formatted = symbol + separator + number
+	} else {
+		formatted = number + separator + symbol
+	}
+
+	if negative {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+func (lf LocaleFormat) group(integer string) string {
+	if len(integer) <= 3 {
+		return integer
+	}
+
+	head, tail := integer[:len(integer)-3], integer[len(integer)-3:]
+	size := 3
+	if lf.IndianGrouping {
+		size = 2
+	}
+
+	var groups []string
+	for len(head) > size {
+		groups = append([]string{head[len(head)-size:]}, groups...)
+		head = head[:len(head)-size]
+	}
+	groups = append([]string{head}, groups...)
+
+	return strings.Join(append(groups, tail), lf.GroupSep)
+}
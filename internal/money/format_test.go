@@ -0,0 +1,36 @@
+package money
+
+import "testing"
+
+func TestLocaleFormat(t *testing.T) {
+	tests := []struct {
+		country   string
+		languages map[string]string
+		amount    string
+		symbol    string
+		currency  string
+		want      string
+	}{
+		{"US", map[string]string{"eng": "English"}, "1234.5", "$", "USD", "$1,234.50"},
+		{"BR", map[string]string{"por": "Portuguese"}, "49.9", "R$", "BRL", "R$" + nbsp + "49,90"},
+		{"DE", map[string]string{"deu": "German"}, "1234567.891", "€", "EUR", "1.234.567,89" + nbsp + "€"},
+		{"IN", map[string]string{"eng": "English", "hin": "Hindi"}, "12345678", "₹", "INR", "₹1,23,45,678.00"},
+		{"JP", map[string]string{"jpn": "Japanese"}, "1234.5", "¥", "JPY", "¥1,235"},
+		{"AR", map[string]string{"spa": "Spanish", "grn": "Guaraní"}, "-1500", "$", "ARS", "-$" + nbsp + "1.500,00"},
+		{"XX", nil, "10", "", "XXX", "XXX10.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			amount, err := NewDecimal(tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := LocaleForCountry(tt.country, tt.languages).Format(amount, tt.symbol, tt.currency)
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
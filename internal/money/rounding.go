@@ -0,0 +1,78 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrUnknownRounding = errors.New("unknown rounding rule")
+
+const (
+	RoundingNone        = "none"
+	RoundingCharm       = "charm"
+	RoundingNearestHalf = "nearest_0.5"
+	RoundingNearest5    = "nearest_5"
+	RoundingNearest10   = "nearest_10"
+)
+
+// ApplyRounding adjusts a converted price to a presentation rule. Charm
+// pricing rounds up to the next whole amount (next ten for currencies
+// without minor units) and subtracts one minor unit, e.g. 47.20 -> 47.99
+// and 47.00 -> 47.99, so the result is never below the price. Amounts of
+// zero or less are only rounded to the currency.
+func ApplyRounding(amount Decimal, rule, currency string) (Decimal, error) {
+	minorUnits := MinorUnits(currency)
+
+	switch rule {
+	case "", RoundingNone:
+		return RoundToCurrency(amount, currency), nil
+	case RoundingCharm:
+		rounded := RoundToCurrency(amount, currency)
+		if rounded.Sign() <= 0 {
+			return rounded, nil
+		}
+
+		step := int64(1)
+		if minorUnits == 0 {
+			step = 10
+		}
+		minorUnit := Decimal{rat: new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(minorUnits)), nil))}
+		ceiled := ceilToStep(rounded.Add(minorUnit), Decimal{rat: big.NewRat(step, 1)})
+		return ceiled.Sub(minorUnit), nil
+	case RoundingNearestHalf:
+		return roundToStep(amount, currencyStep(big.NewRat(1, 2), minorUnits)), nil
+	case RoundingNearest5:
+		return roundToStep(amount, currencyStep(big.NewRat(5, 1), minorUnits)), nil
+	case RoundingNearest10:
+		return roundToStep(amount, currencyStep(big.NewRat(10, 1), minorUnits)), nil
+	default:
+		return Decimal{}, fmt.Errorf("%w: %s", ErrUnknownRounding, rule)
+	}
+}
+
+// currencyStep clamps a rounding step to the currency's minor unit, so that
+// nearest_0.5 rounds JPY or CLP to whole units instead of to amounts the
+// currency cannot represent.
+func currencyStep(step *big.Rat, minorUnits int) Decimal {
+	minorUnit := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(minorUnits)), nil))
+	if step.Cmp(minorUnit) < 0 {
+		step = minorUnit
+	}
+	return Decimal{rat: step}
+}
+
+func roundToStep(amount, step Decimal) Decimal {
+	steps, _ := amount.Div(step)
+	return steps.Round(0).Mul(step)
+}
+
+func ceilToStep(amount, step Decimal) Decimal {
+	steps, _ := amount.Div(step)
+	value := steps.value()
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	return Decimal{rat: new(big.Rat).SetInt(quotient)}.Mul(step)
+}
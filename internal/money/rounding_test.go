@@ -0,0 +1,85 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyRounding(t *testing.T) {
+	tests := []struct {
+		amount   string
+		rule     string
+		currency string
+		want     string
+	}{
+		{"47.20", RoundingCharm, "USD", "47.99"},
+		{"47", RoundingCharm, "USD", "47.99"},
+		{"46.99", RoundingCharm, "USD", "46.99"},
+		{"46.994", RoundingCharm, "USD", "46.99"},
+		{"0.30", RoundingCharm, "EUR", "0.99"},
+		{"0", RoundingCharm, "USD", "0"},
+		{"-3.50", RoundingCharm, "USD", "-3.5"},
+		{"1234", RoundingCharm, "JPY", "1239"},
+		{"1240", RoundingCharm, "JPY", "1249"},
+		{"1239.4", RoundingCharm, "JPY", "1239"},
+		{"0", RoundingCharm, "JPY", "0"},
+		{"12.3456", RoundingCharm, "KWD", "12.999"},
+		{"12.345", RoundingNone, "USD", "12.35"},
+		{"12.345", "", "JPY", "12"},
+		{"12.30", RoundingNearestHalf, "USD", "12.5"},
+		{"12.20", RoundingNearestHalf, "USD", "12"},
+		{"1234.4", RoundingNearestHalf, "JPY", "1234"},
+		{"1234.6", RoundingNearestHalf, "JPY", "1235"},
+		{"990.5", RoundingNearestHalf, "CLP", "991"},
+		{"12.26", RoundingNearestHalf, "KWD", "12.5"},
+		{"12.5", RoundingNearest5, "USD", "15"},
+		{"1234", RoundingNearest10, "JPY", "1230"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.currency+"/"+tt.amount, func(t *testing.T) {
+			amount, err := NewDecimal(tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ApplyRounding(amount, tt.rule, tt.currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("ApplyRounding(%s, %q, %s) = %s, want %s", tt.amount, tt.rule, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRoundingUnknownRule(t *testing.T) {
+	if _, err := ApplyRounding(One, "nearest_3", "USD"); !errors.Is(err, ErrUnknownRounding) {
+		t.Fatalf("expected ErrUnknownRounding, got %v", err)
+	}
+}
+
+func TestRoundToCurrency(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"1.005", "USD", "1.01"},
+		{"-1.005", "usd", "-1.01"},
+		{"1.5", "JPY", "2"},
+		{"1.0005", "BHD", "1.001"},
+		{"0", "EUR", "0"},
+	}
+
+	for _, tt := range tests {
+		amount, err := NewDecimal(tt.amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := RoundToCurrency(amount, tt.currency).String(); got != tt.want {
+			t.Errorf("RoundToCurrency(%s, %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import "math"
+
+const (
+	CoordinateSourceCity    = "city"
+	CoordinateSourceCountry = "country"
+	CoordinateSourceInput   = "input"
+)
+
+type Coordinates struct {
+	Latitude         float64
+	Longitude        float64
+	Source           string
+	AccuracyRadiusKm float64
+}
+
+func (c Coordinates) valid() bool {
+	return c.Source != ""
+}
+
+func (c Coordinates) latLng() []float64 {
+	if !c.valid() {
+		return nil
+	}
+	return []float64{c.Latitude, c.Longitude}
+}
+
+// lookupCoordinates prefers the city-level coordinates of the GeoIP record,
+// together with its accuracy radius, and falls back to the country centroid
+// from restcountries.
+func lookupCoordinates(info *CountryInfo, countryLatLng []float64) Coordinates {
+	if info != nil && info.HasLocation {
+		return Coordinates{
+			Latitude:         info.Latitude,
+			Longitude:        info.Longitude,
+			Source:           CoordinateSourceCity,
+			AccuracyRadiusKm: float64(info.AccuracyRadius),
+		}
+	}
+	if len(countryLatLng) >= 2 {
+		return Coordinates{Latitude: countryLatLng[0], Longitude: countryLatLng[1], Source: CoordinateSourceCountry}
+	}
+	return Coordinates{}
+}
+
+// distanceBand widens a point distance by the accuracy radius of the looked-up
+// coordinates: the IP can be anywhere within that circle.
+func distanceBand(distance, accuracyRadiusKm float64) (float64, float64) {
+	return math.Max(distance-accuracyRadiusKm, 0), distance + accuracyRadiusKm
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrCurrencyNotFound = errors.New("currency not found in rate table")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+type Conversion struct {
+	From      string
+	To        string
+	Amount    money.Decimal
+	Result    money.Decimal
+	Rate      float64
+	RatesInfo RatesInfo
+}
+
+type RatesInfo struct {
+	Provider  string
+	Date      string
+	Timestamp time.Time
+	Age       time.Duration
+}
+
+func newRatesInfo(ratesData RatesData) RatesInfo {
+	timestamp := time.Unix(ratesData.Timestamp, 0).UTC()
+	return RatesInfo{
+		Provider:  ratesData.Provider,
+		Date:      ratesData.Date,
+		Timestamp: timestamp,
+		Age:       time.Since(timestamp).Truncate(time.Second),
+	}
+}
+
+func ConvertCurrency(redisCache interfaces.Cache, from, to string, amount money.Decimal, date string) (*Conversion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	ratesData, err := getRatesData(ctx, redisCache, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertWithRates(ratesData, from, to, amount)
+}
+
+func ConvertToCurrencies(redisCache interfaces.Cache, from string, targets []string, amount money.Decimal, date string) ([]Conversion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	ratesData, err := getRatesData(ctx, redisCache, date)
+	if err != nil {
+		return nil, err
+	}
+
+	conversions := make([]Conversion, 0, len(targets))
+	for _, target := range targets {
+		conversion, err := convertWithRates(ratesData, from, target, amount)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, *conversion)
+	}
+
+	return conversions, nil
+}
+
+func convertWithRates(ratesData RatesData, from, to string, amount money.Decimal) (*Conversion, error) {
+	if amount.Sign() < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAmount, amount)
+	}
+
+	from = strings.ToUpper(strings.TrimSpace(from))
+	to = strings.ToUpper(strings.TrimSpace(to))
+
+	fromRate, err := lookupRate(ratesData, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toRate, err := lookupRate(ratesData, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := toRate.Div(fromRate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCurrencyNotFound, from)
+	}
+
+	return &Conversion{
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		Result:    money.RoundToCurrency(amount.Mul(rate), to),
+		Rate:      rate.Float64(),
+		RatesInfo: newRatesInfo(ratesData),
+	}, nil
+}
+
+func lookupRate(ratesData RatesData, currency string) (money.Decimal, error) {
+	rate, exists := ratesData.Rates[currency]
+	if !exists {
+		return money.Decimal{}, fmt.Errorf("%w: %s", ErrCurrencyNotFound, currency)
+	}
+
+	decimalRate, err := money.NewDecimalFromFloat(rate)
+	if err != nil || decimalRate.Sign() <= 0 {
+		return money.Decimal{}, fmt.Errorf("%w: %s has an invalid rate", ErrCurrencyNotFound, currency)
+	}
+
+	return decimalRate, nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/astro"
+)
+
+func applyDaylight(details *IPLocationDetails) {
+	coordinates := details.Coordinates
+	if !coordinates.valid() {
+		details.Sun = nil
+		return
+	}
+
+	location := time.UTC
+	if details.TimeZone != "" {
+		if loaded, err := time.LoadLocation(details.TimeZone); err == nil {
+			location = loaded
+		}
+	}
+
+	sun := astro.Compute(time.Now(), coordinates.Latitude, coordinates.Longitude, location)
+	details.Sun = &sun
+}
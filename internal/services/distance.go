@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/geodesy"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidDistanceRequest = errors.New("invalid distance request")
+
+const (
+	DistanceMethodVincenty  = "vincenty"
+	DistanceMethodSpherical = "spherical"
+)
+
+type DistanceRequest struct {
+	From string
+	To   string
+	Unit string
+}
+
+type DistanceEndpoint struct {
+	Input       string
+	CountryCode string
+	CountryName string
+	City        string
+	Coordinates Coordinates
+}
+
+type DistanceResult struct {
+	From           DistanceEndpoint
+	To             DistanceEndpoint
+	Unit           geodesy.Unit
+	Method         string
+	Distance       float64
+	MinDistance    float64
+	MaxDistance    float64
+	InitialBearing float64
+	FinalBearing   float64
+	InitialCompass string
+	FinalCompass   string
+	Midpoint       geodesy.Point
+}
+
+// CalculateDistanceBetween measures the WGS84 geodesic between two endpoints,
+// each given as an IP address or a "lat,lng" pair.
+func CalculateDistanceBetween(redisCache interfaces.Cache, httpClient interfaces.Client, request DistanceRequest) (*DistanceResult, error) {
+	unit, err := geodesy.ParseUnit(request.Unit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDistanceRequest, err)
+	}
+
+	if request.From == "" || request.To == "" {
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalidDistanceRequest)
+	}
+
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	from, err := resolveDistanceEndpoint(ctx, redisCache, ipLocation, request.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := resolveDistanceEndpoint(ctx, redisCache, ipLocation, request.To)
+	if err != nil {
+		return nil, err
+	}
+
+	fromPoint := geodesy.Point{Latitude: from.Coordinates.Latitude, Longitude: from.Coordinates.Longitude}
+	toPoint := geodesy.Point{Latitude: to.Coordinates.Latitude, Longitude: to.Coordinates.Longitude}
+	inverse := geodesy.Distance(fromPoint, toPoint)
+
+	method := DistanceMethodVincenty
+	if !inverse.Converged {
+		method = DistanceMethodSpherical
+	}
+
+	distanceKm := geodesy.Kilometers.FromMeters(inverse.DistanceMeters)
+	minKm, maxKm := distanceBand(distanceKm, from.Coordinates.AccuracyRadiusKm+to.Coordinates.AccuracyRadiusKm)
+
+	return &DistanceResult{
+		From:           from,
+		To:             to,
+		Unit:           unit,
+		Method:         method,
+		Distance:       unit.FromMeters(inverse.DistanceMeters),
+		MinDistance:    unit.FromMeters(minKm * 1000),
+		MaxDistance:    unit.FromMeters(maxKm * 1000),
+		InitialBearing: inverse.InitialBearing,
+		FinalBearing:   inverse.FinalBearing,
+		InitialCompass: geodesy.Compass(inverse.InitialBearing),
+		FinalCompass:   geodesy.Compass(inverse.FinalBearing),
+		Midpoint:       geodesy.Midpoint(fromPoint, toPoint),
+	}, nil
+}
+
+func resolveDistanceEndpoint(ctx context.Context, cache interfaces.Cache, ipLocation *IPLocation, value string) (DistanceEndpoint, error) {
+	value = strings.TrimSpace(value)
+	endpoint := DistanceEndpoint{Input: value}
+
+	if lat, lng, err := ParseLatLng(value); err == nil {
+		endpoint.Coordinates = Coordinates{Latitude: lat, Longitude: lng, Source: CoordinateSourceInput}
+		return endpoint, nil
+	}
+
+	if net.ParseIP(value) == nil {
+		return DistanceEndpoint{}, fmt.Errorf("%w: %q is neither an IP address nor a lat,lng pair", ErrInvalidDistanceRequest, value)
+	}
+
+	info, err := ipLocation.GetIPLocation(ctx, value)
+	if err != nil {
+		return DistanceEndpoint{}, fmt.Errorf("error getting IP location for %s: %w", value, err)
+	}
+
+	var countryLatLng []float64
+	if !info.HasLocation {
+		country, err := getCountryFromCache(ctx, cache, info.IsoCode)
+		if err != nil {
+			return DistanceEndpoint{}, err
+		}
+		countryLatLng = country.LatLng
+	}
+
+	endpoint.CountryCode = info.IsoCode
+	endpoint.CountryName = info.Name
+	endpoint.City = info.City
+	endpoint.Coordinates = lookupCoordinates(info, countryLatLng)
+	if !endpoint.Coordinates.valid() {
+		return DistanceEndpoint{}, fmt.Errorf("no coordinates known for %s", value)
+	}
+
+	return endpoint, nil
+}
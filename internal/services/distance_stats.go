@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/histogram"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+)
+
+const (
+	distanceStatsKey         = "stats:distance"
+	countryDistanceStatsKey  = "stats:distance:country_distance"
+	countryRequestsStatsKey  = "stats:distance:country_requests"
+	legacyDistanceStatsKey   = "distance_stats"
+	migratedDistanceStatsKey = "distance_stats:migrated"
+	distanceHistogramKey     = "stats:distance:histogram"
+	countryHistogramPrefix   = "stats:distance:histogram:country:"
+)
+
+// recordScriptPrelude starts the script that applies one lookup to every
+// statistic in a single atomic step, so concurrent requests never lose
+// updates and the totals, per-country sets, histograms and buckets always
+// agree with each other. Each statsRecordPart follows in its own block and
+// takes its keys and arguments in order with nextKey() and nextArg(), after
+// the distance and country shared by all of them. The script returns the
+// keys that parts could not update, e.g. PFADD on servers without the
+// HyperLogLog commands, so they can be handled by the part's fallback.
+const recordScriptPrelude = `
+local keyIndex, argIndex = 0, 0
+local function nextKey()
+	keyIndex = keyIndex + 1
+	return KEYS[keyIndex]
+end
+local function nextArg()
+	argIndex = argIndex + 1
+	return ARGV[argIndex]
+end
+
+local distance, country = nextArg(), nextArg()
+local failed = {}
+
+local function totals(key)
+	redis.call('HINCRBYFLOAT', key, 'total_distance', distance)
+	redis.call('HINCRBYFLOAT', key, 'total_requests', 1)
+	local value = tonumber(distance)
+	local farthest = tonumber(redis.call('HGET', key, 'farthest_distance'))
+	if not farthest or value > farthest then
+		redis.call('HSET', key, 'farthest_distance', distance, 'farthest_country', country)
+	end
+	local closest = tonumber(redis.call('HGET', key, 'closest_distance'))
+	if not closest or value < closest then
+		redis.call('HSET', key, 'closest_distance', distance, 'closest_country', country)
+	end
+end
+`
+
+// statsLookup is the lookup being recorded.
+type statsLookup struct {
+	ip       string
+	visitor  string
+	country  string
+	distance float64
+	buckets  []activeStatsBucket
+}
+
+// statsRecordPart is one family of statistics updated by the record script.
+// args returns its keys and arguments for a lookup, in the order its lua
+// block reads them. fallback, when set, gets the part's keys that failed, or
+// all of them when the script could not run.
+type statsRecordPart struct {
+	lua      string
+	args     func(lookup statsLookup) ([]string, []interface{})
+	fallback func(lookup statsLookup, keys []string, err error)
+}
+
+// statsRecordParts are applied in this order by every recorded lookup.
+var statsRecordParts = []statsRecordPart{
+	distanceTotalsPart,
+	distanceHistogramPart,
+	uniqueVisitorsPart,
+	statsBucketsPart,
+	leaderboardsPart,
+}
+
+var recordDistanceScript = buildRecordScript(statsRecordParts)
+
+func buildRecordScript(parts []statsRecordPart) string {
+	var script strings.Builder
+	script.WriteString(recordScriptPrelude)
+	for _, part := range parts {
+		script.WriteString("do\n" + part.lua + "end\n")
+	}
+	script.WriteString("return failed\n")
+	return script.String()
+}
+
+// distanceTotalsPart keeps the all-time totals and the per-country distance
+// and request sets.
+var distanceTotalsPart = statsRecordPart{
+	lua: `
+totals(nextKey())
+redis.call('ZINCRBY', nextKey(), distance, country)
+redis.call('ZINCRBY', nextKey(), 1, country)
+`,
+	args: func(lookup statsLookup) ([]string, []interface{}) {
+		return []string{distanceStatsKey, countryDistanceStatsKey, countryRequestsStatsKey}, nil
+	},
+}
+
+// distanceHistogramPart counts the lookup in the overall and country
+// histograms.
+var distanceHistogramPart = statsRecordPart{
+	lua: `
+local bucket = nextArg()
+redis.call('HINCRBYFLOAT', nextKey(), bucket, 1)
+redis.call('HINCRBYFLOAT', nextKey(), bucket, 1)
+`,
+	args: func(lookup statsLookup) ([]string, []interface{}) {
+		return []string{distanceHistogramKey, countryHistogramPrefix + lookup.country},
+			[]interface{}{histogram.BucketIndex(lookup.distance)}
+	},
+}
+
+// migrateDistanceStatsScript merges the legacy distance_stats JSON blob into
+// the hash and sorted sets and keeps the blob under a backup key that never
+// expires. The backup key marks the migration as done, so lookups recorded
+// before a failed migration are added to rather than replaced. Numbers are
+// written with 17 significant digits because tostring keeps only 14.
+const migrateDistanceStatsScript = `
+if redis.call('EXISTS', KEYS[5]) == 1 then
+	return 0
+end
+local blob = redis.call('GET', KEYS[4])
+if not blob then
+	return 0
+end
+local function number(value)
+	return string.format('%.17g', value or 0)
+end
+local stats = cjson.decode(blob)
+redis.call('HINCRBYFLOAT', KEYS[1], 'total_distance', number(stats.TotalDistance))
+redis.call('HINCRBYFLOAT', KEYS[1], 'total_requests', number(stats.TotalRequests))
+if (stats.TotalRequests or 0) > 0 then
+	local farthest = tonumber(redis.call('HGET', KEYS[1], 'farthest_distance'))
+	if not farthest or (stats.FarthestDistance or 0) > farthest then
+		redis.call('HSET', KEYS[1], 'farthest_distance', number(stats.FarthestDistance), 'farthest_country', stats.FarthestCountryName)
+	end
+	local closest = tonumber(redis.call('HGET', KEYS[1], 'closest_distance'))
+	if not closest or (stats.ClosestDistance or 0) < closest then
+		redis.call('HSET', KEYS[1], 'closest_distance', number(stats.ClosestDistance), 'closest_country', stats.ClosestCountryName)
+	end
+end
+if type(stats.CountryDistances) == 'table' then
+	for country, data in pairs(stats.CountryDistances) do
+		redis.call('ZINCRBY', KEYS[2], number(data.TotalDistance), country)
+		redis.call('ZINCRBY', KEYS[3], number(data.Requests), country)
+	end
+end
+redis.call('RENAME', KEYS[4], KEYS[5])
+redis.call('PERSIST', KEYS[5])
+return 1
+`
+
+type DistanceStats struct {
+	FarthestDistance    float64
+	ClosestDistance     float64
+	TotalDistance       float64
+	TotalRequests       int
+	UniqueVisitors      int
+	FarthestCountryName string
+	ClosestCountryName  string
+	CountryDistances    map[string]CountryDistance
+	Histogram           *histogram.Histogram
+}
+
+type CountryDistance struct {
+	TotalDistance  float64
+	Requests       int
+	UniqueVisitors int
+	Histogram      *histogram.Histogram
+}
+
+func updateDistanceStats(ctx context.Context, cache interfaces.Cache, ip string, latLng []float64, countryName string) {
+	if len(latLng) < 2 {
+		fmt.Println("Error: latLng does not contain valid coordinates")
+		return
+	}
+
+	buenosAiresLat, buenosAiresLng, err := getBuenosAiresLatLng()
+	if err != nil {
+		fmt.Printf("Error getting Buenos Aires coordinates: %v\n", err)
+		return
+	}
+
+	distance := calculateDistance(buenosAiresLat, buenosAiresLng, latLng[0], latLng[1])
+	if err := recordDistance(ctx, cache, ip, distance, countryName); err != nil {
+		fmt.Printf("Error updating distance stats: %v\n", err)
+	}
+}
+
+func recordDistance(ctx context.Context, cache interfaces.Cache, ip string, distance float64, countryName string) error {
+	lookup := statsLookup{ip: ip, visitor: visitorID(ip), country: countryName, distance: distance, buckets: activeStatsBuckets(time.Now())}
+
+	keys := []string{}
+	args := []interface{}{strconv.FormatFloat(distance, 'f', -1, 64), countryName}
+	partKeys := make([][]string, len(statsRecordParts))
+	for i, part := range statsRecordParts {
+		var partArgs []interface{}
+		partKeys[i], partArgs = part.args(lookup)
+		keys = append(keys, partKeys[i]...)
+		args = append(args, partArgs...)
+	}
+
+	result, err := cache.Eval(ctx, recordDistanceScript, keys, args...)
+	if err != nil {
+		// Nothing was recorded in Redis, but parts with a fallback can
+		// still keep their statistics in this process.
+		for i, part := range statsRecordParts {
+			if part.fallback != nil {
+				part.fallback(lookup, partKeys[i], err)
+			}
+		}
+		return err
+	}
+
+	reply, _ := result.([]interface{})
+	if len(reply) == 0 {
+		return nil
+	}
+	failed := make(map[string]bool, len(reply))
+	for _, key := range reply {
+		if key, ok := key.(string); ok {
+			failed[key] = true
+		}
+	}
+	for i, part := range statsRecordParts {
+		if part.fallback == nil {
+			continue
+		}
+		var partFailed []string
+		for _, key := range partKeys[i] {
+			if failed[key] {
+				partFailed = append(partFailed, key)
+			}
+		}
+		if len(partFailed) > 0 {
+			part.fallback(lookup, partFailed, fmt.Errorf("the record script could not update %v", partFailed))
+		}
+	}
+	return nil
+}
+
+func GetDistanceStatsFromCache(ctx context.Context, cache interfaces.Cache) (*DistanceStats, error) {
+	fields, err := cache.HGetAll(ctx, distanceStatsKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading distance stats: %w", err)
+	}
+
+	stats := &DistanceStats{
+		TotalDistance:       parseStatFloat(fields["total_distance"]),
+		TotalRequests:       int(parseStatFloat(fields["total_requests"])),
+		FarthestDistance:    parseStatFloat(fields["farthest_distance"]),
+		FarthestCountryName: fields["farthest_country"],
+		ClosestDistance:     parseStatFloat(fields["closest_distance"]),
+		ClosestCountryName:  fields["closest_country"],
+		CountryDistances:    make(map[string]CountryDistance),
+		UniqueVisitors:      countUniqueVisitors(ctx, cache, uniqueVisitorsKey),
+	}
+
+	distances, err := cache.ZRangeWithScores(ctx, countryDistanceStatsKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading country distance stats: %w", err)
+	}
+	requests, err := cache.ZRangeWithScores(ctx, countryRequestsStatsKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading country request stats: %w", err)
+	}
+
+	for _, member := range distances {
+		country := stats.CountryDistances[member.Member]
+		country.TotalDistance = member.Score
+		stats.CountryDistances[member.Member] = country
+	}
+	for _, member := range requests {
+		country := stats.CountryDistances[member.Member]
+		country.Requests = int(member.Score)
+		stats.CountryDistances[member.Member] = country
+	}
+
+	if stats.Histogram, err = getDistanceHistogram(ctx, cache, distanceHistogramKey); err != nil {
+		return nil, err
+	}
+	if err := readCountryStats(ctx, cache, stats.CountryDistances); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// readCountryStatsScript returns, for each pair of histogram and unique
+// visitors keys, the histogram hash and the visitors estimate, or -1 when
+// PFCOUNT fails, so that all countries are read in one round trip.
+const readCountryStatsScript = `
+local result = {}
+for i = 1, #KEYS, 2 do
+	local unique = redis.pcall('PFCOUNT', KEYS[i + 1])
+	if type(unique) == 'table' and unique.err then
+		unique = -1
+	end
+	result[#result + 1] = {redis.call('HGETALL', KEYS[i]), unique}
+end
+return result
+`
+
+func readCountryStats(ctx context.Context, cache interfaces.Cache, countries map[string]CountryDistance) error {
+	if len(countries) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(countries))
+	keys := make([]string, 0, 2*len(countries))
+	for name := range countries {
+		names = append(names, name)
+		keys = append(keys, countryHistogramPrefix+name, countryUniqueVisitorsKey(name))
+	}
+
+	result, err := cache.Eval(ctx, readCountryStatsScript, keys)
+	if err != nil {
+		return fmt.Errorf("error reading country histograms: %w", err)
+	}
+	rows, ok := result.([]interface{})
+	if !ok || len(rows) != len(names) {
+		return fmt.Errorf("unexpected country histograms reply %T", result)
+	}
+
+	for i, name := range names {
+		row, _ := rows[i].([]interface{})
+		if len(row) != 2 {
+			return fmt.Errorf("unexpected histogram reply for %s", name)
+		}
+
+		country := countries[name]
+		country.Histogram = histogramFromFields(hashFromReply(row[0]))
+		if unique, _ := row[1].(int64); unique >= 0 {
+			country.UniqueVisitors = int(unique)
+		} else {
+			country.UniqueVisitors = countLocalVisitors(countryUniqueVisitorsKey(name))
+		}
+		countries[name] = country
+	}
+	return nil
+}
+
+// MigrateDistanceStats merges the statistics stored by earlier versions as a
+// single JSON blob into the atomic structures. It is a no-op once migrated.
+func MigrateDistanceStats(ctx context.Context, cache interfaces.Cache) error {
+	result, err := cache.Eval(ctx, migrateDistanceStatsScript, []string{
+		distanceStatsKey, countryDistanceStatsKey, countryRequestsStatsKey, legacyDistanceStatsKey, migratedDistanceStatsKey,
+	})
+	if err != nil {
+		return fmt.Errorf("error migrating distance stats: %w", err)
+	}
+
+	if migrated, _ := result.(int64); migrated == 1 {
+		fmt.Printf("Migrated distance stats from %s (backup kept in %s)\n", legacyDistanceStatsKey, migratedDistanceStatsKey)
+	}
+	return nil
+}
+
+// getDistanceHistogram loads a histogram stored as a hash of bucket index to
+// count. Every API instance increments the same hash, so the stored counts
+// are already the merged histogram.
+func getDistanceHistogram(ctx context.Context, cache interfaces.Cache, key string) (*histogram.Histogram, error) {
+	fields, err := cache.HGetAll(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading distance histogram %s: %w", key, err)
+	}
+
+	return histogramFromFields(fields), nil
+}
+
+func histogramFromFields(fields map[string]string) *histogram.Histogram {
+	h := histogram.New()
+	for field, value := range fields {
+		index, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		h.Add(index, uint64(parseStatFloat(value)))
+	}
+	return h
+}
+
+func CalculateWeightedAverageDistance(stats *DistanceStats) float64 {
+	totalWeightedDistance := 0.0
+	totalRequests := 0
+
+	for _, countryData := range stats.CountryDistances {
+		totalWeightedDistance += countryData.TotalDistance
+		totalRequests += countryData.Requests
+	}
+
+	if totalRequests == 0 {
+		return 0
+	}
+
+	return totalWeightedDistance / float64(totalRequests)
+}
+
+func parseStatFloat(value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
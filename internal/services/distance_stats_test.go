@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestRecordDistance(t *testing.T) {
+	viper.Set("stats.retention.minute", time.Hour)
+	viper.Set("stats.retention.hour", 0)
+	viper.Set("stats.retention.day", 0)
+	t.Cleanup(viper.Reset)
+
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	lookups := []struct {
+		ip       string
+		distance float64
+		country  string
+	}{
+		{"190.2.1.10", 1000.5, "Argentina"},
+		{"190.2.1.10", 1000.5, "Argentina"},
+		{"177.10.0.1", 2500.25, "Brazil"},
+		{"8.8.8.8", 9000, "United States"},
+	}
+	for _, lookup := range lookups {
+		if err := recordDistance(ctx, redisCache, lookup.ip, lookup.distance, lookup.country); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := GetDistanceStatsFromCache(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 4 || stats.TotalDistance != 13501.25 {
+		t.Fatalf("got %d requests and %v km", stats.TotalRequests, stats.TotalDistance)
+	}
+	if stats.FarthestCountryName != "United States" || stats.ClosestCountryName != "Argentina" {
+		t.Fatalf("got farthest %s and closest %s", stats.FarthestCountryName, stats.ClosestCountryName)
+	}
+	if stats.UniqueVisitors != 3 {
+		t.Fatalf("got %d unique visitors, want 3", stats.UniqueVisitors)
+	}
+	if argentina := stats.CountryDistances["Argentina"]; argentina.Requests != 2 || argentina.UniqueVisitors != 1 {
+		t.Fatalf("unexpected Argentina stats %+v", argentina)
+	}
+	if stats.Histogram.Count() != 4 {
+		t.Fatalf("histogram counted %d lookups, want 4", stats.Histogram.Count())
+	}
+
+	bucket := distanceBucketKey(GranularityMinute, time.Now().UTC().Truncate(time.Minute))
+	if ttl := server.TTL(bucket); ttl <= time.Hour || ttl > time.Hour+time.Minute {
+		t.Fatalf("minute bucket TTL = %v", ttl)
+	}
+	if server.Exists(distanceBucketKey(GranularityHour, time.Now().UTC().Truncate(time.Hour))) {
+		t.Fatal("expected no buckets for a granularity without retention")
+	}
+}
+
+func TestMigrateDistanceStats(t *testing.T) {
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	legacy := `{"FarthestDistance":12345.678901234567,"ClosestDistance":0.123456789012345678,
+		"TotalDistance":98765.43210987654,"TotalRequests":3,
+		"FarthestCountryName":"Japan","ClosestCountryName":"Uruguay",
+		"CountryDistances":{"Japan":{"TotalDistance":12345.678901234567,"Requests":1}}}`
+	if err := server.Set(legacyDistanceStatsKey, legacy); err != nil {
+		t.Fatal(err)
+	}
+	server.SetTTL(legacyDistanceStatsKey, time.Hour)
+
+	if err := MigrateDistanceStats(ctx, redisCache); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := GetDistanceStatsFromCache(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalDistance != 98765.43210987654 || stats.FarthestDistance != 12345.678901234567 || stats.ClosestDistance != 0.12345678901234568 {
+		t.Fatalf("migrated values lost precision: %+v", stats)
+	}
+	if stats.TotalRequests != 3 || stats.CountryDistances["Japan"].Requests != 1 {
+		t.Fatalf("unexpected migrated stats %+v", stats)
+	}
+
+	if server.Exists(legacyDistanceStatsKey) {
+		t.Fatal("expected the legacy key to be renamed")
+	}
+	if ttl := server.TTL(migratedDistanceStatsKey); ttl != 0 {
+		t.Fatalf("expected the backup not to expire, got TTL %v", ttl)
+	}
+
+	// A second run is a no-op even if the legacy key reappears.
+	if err := server.Set(legacyDistanceStatsKey, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateDistanceStats(ctx, redisCache); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := GetDistanceStatsFromCache(ctx, redisCache); stats.TotalRequests != 3 {
+		t.Fatalf("second migration changed the totals to %d requests", stats.TotalRequests)
+	}
+}
+
+func TestMigrateDistanceStatsMergesLaterLookups(t *testing.T) {
+	viper.Set("stats.retention.minute", 0)
+	viper.Set("stats.retention.hour", 0)
+	viper.Set("stats.retention.day", 0)
+	t.Cleanup(viper.Reset)
+
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	legacy := `{"FarthestDistance":12000,"ClosestDistance":100,"TotalDistance":12100,"TotalRequests":2,
+		"FarthestCountryName":"Japan","ClosestCountryName":"Uruguay",
+		"CountryDistances":{"Japan":{"TotalDistance":12000,"Requests":1},"Uruguay":{"TotalDistance":100,"Requests":1}}}`
+	if err := server.Set(legacyDistanceStatsKey, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lookups served while an earlier migration attempt failed.
+	if err := recordDistance(ctx, redisCache, "190.2.1.10", 50, "Uruguay"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordDistance(ctx, redisCache, "8.8.8.8", 9000, "United States"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MigrateDistanceStats(ctx, redisCache); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := GetDistanceStatsFromCache(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 4 || stats.TotalDistance != 21150 {
+		t.Fatalf("expected the legacy totals to be added, got %d requests and %v km", stats.TotalRequests, stats.TotalDistance)
+	}
+	if stats.FarthestCountryName != "Japan" || stats.ClosestCountryName != "Uruguay" || stats.ClosestDistance != 50 {
+		t.Fatalf("unexpected extremes %+v", stats)
+	}
+	if uruguay := stats.CountryDistances["Uruguay"]; uruguay.Requests != 2 || uruguay.TotalDistance != 150 {
+		t.Fatalf("unexpected Uruguay stats %+v", uruguay)
+	}
+	if !server.Exists(migratedDistanceStatsKey) {
+		t.Fatal("expected the blob to be kept as the migration marker")
+	}
+}
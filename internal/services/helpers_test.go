@@ -0,0 +1,22 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cgiraldoz/geo-ip-info/internal/cache"
+)
+
+// newTestRedis starts an in-memory Redis for the test and returns it with a
+// cache connected to it, for tests that inspect keys or stop the server.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *cache.RedisCache) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return server, cache.NewRedisCache(server.Addr(), "", 0)
+}
+
+func newTestCache(t *testing.T) *cache.RedisCache {
+	t.Helper()
+	_, redisCache := newTestRedis(t)
+	return redisCache
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/geodesy"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidLoginEvent = errors.New("invalid login event")
+
+const (
+	travelLastSeenKeyPrefix = "travel:last_seen:"
+	travelAlertsKey         = "travel:alerts"
+
+	LoginEventsJSON   = "json"
+	LoginEventsNDJSON = "ndjson"
+	LoginEventsCSV    = "csv"
+)
+
+type LoginEvent struct {
+	Subject   string
+	IP        string
+	Timestamp time.Time
+}
+
+type TravelSighting struct {
+	IP               string    `json:"ip"`
+	CountryCode      string    `json:"country_code"`
+	City             string    `json:"city"`
+	Latitude         float64   `json:"latitude"`
+	Longitude        float64   `json:"longitude"`
+	AccuracyRadiusKm float64   `json:"accuracy_radius_km"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+type TravelCheck struct {
+	Subject       string          `json:"subject"`
+	Current       TravelSighting  `json:"current"`
+	Previous      *TravelSighting `json:"previous,omitempty"`
+	DistanceKm    float64         `json:"distance_km"`
+	MinDistanceKm float64         `json:"min_distance_km"`
+	ElapsedSecs   float64         `json:"elapsed_seconds"`
+	SpeedKmh      float64         `json:"speed_kmh"`
+	ThresholdKmh  float64         `json:"threshold_kmh"`
+	Alert         bool            `json:"alert"`
+	Reason        string          `json:"reason,omitempty"`
+}
+
+type loginEventRecord struct {
+	Subject   string `json:"subject"`
+	IP        string `json:"ip"`
+	Timestamp any    `json:"timestamp"`
+}
+
+// ParseLoginEvents reads all the login events of r, see DecodeLoginEvents.
+func ParseLoginEvents(r io.Reader, format string) ([]LoginEvent, error) {
+	var events []LoginEvent
+	err := DecodeLoginEvents(r, format, func(event LoginEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DecodeLoginEvents reads login events as a JSON array, newline-delimited JSON
+// or CSV with subject,ip,timestamp columns, and calls fn with each event as
+// soon as it is decoded, so a stream can be checked while it is still being
+// written. Timestamps are RFC3339 or Unix seconds.
+func DecodeLoginEvents(r io.Reader, format string, fn func(LoginEvent) error) error {
+	emit := func(n int, record loginEventRecord) error {
+		event, err := record.event()
+		if err != nil {
+			return fmt.Errorf("%w: event %d: %v", ErrInvalidLoginEvent, n, err)
+		}
+		return fn(event)
+	}
+
+	switch strings.ToLower(format) {
+	case "", LoginEventsJSON:
+		decoder := json.NewDecoder(r)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return fmt.Errorf("%w: expected a JSON array of events", ErrInvalidLoginEvent)
+		}
+		for n := 1; decoder.More(); n++ {
+			var record loginEventRecord
+			if err := decoder.Decode(&record); err != nil {
+				return fmt.Errorf("%w: event %d: %v", ErrInvalidLoginEvent, n, err)
+			}
+			if err := emit(n, record); err != nil {
+				return err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidLoginEvent, err)
+		}
+		return nil
+	case LoginEventsNDJSON:
+		decoder := json.NewDecoder(r)
+		for n := 1; ; n++ {
+			var record loginEventRecord
+			err := decoder.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrInvalidLoginEvent, n, err)
+			}
+			if err := emit(n, record); err != nil {
+				return err
+			}
+		}
+	case LoginEventsCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		for row, n := 1, 1; ; row++ {
+			fields, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidLoginEvent, err)
+			}
+			if len(fields) != 3 {
+				return fmt.Errorf("%w: row %d must have subject,ip,timestamp", ErrInvalidLoginEvent, row)
+			}
+			if row == 1 && strings.EqualFold(fields[0], "subject") {
+				continue
+			}
+			if err := emit(n, loginEventRecord{Subject: fields[0], IP: fields[1], Timestamp: fields[2]}); err != nil {
+				return err
+			}
+			n++
+		}
+	default:
+		return fmt.Errorf("%w: unknown format %q (use json, ndjson or csv)", ErrInvalidLoginEvent, format)
+	}
+}
+
+func (record loginEventRecord) event() (LoginEvent, error) {
+	timestamp, err := parseEventTimestamp(record.Timestamp)
+	if err != nil {
+		return LoginEvent{}, err
+	}
+	if strings.TrimSpace(record.Subject) == "" || strings.TrimSpace(record.IP) == "" {
+		return LoginEvent{}, errors.New("a subject and an ip are required")
+	}
+
+	return LoginEvent{
+		Subject:   strings.TrimSpace(record.Subject),
+		IP:        strings.TrimSpace(record.IP),
+		Timestamp: timestamp,
+	}, nil
+}
+
+func parseEventTimestamp(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+		timestamp, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp %q is neither RFC3339 nor Unix seconds", v)
+		}
+		return timestamp.UTC(), nil
+	default:
+		return time.Time{}, errors.New("missing timestamp")
+	}
+}
+
+// TravelChecker checks login events one at a time against the last location
+// seen for each subject. The implied speed uses the distance minus both
+// accuracy radii, so imprecise geolocations do not raise false alerts.
+type TravelChecker struct {
+	cache      interfaces.Cache
+	ipLocation *IPLocation
+	threshold  float64
+}
+
+func NewTravelChecker(redisCache interfaces.Cache, httpClient interfaces.Client) (*TravelChecker, error) {
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	return &TravelChecker{
+		cache:      redisCache,
+		ipLocation: ipLocation,
+		threshold:  viper.GetFloat64("travel.max_speed_kmh"),
+	}, nil
+}
+
+// Check evaluates one event and records it as the subject's last sighting
+// unless an event with a later timestamp was already seen. Reading the
+// previous sighting and replacing it happen in one script, so concurrent
+// checks for a subject each compare against a sighting the other stored.
+func (tc *TravelChecker) Check(event LoginEvent) (TravelCheck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	endpoint, err := resolveDistanceEndpoint(ctx, tc.cache, tc.ipLocation, event.IP)
+	if errors.Is(err, ErrInvalidDistanceRequest) {
+		return TravelCheck{}, fmt.Errorf("%w: %v", ErrInvalidLoginEvent, err)
+	}
+	if err != nil {
+		return TravelCheck{}, err
+	}
+
+	check := TravelCheck{
+		Subject:      event.Subject,
+		ThresholdKmh: tc.threshold,
+		Current: TravelSighting{
+			IP:               event.IP,
+			CountryCode:      endpoint.CountryCode,
+			City:             endpoint.City,
+			Latitude:         endpoint.Coordinates.Latitude,
+			Longitude:        endpoint.Coordinates.Longitude,
+			AccuracyRadiusKm: endpoint.Coordinates.AccuracyRadiusKm,
+			Timestamp:        event.Timestamp,
+		},
+	}
+
+	previous, err := swapTravelSighting(ctx, tc.cache, event.Subject, check.Current)
+	if err != nil {
+		return TravelCheck{}, err
+	}
+	if previous != nil {
+		evaluateTravel(&check, *previous)
+	}
+
+	if check.Alert {
+		if err := appendTravelAlert(ctx, tc.cache, check); err != nil {
+			fmt.Printf("Error recording travel alert: %v\n", err)
+		}
+	}
+
+	return check, nil
+}
+
+// CheckLoginEvents replays a batch of events in timestamp order.
+func CheckLoginEvents(redisCache interfaces.Cache, httpClient interfaces.Client, events []LoginEvent) ([]TravelCheck, error) {
+	if maxEvents := viper.GetInt("travel.max_events"); maxEvents > 0 && len(events) > maxEvents {
+		return nil, fmt.Errorf("%w: at most %d events per request", ErrInvalidLoginEvent, maxEvents)
+	}
+
+	checker, err := NewTravelChecker(redisCache, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]LoginEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	checks := make([]TravelCheck, 0, len(sorted))
+	for _, event := range sorted {
+		check, err := checker.Check(event)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+func evaluateTravel(check *TravelCheck, previous TravelSighting) {
+	check.Previous = &previous
+
+	inverse := geodesy.Distance(
+		geodesy.Point{Latitude: previous.Latitude, Longitude: previous.Longitude},
+		geodesy.Point{Latitude: check.Current.Latitude, Longitude: check.Current.Longitude},
+	)
+	check.DistanceKm = geodesy.Kilometers.FromMeters(inverse.DistanceMeters)
+	check.MinDistanceKm, _ = distanceBand(check.DistanceKm, previous.AccuracyRadiusKm+check.Current.AccuracyRadiusKm)
+
+	elapsed := check.Current.Timestamp.Sub(previous.Timestamp)
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+	check.ElapsedSecs = elapsed.Seconds()
+
+	if check.MinDistanceKm == 0 {
+		return
+	}
+	if elapsed == 0 {
+		check.Alert = true
+		check.Reason = "simultaneous logins from distant locations"
+		return
+	}
+
+	check.SpeedKmh = check.MinDistanceKm / elapsed.Hours()
+	if check.ThresholdKmh > 0 && check.SpeedKmh > check.ThresholdKmh {
+		check.Alert = true
+		check.Reason = fmt.Sprintf("implied speed %.0f km/h exceeds %.0f km/h", check.SpeedKmh, check.ThresholdKmh)
+	}
+}
+
+// swapTravelSightingScript returns the subject's last sighting and replaces
+// it with ARGV[1] unless that one is newer. Timestamps are compared as
+// seconds and nanoseconds, which Lua numbers hold exactly.
+const swapTravelSightingScript = `
+local previous = redis.call('HMGET', KEYS[1], 'data', 'sec', 'nsec')
+if previous[1] then
+	local sec, nsec = tonumber(ARGV[2]), tonumber(ARGV[3])
+	local previousSec, previousNsec = tonumber(previous[2]), tonumber(previous[3])
+	if sec < previousSec or (sec == previousSec and nsec < previousNsec) then
+		return previous[1]
+	end
+end
+redis.call('HSET', KEYS[1], 'data', ARGV[1], 'sec', ARGV[2], 'nsec', ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return previous[1] or ''
+`
+
+func swapTravelSighting(ctx context.Context, cache interfaces.Cache, subject string, sighting TravelSighting) (*TravelSighting, error) {
+	data, err := json.Marshal(sighting)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling last seen location of %s: %w", subject, err)
+	}
+
+	result, err := cache.Eval(ctx, swapTravelSightingScript, []string{travelLastSeenKeyPrefix + subject},
+		data, sighting.Timestamp.Unix(), sighting.Timestamp.Nanosecond(), viper.GetDuration("travel.state_ttl").Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("error updating last seen location of %s: %w", subject, err)
+	}
+
+	previous, _ := result.(string)
+	return decodeTravelSighting(subject, previous)
+}
+
+func getTravelSighting(ctx context.Context, cache interfaces.Cache, subject string) (*TravelSighting, error) {
+	fields, err := cache.HGetAll(ctx, travelLastSeenKeyPrefix+subject)
+	if err != nil {
+		return nil, fmt.Errorf("error getting last seen location of %s: %w", subject, err)
+	}
+	return decodeTravelSighting(subject, fields["data"])
+}
+
+func decodeTravelSighting(subject, data string) (*TravelSighting, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var sighting TravelSighting
+	if err := json.Unmarshal([]byte(data), &sighting); err != nil {
+		return nil, fmt.Errorf("error unmarshalling last seen location of %s: %w", subject, err)
+	}
+	return &sighting, nil
+}
+
+// appendTravelAlertScript pushes an alert onto the capped list, newest first.
+const appendTravelAlertScript = `
+redis.call('LPUSH', KEYS[1], ARGV[1])
+local size = tonumber(ARGV[2])
+if size > 0 then
+	redis.call('LTRIM', KEYS[1], 0, size - 1)
+end
+return 1
+`
+
+const readTravelAlertsScript = `return redis.call('LRANGE', KEYS[1], 0, -1)`
+
+func appendTravelAlert(ctx context.Context, cache interfaces.Cache, check TravelCheck) error {
+	data, err := json.Marshal(check)
+	if err != nil {
+		return fmt.Errorf("error marshalling travel alert: %w", err)
+	}
+
+	if _, err := cache.Eval(ctx, appendTravelAlertScript, []string{travelAlertsKey}, data, viper.GetInt("travel.log_size")); err != nil {
+		return fmt.Errorf("error recording travel alert: %w", err)
+	}
+	return nil
+}
+
+// GetTravelAlerts returns the logged alerts, oldest first.
+func GetTravelAlerts(ctx context.Context, cache interfaces.Cache) ([]TravelCheck, error) {
+	result, err := cache.Eval(ctx, readTravelAlertsScript, []string{travelAlertsKey})
+	if err != nil {
+		return nil, fmt.Errorf("error reading travel alerts: %w", err)
+	}
+
+	entries, _ := result.([]interface{})
+	alerts := make([]TravelCheck, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry, _ := entries[i].(string)
+
+		var alert TravelCheck
+		if err := json.Unmarshal([]byte(entry), &alert); err != nil {
+			return nil, fmt.Errorf("error unmarshalling travel alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
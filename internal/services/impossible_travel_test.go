@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseLoginEvents(t *testing.T) {
+	first := LoginEvent{Subject: "alice", IP: "8.8.8.8", Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	second := LoginEvent{Subject: "bob", IP: "1.1.1.1", Timestamp: time.Unix(1704106800, 0).UTC()}
+
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []LoginEvent
+		wantErr string
+	}{
+		{
+			name:   "json array",
+			format: "",
+			input:  `[{"subject":"alice","ip":"8.8.8.8","timestamp":"2024-01-01T07:00:00-03:00"},{"subject":"bob","ip":"1.1.1.1","timestamp":1704106800}]`,
+			want:   []LoginEvent{first, second},
+		},
+		{
+			name:   "ndjson",
+			format: "NDJSON",
+			input:  "{\"subject\":\" alice \",\"ip\":\"8.8.8.8\",\"timestamp\":\"2024-01-01T10:00:00Z\"}\n{\"subject\":\"bob\",\"ip\":\"1.1.1.1\",\"timestamp\":\"1704106800\"}\n",
+			want:   []LoginEvent{first, second},
+		},
+		{
+			name:   "csv with header",
+			format: "csv",
+			input:  "subject,ip,timestamp\nalice,8.8.8.8,2024-01-01T10:00:00Z\nbob,1.1.1.1,1704106800\n",
+			want:   []LoginEvent{first, second},
+		},
+		{
+			name:   "csv without header",
+			format: "csv",
+			input:  "alice,8.8.8.8,2024-01-01T10:00:00Z\n",
+			want:   []LoginEvent{first},
+		},
+		{name: "empty json array", format: "json", input: `[]`, want: nil},
+		{name: "json object", format: "json", input: `{"subject":"alice"}`, wantErr: "expected a JSON array"},
+		{name: "missing timestamp", format: "ndjson", input: `{"subject":"alice","ip":"8.8.8.8"}`, wantErr: "event 1: missing timestamp"},
+		{name: "bad timestamp", format: "json", input: `[{"subject":"alice","ip":"8.8.8.8","timestamp":"yesterday"}]`, wantErr: "event 1: timestamp \"yesterday\""},
+		{name: "missing ip", format: "csv", input: "alice,,1704106800\n", wantErr: "event 1: a subject and an ip are required"},
+		{name: "short csv row", format: "csv", input: "alice,8.8.8.8,1704106800\nbob,1.1.1.1\n", wantErr: "row 2 must have"},
+		{name: "broken ndjson line", format: "ndjson", input: "{\"subject\":\"alice\",\"ip\":\"8.8.8.8\",\"timestamp\":1}\n{oops\n", wantErr: "line 2"},
+		{name: "unknown format", format: "xml", input: "", wantErr: "unknown format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ParseLoginEvents(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidLoginEvent) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", events, tt.want)
+			}
+			for i := range events {
+				if events[i] != tt.want[i] {
+					t.Fatalf("event %d = %+v, want %+v", i, events[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeLoginEventsStreams(t *testing.T) {
+	reader, writer := io.Pipe()
+	decoded := make(chan LoginEvent)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- DecodeLoginEvents(reader, LoginEventsNDJSON, func(event LoginEvent) error {
+			decoded <- event
+			return nil
+		})
+	}()
+
+	for _, subject := range []string{"alice", "bob"} {
+		if _, err := io.WriteString(writer, `{"subject":"`+subject+`","ip":"8.8.8.8","timestamp":1}`+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-decoded:
+			if event.Subject != subject {
+				t.Fatalf("got %s, want %s", event.Subject, subject)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event for %s was not decoded before the stream ended", subject)
+		}
+	}
+
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeLoginEventsStopsOnCallbackError(t *testing.T) {
+	failure := errors.New("cache down")
+	calls := 0
+	err := DecodeLoginEvents(strings.NewReader("a,1.1.1.1,1\nb,1.1.1.1,2\n"), LoginEventsCSV, func(LoginEvent) error {
+		calls++
+		return failure
+	})
+	if !errors.Is(err, failure) || errors.Is(err, ErrInvalidLoginEvent) || calls != 1 {
+		t.Fatalf("got %v after %d calls", err, calls)
+	}
+}
+
+func setupTravel(t *testing.T) {
+	viper.Set("ipapi.url", "http://ipapi.invalid")
+	viper.Set("context.timeout", time.Second)
+	viper.Set("travel.max_speed_kmh", 1000)
+	viper.Set("travel.log_size", 2)
+	t.Cleanup(viper.Reset)
+}
+
+func TestTravelChecker(t *testing.T) {
+	setupTravel(t)
+	redisCache := newTestCache(t)
+
+	checker, err := NewTravelChecker(redisCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	buenosAires, madrid := "-34.6037,-58.3816", "40.4168,-3.7038"
+
+	steps := []struct {
+		name  string
+		event LoginEvent
+		alert bool
+	}{
+		{"first sighting", LoginEvent{"alice", buenosAires, start}, false},
+		{"same place later", LoginEvent{"alice", buenosAires, start.Add(time.Hour)}, false},
+		{"madrid two hours later", LoginEvent{"alice", madrid, start.Add(3 * time.Hour)}, true},
+		{"back in buenos aires a day later", LoginEvent{"alice", buenosAires, start.Add(27 * time.Hour)}, false},
+		{"other subject", LoginEvent{"bob", madrid, start}, false},
+		{"simultaneous", LoginEvent{"bob", buenosAires, start}, true},
+		{"older event", LoginEvent{"bob", madrid, start.Add(-time.Minute)}, true},
+	}
+
+	for _, step := range steps {
+		check, err := checker.Check(step.event)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if check.Alert != step.alert {
+			t.Fatalf("%s: alert = %v (%s, %.0f km/h)", step.name, check.Alert, check.Reason, check.SpeedKmh)
+		}
+	}
+
+	sighting, err := getTravelSighting(context.Background(), redisCache, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sighting == nil || sighting.IP != buenosAires {
+		t.Fatalf("an older event must not replace the last sighting, got %+v", sighting)
+	}
+
+	alerts, err := GetTravelAlerts(context.Background(), redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Reason != "simultaneous logins from distant locations" || alerts[1].Current.IP != madrid {
+		t.Fatalf("expected the two latest alerts, oldest first, got %+v", alerts)
+	}
+}
+
+func TestGetTravelSightingReportsCacheErrors(t *testing.T) {
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	sighting, err := getTravelSighting(ctx, redisCache, "alice")
+	if err != nil || sighting != nil {
+		t.Fatalf("a missing key is not an error, got %+v, %v", sighting, err)
+	}
+
+	server.Close()
+	if _, err := getTravelSighting(ctx, redisCache, "alice"); err == nil {
+		t.Fatal("expected an error when the cache is unreachable")
+	}
+}
+
+func TestAppendTravelAlertCapsLog(t *testing.T) {
+	setupTravel(t)
+	viper.Set("travel.log_size", 3)
+	redisCache := newTestCache(t)
+	ctx := context.Background()
+
+	for _, subject := range []string{"one", "two", "three", "four"} {
+		if err := appendTravelAlert(ctx, redisCache, TravelCheck{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts, err := GetTravelAlerts(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, alert := range alerts {
+		subjects = append(subjects, alert.Subject)
+	}
+	if strings.Join(subjects, ",") != "two,three,four" {
+		t.Fatalf("got %v", subjects)
+	}
+}
+
+func TestSwapTravelSighting(t *testing.T) {
+	setupTravel(t)
+	viper.Set("travel.state_ttl", time.Hour)
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 500, time.UTC)
+	steps := []struct {
+		name     string
+		sighting TravelSighting
+		previous string
+		stored   string
+	}{
+		{"first sighting", TravelSighting{IP: "a", Timestamp: start}, "", "a"},
+		{"newer sighting", TravelSighting{IP: "b", Timestamp: start.Add(time.Second)}, "a", "b"},
+		{"same instant", TravelSighting{IP: "c", Timestamp: start.Add(time.Second)}, "b", "c"},
+		{"one nanosecond older", TravelSighting{IP: "d", Timestamp: start.Add(time.Second - time.Nanosecond)}, "c", "c"},
+		{"older second", TravelSighting{IP: "e", Timestamp: start}, "c", "c"},
+	}
+
+	for _, step := range steps {
+		previous, err := swapTravelSighting(ctx, redisCache, "alice", step.sighting)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if (previous == nil && step.previous != "") || (previous != nil && previous.IP != step.previous) {
+			t.Fatalf("%s: previous = %+v, want %q", step.name, previous, step.previous)
+		}
+
+		stored, err := getTravelSighting(ctx, redisCache, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.IP != step.stored {
+			t.Fatalf("%s: stored %s, want %s", step.name, stored.IP, step.stored)
+		}
+	}
+
+	if ttl := server.TTL(travelLastSeenKeyPrefix + "alice"); ttl != time.Hour {
+		t.Fatalf("last sighting expires in %v, want travel.state_ttl", ttl)
+	}
+}
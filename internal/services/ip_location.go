@@ -15,8 +15,14 @@ import (
 )
 
 type CountryInfo struct {
-	Name    string `maxminddb:"name"`
-	IsoCode string `maxminddb:"iso_code"`
+	Name           string `maxminddb:"name"`
+	IsoCode        string `maxminddb:"iso_code"`
+	City           string
+	TimeZone       string
+	Latitude       float64
+	Longitude      float64
+	AccuracyRadius uint16
+	HasLocation    bool
 }
 
 type IPLocation struct {
@@ -55,8 +61,14 @@ func (g *IPLocation) GetIPLocation(ctx context.Context, ip string) (*CountryInfo
 
 	if err == nil && record != nil && record.Country.IsoCode != "" {
 		return &CountryInfo{
-			Name:    record.Country.Names["en"],
-			IsoCode: record.Country.IsoCode,
+			Name:           record.Country.Names["en"],
+			IsoCode:        record.Country.IsoCode,
+			City:           record.City.Names["en"],
+			TimeZone:       record.Location.TimeZone,
+			Latitude:       record.Location.Latitude,
+			Longitude:      record.Location.Longitude,
+			AccuracyRadius: record.Location.AccuracyRadius,
+			HasLocation:    record.Location.Latitude != 0 || record.Location.Longitude != 0,
 		}, nil
 	}
 
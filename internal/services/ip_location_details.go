@@ -5,19 +5,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/cgiraldoz/geo-ip-info/internal/astro"
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/timezone"
 	"github.com/spf13/viper"
 )
 
 type Country struct {
-	Cca2       string              `json:"cca2"`
-	Currencies map[string]Currency `json:"currencies"`
-	Languages  map[string]string   `json:"languages"`
-	LatLng     []float64           `json:"latlng"`
-	Name       CountryName         `json:"name"`
-	Timezones  []string            `json:"timezones"`
+	Cca2        string              `json:"cca2"`
+	Currencies  map[string]Currency `json:"currencies"`
+	Languages   map[string]string   `json:"languages"`
+	LatLng      []float64           `json:"latlng"`
+	Name        CountryName         `json:"name"`
+	Timezones   []string            `json:"timezones"`
+	Capital     []string            `json:"capital"`
+	CapitalInfo CapitalInfo         `json:"capitalInfo"`
+}
+
+type CapitalInfo struct {
+	LatLng []float64 `json:"latlng"`
 }
 
 type Currency struct {
@@ -37,35 +47,48 @@ type NativeNames struct {
 }
 
 type RatesData struct {
-	Rates map[string]float64 `json:"rates"`
+	Provider      string             `json:"provider,omitempty"`
+	Base          string             `json:"base"`
+	Date          string             `json:"date"`
+	Timestamp     int64              `json:"timestamp"`
+	Rates         map[string]float64 `json:"rates"`
+	Discrepancies []RateDiscrepancy  `json:"discrepancies,omitempty"`
+}
+
+type IPLocationOptions struct {
+	BaseCurrencies []string
+	RatesDate      string
+	TimeFormat     string
+	HomeLocation   *time.Location
+	References     []string
 }
 
 type IPLocationDetails struct {
 	CountryName           string
+	BaseCurrency          string
 	RelativeRates         map[string]float64
+	RatesMatrix           map[string]map[string]float64
+	RatesInfo             RatesInfo
 	CurrentTimeByTimezone map[string]string
+	CurrentTimeByZone     map[string]string
+	LegacyTimezones       []string
+	TimeZone              string
+	Timezones             []timezone.Info
 	Currencies            map[string]Currency
 	Cca2                  string
 	LatLng                []float64
+	Coordinates           Coordinates
+	Sun                   *astro.SunTimes
+	Distances             []ReferenceDistance
 	DistanceToBuenosAires float64
 }
 
-type DistanceStats struct {
-	FarthestDistance    float64
-	ClosestDistance     float64
-	TotalDistance       float64
-	TotalRequests       int
-	FarthestCountryName string
-	ClosestCountryName  string
-	CountryDistances    map[string]CountryDistance
-}
-
-type CountryDistance struct {
-	TotalDistance float64
-	Requests      int
-}
+func GetIPLocationDetails(redisCache interfaces.Cache, httpClient interfaces.Client, ip string, opts IPLocationOptions) (*IPLocationDetails, error) {
+	references, err := ResolveReferenceLocations(opts.References)
+	if err != nil {
+		return nil, err
+	}
 
-func GetIPLocationDetails(redisCache interfaces.Cache, httpClient interfaces.Client, ip string) (*IPLocationDetails, error) {
 	ipLocation, err := NewIPLocation(httpClient)
 	if err != nil {
 		return nil, fmt.Errorf("error creating IP location service: %w", err)
@@ -82,9 +105,12 @@ func GetIPLocationDetails(redisCache interfaces.Cache, httpClient interfaces.Cli
 	countryCacheKey := "country:" + info.IsoCode
 	cachedDetails, err := getCountryDetailsFromCache(ctx, redisCache, countryCacheKey)
 	if err == nil && cachedDetails != nil {
-		updateCurrentTimeByTimezone(cachedDetails)
-		cachedDetails.DistanceToBuenosAires = calculateDistanceToBuenosAires(cachedDetails.LatLng)
-		updateDistanceStats(ctx, redisCache, cachedDetails.LatLng, cachedDetails.CountryName)
+		applyTimezones(cachedDetails, info.TimeZone, opts)
+		applyLocation(cachedDetails, info, references)
+		if err := applyRelativeRates(ctx, redisCache, cachedDetails, opts.baseCurrencies(), opts.RatesDate); err != nil {
+			return nil, err
+		}
+		updateDistanceStats(ctx, redisCache, ip, cachedDetails.Coordinates.latLng(), cachedDetails.CountryName)
 		return cachedDetails, nil
 	}
 
@@ -93,37 +119,38 @@ func GetIPLocationDetails(redisCache interfaces.Cache, httpClient interfaces.Cli
 		return nil, err
 	}
 
-	ratesData, err := getRatesDataFromCache(ctx, redisCache)
-	if err != nil {
-		return nil, err
-	}
-
-	usdRate, exists := ratesData.Rates["USD"]
-	if !exists {
-		return nil, fmt.Errorf("USD rate not found in cache")
+	ipDetails := &IPLocationDetails{
+		CountryName:     country.Name.Common,
+		LegacyTimezones: country.Timezones,
+		Currencies:      country.Currencies,
+		Cca2:            country.Cca2,
+		LatLng:          country.LatLng,
 	}
 
-	relativeRates := calculateRelativeRates(country.Currencies, ratesData.Rates, usdRate)
-	currentTimeByTimezone := calculateCurrentTimeByTimezone(country.Timezones)
+	applyTimezones(ipDetails, info.TimeZone, opts)
+	applyLocation(ipDetails, info, references)
 
-	ipDetails := &IPLocationDetails{
-		CountryName:           country.Name.Common,
-		RelativeRates:         relativeRates,
-		CurrentTimeByTimezone: currentTimeByTimezone,
-		Currencies:            country.Currencies,
-		Cca2:                  country.Cca2,
-		LatLng:                country.LatLng,
-		DistanceToBuenosAires: calculateDistanceToBuenosAires(country.LatLng),
+	if err := applyRelativeRates(ctx, redisCache, ipDetails, opts.baseCurrencies(), opts.RatesDate); err != nil {
+		return nil, err
 	}
 
 	err = cacheCountryDetails(ctx, redisCache, countryCacheKey, ipDetails)
 	if err != nil {
 		return nil, fmt.Errorf("error caching country details: %w", err)
 	}
-	updateDistanceStats(ctx, redisCache, ipDetails.LatLng, ipDetails.CountryName)
+	updateDistanceStats(ctx, redisCache, ip, ipDetails.Coordinates.latLng(), ipDetails.CountryName)
 	return ipDetails, nil
 }
 
+// applyLocation fills the per-IP fields derived from the looked-up
+// coordinates, which differ between IPs of the same cached country.
+func applyLocation(details *IPLocationDetails, info *CountryInfo, references []ReferenceLocation) {
+	details.Coordinates = lookupCoordinates(info, details.LatLng)
+	details.DistanceToBuenosAires = calculateDistanceToBuenosAires(details.Coordinates.latLng())
+	details.Distances = calculateReferenceDistances(references, details.Coordinates)
+	applyDaylight(details)
+}
+
 func getCountryDetailsFromCache(ctx context.Context, cache interfaces.Cache, countryCacheKey string) (*IPLocationDetails, error) {
 	data, err := cache.Get(ctx, countryCacheKey)
 	if err != nil || data == nil {
@@ -148,35 +175,73 @@ func cacheCountryDetails(ctx context.Context, cache interfaces.Cache, countryCac
 	return cache.Set(ctx, countryCacheKey, data, ttl)
 }
 
-func updateCurrentTimeByTimezone(details *IPLocationDetails) {
-	for timezone := range details.CurrentTimeByTimezone {
-		offset, err := parseTimezoneOffset(timezone)
-		if err == nil {
-			details.CurrentTimeByTimezone[timezone] = time.Now().UTC().Add(offset).Format(time.RFC1123)
+func applyTimezones(details *IPLocationDetails, cityZone string, opts IPLocationOptions) {
+	now := time.Now()
+	hours, hoursErr := loadBusinessHours()
+
+	legacyTimezones := details.LegacyTimezones
+	if len(legacyTimezones) == 0 {
+		for key := range details.CurrentTimeByTimezone {
+			legacyTimezones = append(legacyTimezones, key)
 		}
 	}
-}
 
-func calculateCurrentTimeByTimezone(timezones []string) map[string]string {
 	currentTimeByTimezone := make(map[string]string)
-	for _, timezone := range timezones {
-		offset, err := parseTimezoneOffset(timezone)
+	for _, legacyTimezone := range legacyTimezones {
+		offset, err := parseTimezoneOffset(legacyTimezone)
 		if err == nil {
-			currentTimeByTimezone[timezone] = time.Now().UTC().Add(offset).Format(time.RFC1123)
+			location := time.FixedZone(legacyTimezone, int(offset.Seconds()))
+			currentTimeByTimezone[legacyTimezone] = FormatTime(now.In(location), opts.TimeFormat)
 		}
 	}
-	return currentTimeByTimezone
+
+	zones := timezone.CountryZones(details.Cca2)
+	if cityZone != "" && !slices.Contains(zones, cityZone) {
+		zones = append([]string{cityZone}, zones...)
+	}
+
+	details.TimeZone = cityZone
+	if details.TimeZone == "" && len(zones) > 0 {
+		details.TimeZone = zones[0]
+	}
+
+	// IANA zones are kept apart from the restcountries offsets, whose keys
+	// stay fixed while the zone's offset changes with DST.
+	currentTimeByZone := make(map[string]string, len(zones))
+	details.Timezones = nil
+	for _, name := range zones {
+		info, err := timezone.Describe(name, now)
+		if err != nil {
+			continue
+		}
+		applyTimezoneContext(&info, hours, hoursErr, opts.HomeLocation)
+		details.Timezones = append(details.Timezones, info)
+
+		currentTimeByZone[info.Name] = FormatTime(info.CurrentTime, opts.TimeFormat)
+	}
+
+	details.CurrentTimeByTimezone = currentTimeByTimezone
+	details.CurrentTimeByZone = currentTimeByZone
 }
 
-func getCountryFromCache(ctx context.Context, cache interfaces.Cache, isoCode string) (Country, error) {
+func getCountriesFromCache(ctx context.Context, cache interfaces.Cache) ([]Country, error) {
 	data, err := cache.Get(ctx, "countries")
 	if err != nil || data == nil {
-		return Country{}, fmt.Errorf("error getting or country data not found in cache")
+		return nil, fmt.Errorf("error getting or country data not found in cache")
 	}
 
 	var countries []Country
 	if err := json.Unmarshal(data, &countries); err != nil {
-		return Country{}, fmt.Errorf("error unmarshalling country data: %w", err)
+		return nil, fmt.Errorf("error unmarshalling country data: %w", err)
+	}
+
+	return countries, nil
+}
+
+func getCountryFromCache(ctx context.Context, cache interfaces.Cache, isoCode string) (Country, error) {
+	countries, err := getCountriesFromCache(ctx, cache)
+	if err != nil {
+		return Country{}, err
 	}
 
 	for _, country := range countries {
@@ -202,88 +267,73 @@ func getRatesDataFromCache(ctx context.Context, cache interfaces.Cache) (RatesDa
 	return ratesData, nil
 }
 
-func calculateRelativeRates(currencies map[string]Currency, rates map[string]float64, usdRate float64) map[string]float64 {
-	relativeRates := make(map[string]float64)
-	for code := range currencies {
-		if rate, exists := rates[code]; exists {
-			relativeRates[code] = rate / usdRate
-		}
+func (opts IPLocationOptions) baseCurrencies() []string {
+	bases := opts.BaseCurrencies
+	if len(bases) == 0 {
+		bases = viper.GetStringSlice("rates.base_currencies")
 	}
-	return relativeRates
-}
-
-func updateDistanceStats(ctx context.Context, cache interfaces.Cache, countryLatLng []float64, countryName string) {
-	if len(countryLatLng) < 2 {
-		fmt.Println("Error: countryLatLng does not contain valid coordinates")
-		return
+	if len(bases) == 0 {
+		bases = []string{"USD"}
 	}
 
-	buenosAiresLat, buenosAiresLng, err := getBuenosAiresLatLng()
-	if err != nil {
-		fmt.Printf("Error getting Buenos Aires coordinates: %v\n", err)
-		return
+	normalized := make([]string, 0, len(bases))
+	for _, base := range bases {
+		if base = strings.ToUpper(strings.TrimSpace(base)); base != "" {
+			normalized = append(normalized, base)
+		}
 	}
+	return normalized
+}
 
-	distance := calculateDistance(buenosAiresLat, buenosAiresLng, countryLatLng[0], countryLatLng[1])
-
-	stats, err := GetDistanceStatsFromCache(ctx, cache)
+func applyRelativeRates(ctx context.Context, cache interfaces.Cache, details *IPLocationDetails, baseCurrencies []string, date string) error {
+	ratesData, err := getRatesData(ctx, cache, date)
 	if err != nil {
-		stats = &DistanceStats{
-			FarthestDistance:    distance,
-			ClosestDistance:     distance,
-			TotalDistance:       distance,
-			TotalRequests:       1,
-			FarthestCountryName: countryName,
-			ClosestCountryName:  countryName,
-			CountryDistances:    make(map[string]CountryDistance),
-		}
-	} else {
-		stats.TotalRequests++
-		stats.TotalDistance += distance
-		if distance > stats.FarthestDistance {
-			stats.FarthestDistance = distance
-			stats.FarthestCountryName = countryName
-		}
-		if distance < stats.ClosestDistance || stats.ClosestDistance == 0 {
-			stats.ClosestDistance = distance
-			stats.ClosestCountryName = countryName
-		}
+		return err
 	}
 
-	countryStats := stats.CountryDistances[countryName]
-	countryStats.TotalDistance += distance
-	countryStats.Requests++
-	stats.CountryDistances[countryName] = countryStats
-
-	err = cacheDistanceStats(ctx, cache, stats)
+	ratesMatrix, err := calculateRatesMatrix(details.Currencies, ratesData.Rates, baseCurrencies)
 	if err != nil {
-		fmt.Printf("Error caching distance stats: %v\n", err)
+		return err
 	}
+
+	details.RatesInfo = newRatesInfo(ratesData)
+	details.BaseCurrency = baseCurrencies[0]
+	details.RelativeRates = ratesMatrix[details.BaseCurrency]
+	details.RatesMatrix = ratesMatrix
+	return nil
 }
 
-func GetDistanceStatsFromCache(ctx context.Context, cache interfaces.Cache) (*DistanceStats, error) {
-	data, err := cache.Get(ctx, "distance_stats")
-	if err != nil || data == nil {
-		return nil, err
+func calculateRatesMatrix(currencies map[string]Currency, rates map[string]float64, baseCurrencies []string) (map[string]map[string]float64, error) {
+	if len(baseCurrencies) == 0 {
+		return nil, fmt.Errorf("no base currency configured")
 	}
 
-	var stats DistanceStats
-	if err := json.Unmarshal(data, &stats); err != nil {
-		return nil, fmt.Errorf("error unmarshalling distance stats from cache: %w", err)
+	ratesMatrix := make(map[string]map[string]float64, len(baseCurrencies))
+	for _, base := range baseCurrencies {
+		baseRate, exists := rates[base]
+		if !exists {
+			return nil, fmt.Errorf("%w: base currency %s", ErrCurrencyNotFound, base)
+		}
+		ratesMatrix[base] = calculateRelativeRates(currencies, rates, baseRate)
 	}
-
-	return &stats, nil
+	return ratesMatrix, nil
 }
 
-func cacheDistanceStats(ctx context.Context, cache interfaces.Cache, stats *DistanceStats) error {
-	data, err := json.Marshal(stats)
-	if err != nil {
-		return fmt.Errorf("error marshalling distance stats for cache: %w", err)
+func calculateRelativeRates(currencies map[string]Currency, rates map[string]float64, baseRate float64) map[string]float64 {
+	relativeRates := make(map[string]float64)
+	for code := range currencies {
+		if rate, exists := rates[code]; exists {
+			relativeRates[code] = rate / baseRate
+		}
 	}
-
-	return cache.Set(ctx, "distance_stats", data, viper.GetDuration("cache.distance_stats.ttl"))
+	return relativeRates
 }
 
+// calculateDistance returns the WGS84 distance in kilometers, the same one
+// reported by the distance endpoints.
+// calculateDistance keeps the original haversine distance on a 6371 km
+// sphere, so the distances in /api/ip and the stats built from them stay
+// comparable with earlier releases. /api/distance uses the WGS84 geodesic.
 func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const earthRadiusKm = 6371.0
 	dLat := degreesToRadians(lat2 - lat1)
@@ -301,19 +351,23 @@ func degreesToRadians(deg float64) float64 {
 	return deg * math.Pi / 180
 }
 
-func parseTimezoneOffset(timezone string) (time.Duration, error) {
-	if len(timezone) < 9 || timezone[:3] != "UTC" {
+func parseTimezoneOffset(legacyTimezone string) (time.Duration, error) {
+	if legacyTimezone == "UTC" {
+		return 0, nil
+	}
+
+	if len(legacyTimezone) < 9 || legacyTimezone[:3] != "UTC" {
 		return 0, fmt.Errorf("invalid timezone format")
 	}
 
 	sign := 1
-	if timezone[3] == '-' {
+	if legacyTimezone[3] == '-' {
 		sign = -1
 	}
 
 	hours := 0
 	minutes := 0
-	_, err := fmt.Sscanf(timezone[4:], "%02d:%02d", &hours, &minutes)
+	_, err := fmt.Sscanf(legacyTimezone[4:], "%02d:%02d", &hours, &minutes)
 	if err != nil {
 		return 0, err
 	}
@@ -333,8 +387,8 @@ func getBuenosAiresLatLng() (float64, float64, error) {
 	return lat, lng, nil
 }
 
-func calculateDistanceToBuenosAires(countryLatLng []float64) float64 {
-	if len(countryLatLng) < 2 {
+func calculateDistanceToBuenosAires(latLng []float64) float64 {
+	if len(latLng) < 2 {
 		return 0
 	}
 
@@ -344,21 +398,5 @@ func calculateDistanceToBuenosAires(countryLatLng []float64) float64 {
 		return 0
 	}
 
-	return calculateDistance(buenosAiresLat, buenosAiresLng, countryLatLng[0], countryLatLng[1])
-}
-
-func CalculateWeightedAverageDistance(stats *DistanceStats) float64 {
-	totalWeightedDistance := 0.0
-	totalRequests := 0
-
-	for _, countryData := range stats.CountryDistances {
-		totalWeightedDistance += countryData.TotalDistance
-		totalRequests += countryData.Requests
-	}
-
-	if totalRequests == 0 {
-		return 0
-	}
-
-	return totalWeightedDistance / float64(totalRequests)
+	return calculateDistance(buenosAiresLat, buenosAiresLng, latLng[0], latLng[1])
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidDiagnosticsRequest = errors.New("invalid diagnostics request")
+
+const (
+	speedOfLightKmPerMs    = 299.792458
+	defaultRefractiveIndex = 1.468
+)
+
+type LatencyBound struct {
+	Distance ReferenceDistance
+	// VacuumRTTMs is the round trip at the speed of light in vacuum; the
+	// fiber bounds divide that speed by the refractive index of the fiber
+	// and use the accuracy band of the looked-up coordinates.
+	VacuumRTTMs   float64
+	FiberRTTMs    float64
+	MinFiberRTTMs float64
+	MaxFiberRTTMs float64
+}
+
+type LatencyDiagnostics struct {
+	Origin          DistanceEndpoint
+	RefractiveIndex float64
+	Bounds          []LatencyBound
+}
+
+// LatencyBounds converts great-circle distances into theoretical minimum
+// round-trip times; real paths are longer, so measured RTTs below these
+// values indicate a wrong geolocation.
+func LatencyBounds(distances []ReferenceDistance) []LatencyBound {
+	index := refractiveIndex()
+
+	bounds := make([]LatencyBound, 0, len(distances))
+	for _, distance := range distances {
+		bounds = append(bounds, LatencyBound{
+			Distance:      distance,
+			VacuumRTTMs:   roundTripMs(distance.DistanceKm, 1),
+			FiberRTTMs:    roundTripMs(distance.DistanceKm, index),
+			MinFiberRTTMs: roundTripMs(distance.MinKm, index),
+			MaxFiberRTTMs: roundTripMs(distance.MaxKm, index),
+		})
+	}
+	return bounds
+}
+
+func GetLatencyDiagnostics(redisCache interfaces.Cache, httpClient interfaces.Client, origin string, referenceValues []string) (*LatencyDiagnostics, error) {
+	references, err := ResolveReferenceLocations(referenceValues)
+	if err != nil {
+		return nil, err
+	}
+
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	endpoint, err := resolveDistanceEndpoint(ctx, redisCache, ipLocation, origin)
+	if errors.Is(err, ErrInvalidDistanceRequest) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiagnosticsRequest, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &LatencyDiagnostics{
+		Origin:          endpoint,
+		RefractiveIndex: refractiveIndex(),
+		Bounds:          LatencyBounds(calculateReferenceDistances(references, endpoint.Coordinates)),
+	}, nil
+}
+
+func refractiveIndex() float64 {
+	if index := viper.GetFloat64("latency.fiber_refractive_index"); index >= 1 {
+		return index
+	}
+	return defaultRefractiveIndex
+}
+
+func roundTripMs(distanceKm, refractiveIndex float64) float64 {
+	return 2 * distanceKm / (speedOfLightKmPerMs / refractiveIndex)
+}
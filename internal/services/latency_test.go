@@ -0,0 +1,94 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func TestRoundTripMs(t *testing.T) {
+	tests := []struct {
+		name            string
+		distanceKm      float64
+		refractiveIndex float64
+		want            float64
+	}{
+		// Light covers 299.792458 km per millisecond in vacuum.
+		{"vacuum", 299.792458, 1, 2},
+		{"fiber", 299.792458, 1.5, 3},
+		{"zero distance", 0, defaultRefractiveIndex, 0},
+		// Buenos Aires to Madrid, ~10,040 km, takes at least ~98 ms in fiber.
+		{"buenos aires to madrid", 10040, defaultRefractiveIndex, 2 * 10040 * defaultRefractiveIndex / 299.792458},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundTripMs(tt.distanceKm, tt.refractiveIndex); !closeTo(got, tt.want) {
+				t.Fatalf("roundTripMs(%v, %v) = %v, want %v", tt.distanceKm, tt.refractiveIndex, got, tt.want)
+			}
+		})
+	}
+
+	if got := roundTripMs(10040, defaultRefractiveIndex); got < 98 || got > 99 {
+		t.Fatalf("expected ~98 ms between Buenos Aires and Madrid, got %v", got)
+	}
+}
+
+func TestRefractiveIndex(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured interface{}
+		want       float64
+	}{
+		{"default", nil, defaultRefractiveIndex},
+		{"configured", 1.5, 1.5},
+		{"below vacuum", 0.8, defaultRefractiveIndex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("latency.fiber_refractive_index", tt.configured)
+			t.Cleanup(viper.Reset)
+
+			if got := refractiveIndex(); got != tt.want {
+				t.Fatalf("refractiveIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyBounds(t *testing.T) {
+	viper.Set("latency.fiber_refractive_index", 1.5)
+	t.Cleanup(viper.Reset)
+
+	distances := []ReferenceDistance{
+		{Reference: ReferenceLocation{Key: "exact"}, DistanceKm: 299.792458, MinKm: 299.792458, MaxKm: 299.792458},
+		{Reference: ReferenceLocation{Key: "band"}, DistanceKm: 2997.92458, MinKm: 2898.0, MaxKm: 3097.0},
+	}
+
+	bounds := LatencyBounds(distances)
+	if len(bounds) != 2 {
+		t.Fatalf("got %d bounds, want 2", len(bounds))
+	}
+
+	if exact := bounds[0]; !closeTo(exact.VacuumRTTMs, 2) || !closeTo(exact.FiberRTTMs, 3) ||
+		!closeTo(exact.MinFiberRTTMs, 3) || !closeTo(exact.MaxFiberRTTMs, 3) {
+		t.Fatalf("unexpected bounds %+v", exact)
+	}
+
+	band := bounds[1]
+	if !closeTo(band.VacuumRTTMs, 20) || !closeTo(band.FiberRTTMs, 30) {
+		t.Fatalf("unexpected bounds %+v", band)
+	}
+	if !closeTo(band.MinFiberRTTMs, roundTripMs(2898, 1.5)) || !closeTo(band.MaxFiberRTTMs, roundTripMs(3097, 1.5)) {
+		t.Fatalf("the fiber band %v - %v does not follow the distance band", band.MinFiberRTTMs, band.MaxFiberRTTMs)
+	}
+	if band.MinFiberRTTMs > band.FiberRTTMs || band.FiberRTTMs > band.MaxFiberRTTMs || band.VacuumRTTMs > band.MinFiberRTTMs {
+		t.Fatalf("expected vacuum <= min <= fiber <= max, got %+v", band)
+	}
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidLeaderboard = errors.New("invalid leaderboard request")
+
+const (
+	LeaderboardCountry = "country"
+	LeaderboardNetwork = "network"
+	LeaderboardASN     = "asn"
+	LeaderboardCaller  = "caller"
+
+	leaderboardKeyPrefix = "stats:top:"
+	defaultLeaderboardN  = 10
+)
+
+var leaderboardDimensions = []string{LeaderboardCountry, LeaderboardNetwork, LeaderboardASN, LeaderboardCaller}
+
+type LeaderboardRequest struct {
+	Dimension string
+	N         int
+	// Window is how far back to look, e.g. "24h", or a start time accepted by
+	// ParseStatsTime. Empty means since the statistics were created.
+	Window string
+}
+
+type LeaderboardEntry struct {
+	Rank     int
+	Member   string
+	Requests int
+}
+
+type Leaderboard struct {
+	Dimension   string
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Entries     []LeaderboardEntry
+}
+
+// leaderboardKey returns the all-time sorted set for a dimension. Countries
+// reuse the per-country request counts of the distance statistics.
+func leaderboardKey(dimension string) string {
+	if dimension == LeaderboardCountry {
+		return countryRequestsStatsKey
+	}
+	return leaderboardKeyPrefix + dimension
+}
+
+func leaderboardBucketKey(dimension, granularity string, start time.Time) string {
+	return leaderboardKeyPrefix + dimension + ":" + granularity + ":" + strconv.FormatInt(start.Unix(), 10)
+}
+
+// networkOf aggregates an address to its /24 (IPv4) or /48 (IPv6) network,
+// the usual size of a customer or provider allocation.
+func networkOf(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	mask := net.CIDRMask(48, 128)
+	if v4 := parsed.To4(); v4 != nil {
+		parsed, mask = v4, net.CIDRMask(24, 32)
+	}
+	return (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String()
+}
+
+// asnDatabase is the GeoLite2-ASN reader, opened on the first lookup and
+// reopened only when stats.asn_database changes.
+var asnDatabase struct {
+	sync.Mutex
+	path   string
+	reader *geoip2.Reader
+}
+
+// lookupASN reads the optional GeoLite2-ASN database configured in
+// stats.asn_database and returns "AS<number> <organization>".
+func lookupASN(ip string) (string, error) {
+	path := viper.GetString("stats.asn_database")
+	if path == "" {
+		return "", nil
+	}
+
+	db, err := openASNDatabase(path)
+	if err != nil {
+		return "", err
+	}
+
+	record, err := db.ASN(net.ParseIP(ip))
+	if err != nil || record == nil || record.AutonomousSystemNumber == 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(fmt.Sprintf("AS%d %s", record.AutonomousSystemNumber, record.AutonomousSystemOrganization)), nil
+}
+
+func openASNDatabase(path string) (*geoip2.Reader, error) {
+	asnDatabase.Lock()
+	defer asnDatabase.Unlock()
+
+	if asnDatabase.reader != nil && asnDatabase.path == path {
+		return asnDatabase.reader, nil
+	}
+
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening ASN database: %w", err)
+	}
+
+	if asnDatabase.reader != nil {
+		if err := asnDatabase.reader.Close(); err != nil {
+			fmt.Println("Error closing ASN database")
+		}
+	}
+	asnDatabase.path, asnDatabase.reader = path, db
+	return db, nil
+}
+
+// leaderboardTopLua defines top(key, member, max, ttl) for the scripts that
+// count leaderboard members, and refreshes the TTL when given. Sets keyed by
+// IP or network are bounded to max members with the Space-Saving algorithm:
+// a newcomer to a full set replaces the member with the lowest count and
+// inherits that count plus one, so a late heavy hitter still reaches the top.
+// A count overestimates its member by at most the count it inherited.
+const leaderboardTopLua = `
+local function top(key, member, max, ttl)
+	if member == '' then
+		return
+	end
+	if max > 0 and not redis.call('ZSCORE', key, member) and redis.call('ZCARD', key) >= max then
+		local evicted = redis.call('ZPOPMIN', key)
+		redis.call('ZADD', key, tonumber(evicted[2]) + 1, member)
+	else
+		redis.call('ZINCRBY', key, 1, member)
+	end
+	if ttl then
+		redis.call('EXPIRE', key, ttl)
+	end
+end
+`
+
+// leaderboardsPart counts the network and ASN in their all-time
+// leaderboards, and the country, network and ASN in each time bucket.
+var leaderboardsPart = statsRecordPart{
+	lua: leaderboardTopLua + `
+local network, asn, max = nextArg(), nextArg(), tonumber(nextArg())
+top(nextKey(), network, max)
+top(nextKey(), asn, max)
+for _ = 1, tonumber(nextArg()) do
+	local ttl = nextArg()
+	top(nextKey(), country, 0, ttl)
+	top(nextKey(), network, max, ttl)
+	top(nextKey(), asn, max, ttl)
+end
+`,
+	args: func(lookup statsLookup) ([]string, []interface{}) {
+		asn, err := lookupASN(lookup.ip)
+		if err != nil {
+			fmt.Printf("Error looking up ASN: %v\n", err)
+		}
+
+		keys := []string{leaderboardKey(LeaderboardNetwork), leaderboardKey(LeaderboardASN)}
+		buckets := lookup.buckets
+		args := []interface{}{networkOf(lookup.ip), asn, viper.GetInt("stats.top.max_members"), len(buckets)}
+		for _, bucket := range buckets {
+			keys = append(keys,
+				leaderboardBucketKey(LeaderboardCountry, bucket.granularity, bucket.start),
+				leaderboardBucketKey(LeaderboardNetwork, bucket.granularity, bucket.start),
+				leaderboardBucketKey(LeaderboardASN, bucket.granularity, bucket.start))
+			args = append(args, int64(bucket.ttl/time.Second))
+		}
+		return keys, args
+	},
+}
+
+// recordCallerScript counts a caller in the all-time leaderboard (KEYS[1])
+// and in each time bucket (KEYS[2..]).
+// ARGV: caller, maximum members kept, then the TTL in seconds of each bucket.
+const recordCallerScript = leaderboardTopLua + `
+local caller, max = ARGV[1], tonumber(ARGV[2])
+top(KEYS[1], caller, max)
+for i = 2, #KEYS do
+	top(KEYS[i], caller, max, ARGV[i + 1])
+end
+return 1
+`
+
+// callerJob is an API request waiting to be counted.
+type callerJob struct {
+	cache interfaces.Cache
+	ip    string
+	at    time.Time
+}
+
+var (
+	callerJobs   = make(chan callerJob, 1024)
+	callerWorker sync.Once
+)
+
+// RecordCaller counts an API request towards the caller leaderboard. The
+// count is written in the background so requests never wait on Redis; when
+// the queue is full the request is not counted.
+func RecordCaller(redisCache interfaces.Cache, ip string) {
+	callerWorker.Do(func() {
+		go recordCallers()
+	})
+
+	select {
+	case callerJobs <- callerJob{cache: redisCache, ip: ip, at: time.Now()}:
+	default:
+	}
+}
+
+func recordCallers() {
+	for job := range callerJobs {
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+		if err := recordCaller(ctx, job.cache, job.at, visitorID(job.ip)); err != nil {
+			fmt.Printf("Error updating caller leaderboard: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+func recordCaller(ctx context.Context, cache interfaces.Cache, now time.Time, caller string) error {
+	if caller == "" {
+		return nil
+	}
+
+	keys := []string{leaderboardKey(LeaderboardCaller)}
+	args := []interface{}{caller, viper.GetInt("stats.top.max_members")}
+
+	// The buckets are the same as the distance statistics', so that
+	// leaderboards can be read for the same windows.
+	for _, g := range statsGranularities {
+		retention := granularityRetention(g.name)
+		if retention <= 0 {
+			continue
+		}
+		keys = append(keys, leaderboardBucketKey(LeaderboardCaller, g.name, now.UTC().Truncate(g.size)))
+		args = append(args, int64((retention+g.size)/time.Second))
+	}
+
+	_, err := cache.Eval(ctx, recordCallerScript, keys, args...)
+	return err
+}
+
+// topLeaderboardScript returns the ARGV[1] highest members of the union of
+// KEYS[2..], using KEYS[1] as a temporary key when there is more than one.
+const topLeaderboardScript = `
+local n = tonumber(ARGV[1])
+if #KEYS == 2 then
+	return redis.call('ZREVRANGE', KEYS[2], 0, n - 1, 'WITHSCORES')
+end
+redis.call('ZUNIONSTORE', KEYS[1], #KEYS - 1, unpack(KEYS, 2))
+local result = redis.call('ZREVRANGE', KEYS[1], 0, n - 1, 'WITHSCORES')
+redis.call('DEL', KEYS[1])
+return result
+`
+
+var leaderboardUnions atomic.Uint64
+
+func GetLeaderboard(redisCache interfaces.Cache, req LeaderboardRequest) (*Leaderboard, error) {
+	if !isLeaderboardDimension(req.Dimension) {
+		return nil, fmt.Errorf("%w: unknown dimension %q (use %s)", ErrInvalidLeaderboard, req.Dimension, strings.Join(leaderboardDimensions, ", "))
+	}
+	if req.N < 0 {
+		return nil, fmt.Errorf("%w: n must be positive", ErrInvalidLeaderboard)
+	}
+	if req.N == 0 {
+		req.N = defaultLeaderboardN
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	leaderboard := &Leaderboard{Dimension: req.Dimension}
+	// The first key is the temporary destination of the union.
+	keys := []string{fmt.Sprintf("%sunion:%d:%d", leaderboardKeyPrefix, time.Now().UnixNano(), leaderboardUnions.Add(1))}
+
+	if req.Window == "" {
+		keys = append(keys, leaderboardKey(req.Dimension))
+	} else {
+		now := time.Now()
+		from, err := ParseStatsTime(req.Window, now)
+		if err != nil {
+			return nil, err
+		}
+
+		granularity, starts, err := statsWindowStarts(from, now, "")
+		if err != nil {
+			return nil, err
+		}
+		leaderboard.From, leaderboard.To, leaderboard.Granularity = from, now, granularity
+
+		for _, start := range starts {
+			keys = append(keys, leaderboardBucketKey(req.Dimension, granularity, start))
+		}
+	}
+
+	result, err := redisCache.Eval(ctx, topLeaderboardScript, keys, req.N)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s leaderboard: %w", req.Dimension, err)
+	}
+
+	reply, _ := result.([]interface{})
+	for i := 0; i+1 < len(reply); i += 2 {
+		member, _ := reply[i].(string)
+		score, _ := reply[i+1].(string)
+		leaderboard.Entries = append(leaderboard.Entries, LeaderboardEntry{Member: member, Requests: int(parseStatFloat(score))})
+	}
+	sort.SliceStable(leaderboard.Entries, func(i, j int) bool {
+		a, b := leaderboard.Entries[i], leaderboard.Entries[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Member < b.Member
+	})
+	for i := range leaderboard.Entries {
+		leaderboard.Entries[i].Rank = i + 1
+	}
+
+	return leaderboard, nil
+}
+
+func isLeaderboardDimension(dimension string) bool {
+	for _, d := range leaderboardDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestNetworkOf(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"190.2.1.10", "190.2.1.0/24"},
+		{"::ffff:190.2.1.10", "190.2.1.0/24"},
+		{"2001:db8:abcd:12::1", "2001:db8:abcd::/48"},
+		{"not an ip", ""},
+	}
+
+	for _, tt := range tests {
+		if got := networkOf(tt.ip); got != tt.want {
+			t.Errorf("networkOf(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	viper.Set("context.timeout", time.Second)
+	viper.Set("stats.retention.minute", 3*time.Hour)
+	viper.Set("stats.retention.hour", 0)
+	viper.Set("stats.retention.day", 0)
+	viper.Set("stats.max_buckets", 1500)
+	viper.Set("stats.top.max_members", 3)
+	t.Cleanup(viper.Reset)
+
+	redisCache := newTestCache(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	calls := map[string]int{"1.1.1.1": 5, "2.2.2.2": 3, "3.3.3.3": 2}
+	for caller, count := range calls {
+		for i := 0; i < count; i++ {
+			// Half of the calls land in an earlier minute bucket.
+			at := now
+			if i%2 == 1 {
+				at = now.Add(-10 * time.Minute)
+			}
+			if err := recordCaller(ctx, redisCache, at, caller); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// A late heavy hitter takes over the lowest member of each full set, and
+	// its count, so it reaches the top instead of being evicted on arrival.
+	for i := 0; i < 6; i++ {
+		if err := recordCaller(ctx, redisCache, now, "4.4.4.4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		window string
+		n      int
+		want   []LeaderboardEntry
+	}{
+		{name: "all time", want: []LeaderboardEntry{
+			{1, "4.4.4.4", 8}, {2, "1.1.1.1", 5}, {3, "2.2.2.2", 3},
+		}},
+		{name: "top two", n: 2, want: []LeaderboardEntry{
+			{1, "4.4.4.4", 8}, {2, "1.1.1.1", 5},
+		}},
+		{name: "last hour", window: "1h", want: []LeaderboardEntry{
+			{1, "4.4.4.4", 7}, {2, "1.1.1.1", 5}, {3, "2.2.2.2", 3}, {4, "3.3.3.3", 1},
+		}},
+		{name: "last five minutes", window: "5m", want: []LeaderboardEntry{
+			{1, "4.4.4.4", 7}, {2, "1.1.1.1", 3}, {3, "2.2.2.2", 2},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaderboard, err := GetLeaderboard(redisCache, LeaderboardRequest{Dimension: LeaderboardCaller, N: tt.n, Window: tt.window})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leaderboard.Entries) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", leaderboard.Entries, tt.want)
+			}
+			for i := range tt.want {
+				if leaderboard.Entries[i] != tt.want[i] {
+					t.Fatalf("got %+v, want %+v", leaderboard.Entries, tt.want)
+				}
+			}
+		})
+	}
+
+	if keys, _ := redisCache.Keys(ctx, leaderboardKeyPrefix+"union:*"); len(keys) != 0 {
+		t.Fatalf("temporary union keys were left behind: %v", keys)
+	}
+
+	if _, err := GetLeaderboard(redisCache, LeaderboardRequest{Dimension: "planet"}); !errors.Is(err, ErrInvalidLeaderboard) {
+		t.Fatalf("expected ErrInvalidLeaderboard, got %v", err)
+	}
+}
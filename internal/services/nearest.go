@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/geodesy"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/spatial"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidNearestRequest = errors.New("invalid nearest request")
+
+const (
+	PlaceKindCountry = "country"
+	PlaceKindCapital = "capital"
+)
+
+type NearestRequest struct {
+	Origin string
+	N      int
+	Kinds  []string
+}
+
+type NearestPlace struct {
+	Name        string
+	Kind        string
+	CountryCode string
+	Latitude    float64
+	Longitude   float64
+	DistanceKm  float64
+	Bearing     float64
+	Compass     string
+}
+
+type NearestResult struct {
+	Origin DistanceEndpoint
+	Places []NearestPlace
+}
+
+type customPoint struct {
+	Name      string  `mapstructure:"name"`
+	Kind      string  `mapstructure:"kind"`
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+}
+
+// FindNearest ranks the country centroids, capitals and custom points closest
+// to an IP address or "lat,lng" origin.
+func FindNearest(redisCache interfaces.Cache, httpClient interfaces.Client, request NearestRequest) (*NearestResult, error) {
+	n := request.N
+	if n <= 0 {
+		n = viper.GetInt("nearest.default_n")
+	}
+	if maxN := viper.GetInt("nearest.max_n"); maxN > 0 && n > maxN {
+		return nil, fmt.Errorf("%w: n must be at most %d", ErrInvalidNearestRequest, maxN)
+	}
+	if request.Origin == "" {
+		return nil, fmt.Errorf("%w: an IP address or lat,lng origin is required", ErrInvalidNearestRequest)
+	}
+
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	origin, err := resolveDistanceEndpoint(ctx, redisCache, ipLocation, request.Origin)
+	if errors.Is(err, ErrInvalidDistanceRequest) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNearestRequest, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := nearestIndex(ctx, redisCache, request.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	originPoint := geodesy.Point{Latitude: origin.Coordinates.Latitude, Longitude: origin.Coordinates.Longitude}
+
+	result := &NearestResult{Origin: origin}
+	for _, neighbor := range index.Nearest(originPoint.Latitude, originPoint.Longitude, n) {
+		point := neighbor.Point
+		inverse := geodesy.Distance(originPoint, geodesy.Point{Latitude: point.Latitude, Longitude: point.Longitude})
+		result.Places = append(result.Places, NearestPlace{
+			Name:        point.Name,
+			Kind:        point.Kind,
+			CountryCode: point.ID,
+			Latitude:    point.Latitude,
+			Longitude:   point.Longitude,
+			DistanceKm:  geodesy.Kilometers.FromMeters(inverse.DistanceMeters),
+			Bearing:     inverse.InitialBearing,
+			Compass:     geodesy.Compass(inverse.InitialBearing),
+		})
+	}
+
+	sort.SliceStable(result.Places, func(i, j int) bool { return result.Places[i].DistanceKm < result.Places[j].DistanceKm })
+	return result, nil
+}
+
+// nearestIndexes keeps the points loaded from the current countries dataset
+// and points file and the k-d trees built from them, one per requested
+// combination of kinds, so they are only rebuilt when either input changes.
+// Kinds are validated against the loaded points, which bounds the number of
+// trees by the combinations of known kinds.
+var nearestIndexes struct {
+	sync.Mutex
+	version string
+	points  []spatial.Point
+	kinds   []string
+	byKinds map[string]*spatial.Index
+}
+
+func nearestIndex(ctx context.Context, cache interfaces.Cache, kinds []string) (*spatial.Index, error) {
+	version, data, err := nearestDatasetVersion(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	wanted := nearestKinds(kinds)
+
+	nearestIndexes.Lock()
+	defer nearestIndexes.Unlock()
+
+	if nearestIndexes.version != version || nearestIndexes.byKinds == nil {
+		if data == nil {
+			if data, err = cache.Get(ctx, "countries"); err != nil || data == nil {
+				return nil, fmt.Errorf("error getting or country data not found in cache")
+			}
+		}
+
+		var countries []Country
+		if err := json.Unmarshal(data, &countries); err != nil {
+			return nil, fmt.Errorf("error unmarshalling country data: %w", err)
+		}
+
+		points, err := loadNearestPoints(countries, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		nearestIndexes.version = version
+		nearestIndexes.points = points
+		nearestIndexes.kinds = pointKinds(points)
+		nearestIndexes.byKinds = map[string]*spatial.Index{"": spatial.NewIndex(points)}
+	}
+
+	for _, kind := range wanted {
+		if !slices.Contains(nearestIndexes.kinds, kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q (use %s)", ErrInvalidNearestRequest, kind, strings.Join(nearestIndexes.kinds, ", "))
+		}
+	}
+
+	key := strings.Join(wanted, ",")
+	if index, exists := nearestIndexes.byKinds[key]; exists {
+		return index, nil
+	}
+
+	var points []spatial.Point
+	for _, point := range nearestIndexes.points {
+		if slices.Contains(wanted, point.Kind) {
+			points = append(points, point)
+		}
+	}
+
+	index := spatial.NewIndex(points)
+	nearestIndexes.byKinds[key] = index
+	return index, nil
+}
+
+// nearestDatasetVersion combines the version marker stored with the
+// countries dataset and the points file's size and modification time, which
+// are the only inputs of the index. Countries cached without a marker are
+// fingerprinted here, and their data returned so it is not read twice.
+func nearestDatasetVersion(ctx context.Context, cache interfaces.Cache) (string, []byte, error) {
+	var data []byte
+	version, err := cache.Get(ctx, datasetVersionKey("countries"))
+	if err != nil || version == nil {
+		data, err = cache.Get(ctx, "countries")
+		if err != nil || data == nil {
+			return "", nil, fmt.Errorf("error getting or country data not found in cache")
+		}
+		version = []byte(datasetVersion(data))
+	}
+
+	if path := viper.GetString("nearest.points_file"); path != "" {
+		if info, err := os.Stat(path); err == nil {
+			version = fmt.Appendf(version, ":%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return string(version), data, nil
+}
+
+func pointKinds(points []spatial.Point) []string {
+	kinds := []string{PlaceKindCapital, PlaceKindCountry}
+	for _, point := range points {
+		if !slices.Contains(kinds, point.Kind) {
+			kinds = append(kinds, point.Kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func nearestKinds(kinds []string) []string {
+	var wanted []string
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind != "" && !slices.Contains(wanted, kind) {
+			wanted = append(wanted, kind)
+		}
+	}
+	sort.Strings(wanted)
+	return wanted
+}
+
+func loadNearestPoints(countries []Country, kinds []string) ([]spatial.Point, error) {
+	include := func(kind string) bool {
+		return len(kinds) == 0 || slices.Contains(kinds, kind)
+	}
+
+	var points []spatial.Point
+	for _, country := range countries {
+		if include(PlaceKindCountry) && len(country.LatLng) >= 2 {
+			points = append(points, spatial.Point{
+				ID:        country.Cca2,
+				Name:      country.Name.Common,
+				Kind:      PlaceKindCountry,
+				Latitude:  country.LatLng[0],
+				Longitude: country.LatLng[1],
+			})
+		}
+		if include(PlaceKindCapital) && len(country.Capital) > 0 && len(country.CapitalInfo.LatLng) >= 2 {
+			points = append(points, spatial.Point{
+				ID:        country.Cca2,
+				Name:      country.Capital[0],
+				Kind:      PlaceKindCapital,
+				Latitude:  country.CapitalInfo.LatLng[0],
+				Longitude: country.CapitalInfo.LatLng[1],
+			})
+		}
+	}
+
+	custom, err := loadCustomPoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range custom {
+		if include(point.Kind) {
+			points = append(points, point)
+		}
+	}
+
+	return points, nil
+}
+
+// loadCustomPoints reads the optional nearest.points_file, e.g. regional
+// offices, with the same layout as points.example.yaml.
+func loadCustomPoints() ([]spatial.Point, error) {
+	path := viper.GetString("nearest.points_file")
+	if path == "" {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	pointsConfig := viper.New()
+	pointsConfig.SetConfigFile(path)
+	if err := pointsConfig.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading points from %s: %w", path, err)
+	}
+
+	var entries []customPoint
+	if err := pointsConfig.UnmarshalKey("points", &entries); err != nil {
+		return nil, fmt.Errorf("error decoding points from %s: %w", path, err)
+	}
+
+	points := make([]spatial.Point, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Name == "" || entry.Latitude < -90 || entry.Latitude > 90 || entry.Longitude < -180 || entry.Longitude > 180 {
+			return nil, fmt.Errorf("point %d (%s) needs a name and valid coordinates", i, entry.Name)
+		}
+		if entry.Kind == "" {
+			entry.Kind = "point"
+		}
+		points = append(points, spatial.Point{
+			Name:      entry.Name,
+			Kind:      strings.ToLower(entry.Kind),
+			Latitude:  entry.Latitude,
+			Longitude: entry.Longitude,
+		})
+	}
+
+	return points, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const nearestCountries = `[
+	{"name":{"common":"Argentina"},"cca2":"AR","latlng":[-34,-64],"capital":["Buenos Aires"],"capitalInfo":{"latlng":[-34.58,-58.67]}},
+	{"name":{"common":"Uruguay"},"cca2":"UY","latlng":[-33,-56],"capital":["Montevideo"],"capitalInfo":{"latlng":[-34.85,-56.17]}},
+	{"name":{"common":"Spain"},"cca2":"ES","latlng":[40,-4],"capital":["Madrid"],"capitalInfo":{"latlng":[40.4,-3.68]}}
+]`
+
+func TestNearestIndexIsCachedPerDatasetVersion(t *testing.T) {
+	viper.Set("nearest.points_file", "")
+	t.Cleanup(viper.Reset)
+
+	ctx := context.Background()
+	redisCache := newTestCache(t)
+	if err := redisCache.Set(ctx, "countries", nearestCountries, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := nearestIndex(ctx, redisCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Len() != 6 {
+		t.Fatalf("expected countries and capitals, got %d points", all.Len())
+	}
+
+	again, err := nearestIndex(ctx, redisCache, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != all {
+		t.Fatal("expected the cached index to be reused")
+	}
+
+	capitals, err := nearestIndex(ctx, redisCache, []string{" Capital ", "capital"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capitals == all || capitals.Len() != 3 {
+		t.Fatalf("expected a separate capitals index, got %d points", capitals.Len())
+	}
+
+	if err := redisCache.Set(ctx, "countries", nearestCountries[:len(nearestCountries)-1]+`,
+		{"name":{"common":"Chile"},"cca2":"CL","latlng":[-30,-71],"capital":["Santiago"],"capitalInfo":{"latlng":[-33.45,-70.67]}}]`, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := nearestIndex(ctx, redisCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed == all || refreshed.Len() != 8 {
+		t.Fatalf("expected a rebuilt index after the dataset changed, got %d points", refreshed.Len())
+	}
+}
+
+func TestDatasetOutdated(t *testing.T) {
+	ctx := context.Background()
+	redisCache := newTestCache(t)
+	service := NewDefaultPrefetchDataService(redisCache, nil)
+
+	tests := []struct {
+		name     string
+		key      string
+		data     string
+		version  bool
+		outdated bool
+	}{
+		{"countries with capitals", "countries", nearestCountries, true, false},
+		{"countries cached before capitals", "countries", `[{"name":{"common":"Argentina"},"cca2":"AR","latlng":[-34,-64],"capital":["Buenos Aires"]}]`, true, true},
+		{"countries without a version marker", "countries", nearestCountries, false, true},
+		{"other datasets", "currencies", `{"rates":{}}`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := redisCache.Set(ctx, tt.key, tt.data, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := redisCache.Del(ctx, datasetVersionKey(tt.key)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.version {
+				if err := redisCache.Set(ctx, datasetVersionKey(tt.key), datasetVersion([]byte(tt.data)), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := service.datasetOutdated(ctx, tt.key); got != tt.outdated {
+				t.Fatalf("datasetOutdated = %v, want %v", got, tt.outdated)
+			}
+		})
+	}
+}
+
+func TestNearestIndexUsesVersionMarker(t *testing.T) {
+	viper.Set("nearest.points_file", "")
+	t.Cleanup(viper.Reset)
+
+	ctx := context.Background()
+	redisCache := newTestCache(t)
+	if err := redisCache.Set(ctx, "countries", nearestCountries, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisCache.Set(ctx, datasetVersionKey("countries"), "v1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := nearestIndex(ctx, redisCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the marker is read while it is unchanged, even if the countries
+	// key itself is gone.
+	if err := redisCache.Del(ctx, "countries"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := nearestIndex(ctx, redisCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Fatal("expected the index to be reused while the marker is unchanged")
+	}
+
+	if err := redisCache.Set(ctx, datasetVersionKey("countries"), "v2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nearestIndex(ctx, redisCache, nil); err == nil {
+		t.Fatal("expected a new marker to reload the countries")
+	}
+}
+
+func TestNearestIndexRejectsUnknownKinds(t *testing.T) {
+	pointsFile := filepath.Join(t.TempDir(), "points.yaml")
+	points := "points:\n  - name: Madrid office\n    kind: Office\n    latitude: 40.42\n    longitude: -3.7\n"
+	if err := os.WriteFile(pointsFile, []byte(points), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("nearest.points_file", pointsFile)
+	t.Cleanup(viper.Reset)
+
+	ctx := context.Background()
+	redisCache := newTestCache(t)
+	if err := redisCache.Set(ctx, "countries", nearestCountries, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		kinds   []string
+		want    int
+		wantErr bool
+	}{
+		{name: "all kinds", want: 7},
+		{name: "custom kind", kinds: []string{"office"}, want: 1},
+		{name: "mixed kinds", kinds: []string{"office", "capital"}, want: 4},
+		{name: "unknown kind", kinds: []string{"office", "airport"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := nearestIndex(ctx, redisCache, tt.kinds)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidNearestRequest) {
+					t.Fatalf("expected ErrInvalidNearestRequest, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if index.Len() != tt.want {
+				t.Fatalf("got %d points, want %d", index.Len(), tt.want)
+			}
+		})
+	}
+
+	nearestIndexes.Lock()
+	cached := len(nearestIndexes.byKinds)
+	nearestIndexes.Unlock()
+	if cached != 3 {
+		t.Fatalf("expected only valid kind combinations to be cached, got %d indexes", cached)
+	}
+}
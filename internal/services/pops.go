@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/geodesy"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrInvalidPoPRequest = errors.New("invalid PoP request")
+	ErrNoPoPs            = errors.New("no enabled PoPs configured")
+)
+
+type PoP struct {
+	Name      string  `mapstructure:"name"`
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+	Weight    float64 `mapstructure:"weight"`
+	Enabled   *bool   `mapstructure:"enabled"`
+}
+
+type PoPPinningRule struct {
+	Countries []string `mapstructure:"countries"`
+	PoP       string   `mapstructure:"pop"`
+}
+
+type PoPRequest struct {
+	Origin   string
+	Weighted *bool
+	Limit    int
+}
+
+type RankedPoP struct {
+	PoP        PoP
+	DistanceKm float64
+	Score      float64
+	Pinned     bool
+}
+
+type PoPSelection struct {
+	Origin   DistanceEndpoint
+	Weighted bool
+	PoPs     []RankedPoP
+}
+
+func (p PoP) enabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+func LoadPoPs() ([]PoP, []PoPPinningRule, error) {
+	var pops []PoP
+	if err := viper.UnmarshalKey("pops.list", &pops); err != nil {
+		return nil, nil, fmt.Errorf("error decoding PoPs: %w", err)
+	}
+
+	names := make(map[string]bool, len(pops))
+	for i, pop := range pops {
+		if pop.Name == "" || pop.Latitude < -90 || pop.Latitude > 90 || pop.Longitude < -180 || pop.Longitude > 180 {
+			return nil, nil, fmt.Errorf("PoP %d (%s) needs a name and valid coordinates", i, pop.Name)
+		}
+		if pop.Weight < 0 {
+			return nil, nil, fmt.Errorf("PoP %s has a negative weight", pop.Name)
+		}
+		if pop.Weight == 0 {
+			pops[i].Weight = 1
+		}
+		names[pop.Name] = true
+	}
+
+	var pinning []PoPPinningRule
+	if err := viper.UnmarshalKey("pops.pinning", &pinning); err != nil {
+		return nil, nil, fmt.Errorf("error decoding PoP pinning rules: %w", err)
+	}
+	for i, rule := range pinning {
+		if !names[rule.PoP] {
+			return nil, nil, fmt.Errorf("pinning rule %d references unknown PoP %q", i, rule.PoP)
+		}
+	}
+
+	return pops, pinning, nil
+}
+
+// RankPoPs orders the enabled PoPs for a client. The score is the geodesic
+// distance, divided by the PoP weight when capacity weighting is on, and PoPs
+// pinned to the client's country always come first.
+func RankPoPs(redisCache interfaces.Cache, httpClient interfaces.Client, request PoPRequest) (*PoPSelection, error) {
+	if request.Origin == "" {
+		return nil, fmt.Errorf("%w: an IP address or lat,lng origin is required", ErrInvalidPoPRequest)
+	}
+
+	pops, pinning, err := LoadPoPs()
+	if err != nil {
+		return nil, err
+	}
+
+	weighted := viper.GetBool("pops.capacity_weighting")
+	if request.Weighted != nil {
+		weighted = *request.Weighted
+	}
+
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	origin, err := resolveDistanceEndpoint(ctx, redisCache, ipLocation, request.Origin)
+	if errors.Is(err, ErrInvalidDistanceRequest) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPoPRequest, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selection := &PoPSelection{Origin: origin, Weighted: weighted, PoPs: rankPoPs(pops, pinning, origin, weighted)}
+	if len(selection.PoPs) == 0 {
+		return nil, ErrNoPoPs
+	}
+
+	if request.Limit > 0 && len(selection.PoPs) > request.Limit {
+		selection.PoPs = selection.PoPs[:request.Limit]
+	}
+
+	return selection, nil
+}
+
+func rankPoPs(pops []PoP, pinning []PoPPinningRule, origin DistanceEndpoint, weighted bool) []RankedPoP {
+	pinned := pinnedPoPs(pinning, origin.CountryCode)
+	originPoint := geodesy.Point{Latitude: origin.Coordinates.Latitude, Longitude: origin.Coordinates.Longitude}
+
+	var ranked []RankedPoP
+	for _, pop := range pops {
+		if !pop.enabled() {
+			continue
+		}
+
+		inverse := geodesy.Distance(originPoint, geodesy.Point{Latitude: pop.Latitude, Longitude: pop.Longitude})
+		distance := geodesy.Kilometers.FromMeters(inverse.DistanceMeters)
+		score := distance
+		if weighted {
+			score = distance / pop.Weight
+		}
+
+		_, isPinned := pinned[pop.Name]
+		ranked = append(ranked, RankedPoP{PoP: pop, DistanceKm: distance, Score: score, Pinned: isPinned})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Pinned {
+			return pinned[a.PoP.Name] < pinned[b.PoP.Name]
+		}
+		return a.Score < b.Score
+	})
+
+	return ranked
+}
+
+// pinnedPoPs maps each PoP pinned to countryCode to the position of its rule.
+func pinnedPoPs(pinning []PoPPinningRule, countryCode string) map[string]int {
+	pinned := make(map[string]int)
+	if countryCode == "" {
+		return pinned
+	}
+
+	for i, rule := range pinning {
+		for _, country := range rule.Countries {
+			if strings.EqualFold(country, countryCode) {
+				if _, exists := pinned[rule.PoP]; !exists {
+					pinned[rule.PoP] = i
+				}
+			}
+		}
+	}
+	return pinned
+}
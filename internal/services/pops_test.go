@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func popNames(ranked []RankedPoP) []string {
+	names := make([]string, len(ranked))
+	for i, pop := range ranked {
+		names[i] = pop.PoP.Name
+	}
+	return names
+}
+
+func setupPoPs(t *testing.T) {
+	viper.Set("pops.list", []map[string]interface{}{
+		{"name": "gru", "latitude": -23.43, "longitude": -46.47, "weight": 2},
+		{"name": "eze", "latitude": -34.82, "longitude": -58.54},
+		{"name": "scl", "latitude": -33.39, "longitude": -70.79},
+		{"name": "mad", "latitude": 40.47, "longitude": -3.56, "weight": 10},
+		{"name": "mia", "latitude": 25.79, "longitude": -80.29, "enabled": false},
+	})
+	viper.Set("pops.pinning", []map[string]interface{}{
+		{"countries": []string{"uy", "PY"}, "pop": "scl"},
+		{"countries": []string{"UY"}, "pop": "mad"},
+	})
+	t.Cleanup(viper.Reset)
+}
+
+func TestRankPoPs(t *testing.T) {
+	setupPoPs(t)
+	pops, pinning, err := LoadPoPs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	montevideo := DistanceEndpoint{Coordinates: Coordinates{Latitude: -34.9, Longitude: -56.16}}
+	uruguay := montevideo
+	uruguay.CountryCode = "UY"
+	paraguay := DistanceEndpoint{CountryCode: "PY", Coordinates: Coordinates{Latitude: -25.26, Longitude: -57.58}}
+
+	tests := []struct {
+		name       string
+		origin     DistanceEndpoint
+		weighted   bool
+		want       []string
+		wantPinned int
+	}{
+		{"by distance", montevideo, false, []string{"eze", "scl", "gru", "mad"}, 0},
+		// São Paulo has twice the capacity and Madrid ten times, so both get
+		// ahead of Santiago despite being farther.
+		{"capacity weighting", montevideo, true, []string{"eze", "gru", "mad", "scl"}, 0},
+		{"pinned in rule order", uruguay, false, []string{"scl", "mad", "eze", "gru"}, 2},
+		{"pinned before weighted", paraguay, true, []string{"scl", "gru", "mad", "eze"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankPoPs(pops, pinning, tt.origin, tt.weighted)
+			got := popNames(ranked)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+			for i, pop := range ranked {
+				if pop.Pinned != (i < tt.wantPinned) {
+					t.Fatalf("%s pinned = %v", pop.PoP.Name, pop.Pinned)
+				}
+			}
+		})
+	}
+}
+
+func TestRankPoPsLimitAndDisabled(t *testing.T) {
+	setupPoPs(t)
+	viper.Set("ipapi.url", "http://ipapi.invalid")
+
+	selection, err := RankPoPs(nil, nil, PoPRequest{Origin: "-34.9,-56.16", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := popNames(selection.PoPs); len(got) != 2 || got[0] != "eze" || got[1] != "scl" {
+		t.Fatalf("got %v, want the two closest PoPs", got)
+	}
+	for _, pop := range selection.PoPs {
+		if pop.PoP.Name == "mia" {
+			t.Fatal("expected disabled PoPs to be skipped")
+		}
+	}
+
+	viper.Set("pops.list", []map[string]interface{}{{"name": "mia", "latitude": 25.79, "longitude": -80.29, "enabled": false}})
+	viper.Set("pops.pinning", nil)
+	if _, err := RankPoPs(nil, nil, PoPRequest{Origin: "-34.9,-56.16"}); !errors.Is(err, ErrNoPoPs) {
+		t.Fatalf("expected ErrNoPoPs, got %v", err)
+	}
+
+	if _, err := RankPoPs(nil, nil, PoPRequest{}); !errors.Is(err, ErrInvalidPoPRequest) {
+		t.Fatalf("expected ErrInvalidPoPRequest, got %v", err)
+	}
+}
+
+func TestLoadPoPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		pops    []map[string]interface{}
+		pinning []map[string]interface{}
+		wantErr bool
+	}{
+		{name: "valid", pops: []map[string]interface{}{{"name": "eze", "latitude": -34.82, "longitude": -58.54}}},
+		{name: "no name", pops: []map[string]interface{}{{"latitude": -34.82, "longitude": -58.54}}, wantErr: true},
+		{name: "invalid latitude", pops: []map[string]interface{}{{"name": "eze", "latitude": -95, "longitude": -58.54}}, wantErr: true},
+		{name: "invalid longitude", pops: []map[string]interface{}{{"name": "eze", "latitude": -34.82, "longitude": 181}}, wantErr: true},
+		{name: "negative weight", pops: []map[string]interface{}{{"name": "eze", "latitude": -34.82, "longitude": -58.54, "weight": -1}}, wantErr: true},
+		{
+			name:    "unknown pinned PoP",
+			pops:    []map[string]interface{}{{"name": "eze", "latitude": -34.82, "longitude": -58.54}},
+			pinning: []map[string]interface{}{{"countries": []string{"AR"}, "pop": "gru"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("pops.list", tt.pops)
+			viper.Set("pops.pinning", tt.pinning)
+			t.Cleanup(viper.Reset)
+
+			pops, _, err := LoadPoPs()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pops[0].Weight != 1 {
+				t.Fatalf("expected a missing weight to default to 1, got %v", pops[0].Weight)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
 	"github.com/gofiber/fiber/v2/log"
@@ -57,34 +60,34 @@ func (pd *DefaultPrefetchDataService) PreFetchData(ctx context.Context) error {
 				return
 			}
 
-			if exists > 0 {
+			if exists > 0 && !pd.datasetOutdated(ctx, key) {
 				return
 			}
 
-			resp, err := pd.httpClient.Get(ctx, url)
+			body, err := pd.fetchDataset(ctx, key, url)
+			if errors.Is(err, ErrInvalidDataset) {
+				if restoreErr := pd.rejectDataset(ctx, key, url, err); restoreErr != nil {
+					errCh <- restoreErr
+				}
+				return
+			}
 			if err != nil {
-				errCh <- fmt.Errorf("error fetching data from %s: %w", url, err)
+				errCh <- err
 				return
 			}
-			defer func(Body io.ReadCloser) {
-				closingErr := Body.Close()
-				if closingErr != nil {
-					log.Fatalf("error closing response body: %v", closingErr)
-				}
-			}(resp.Body)
 
 			var jsonData []byte
 
 			if key == "countries" {
 				var data []map[string]interface{}
-				if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+				if err := json.Unmarshal(body, &data); err != nil {
 					errCh <- fmt.Errorf("error decoding response body from %s: %w", url, err)
 					return
 				}
 				jsonData, err = json.Marshal(data)
 			} else if key == "currencies" {
 				var data map[string]interface{}
-				if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+				if err := json.Unmarshal(body, &data); err != nil {
 					errCh <- fmt.Errorf("error decoding response body from %s: %w", url, err)
 					return
 				}
@@ -101,12 +104,33 @@ func (pd *DefaultPrefetchDataService) PreFetchData(ctx context.Context) error {
 				return
 			}
 
+			if err := pd.cache.Set(ctx, datasetVersionKey(key), datasetVersion(jsonData), ttl); err != nil {
+				errCh <- fmt.Errorf("error setting data version in cache for key %s: %w", key, err)
+				return
+			}
+
+			if err := pd.cache.Set(ctx, lastGoodKey(key), jsonData, 0); err != nil {
+				errCh <- fmt.Errorf("error setting last good data in cache for key %s: %w", key, err)
+				return
+			}
+
+			if key == "currencies" {
+				if err := recordRatesHistory(ctx, NewCacheRatesHistoryStore(pd.cache), jsonData); err != nil {
+					errCh <- fmt.Errorf("error recording rates history: %w", err)
+					return
+				}
+			}
+
 		}(key, config.url, config.ttl)
 	}
 
 	wg.Wait()
 	close(errCh)
 
+	if err := pd.evaluateRateAlerts(ctx); err != nil {
+		fmt.Printf("Error evaluating rate alerts: %v\n", err)
+	}
+
 	for err := range errCh {
 		if err != nil {
 			return err
@@ -115,3 +139,131 @@ func (pd *DefaultPrefetchDataService) PreFetchData(ctx context.Context) error {
 
 	return nil
 }
+
+// RefreshPeriodically runs PreFetchData every interval until ctx is done, so a
+// long-running process picks up expired datasets and evaluates the rate alerts
+// against every new rate table.
+func (pd *DefaultPrefetchDataService) RefreshPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(ctx, viper.GetDuration("context.timeout"))
+			if err := pd.PreFetchData(refreshCtx); err != nil {
+				fmt.Printf("Error refreshing data: %v\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// datasetOutdated reports whether a cached dataset no longer passes
+// validation, e.g. countries cached without capitalInfo, or was cached without
+// a version marker, so it is refetched instead of waiting for its TTL.
+func (pd *DefaultPrefetchDataService) datasetOutdated(ctx context.Context, key string) bool {
+	if key != "countries" {
+		return false
+	}
+
+	data, err := pd.cache.Get(ctx, key)
+	if err != nil || data == nil {
+		return false
+	}
+	if exists, err := pd.cache.Exists(ctx, datasetVersionKey(key)); err == nil && exists == 0 {
+		return true
+	}
+	return validateCountriesDataset(data) != nil
+}
+
+// evaluateRateAlerts runs on every start and refresh, not only when the rates
+// were refetched, so a table stored by another process is still evaluated once.
+func (pd *DefaultPrefetchDataService) evaluateRateAlerts(ctx context.Context) error {
+	currentData, err := pd.cache.Get(ctx, "currencies")
+	if err != nil || currentData == nil {
+		return nil
+	}
+	return notifyRateAlerts(ctx, pd.cache, pd.httpClient, currentData)
+}
+
+func (pd *DefaultPrefetchDataService) fetchDataset(ctx context.Context, key, url string) ([]byte, error) {
+	if key == "currencies" {
+		ratesData, err := NewRatesProviderChain(pd.httpClient).FetchLatest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ratesData)
+	}
+
+	resp, err := pd.httpClient.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from %s: %w", url, err)
+	}
+	defer func(Body io.ReadCloser) {
+		closingErr := Body.Close()
+		if closingErr != nil {
+			log.Fatalf("error closing response body: %v", closingErr)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body from %s: %w", url, err)
+	}
+
+	if err := validateDataset(key, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (pd *DefaultPrefetchDataService) rejectDataset(ctx context.Context, key, url string, reason error) error {
+	fmt.Printf("Rejected %s dataset from %s: %v\n", key, url, reason)
+
+	rejection, err := json.Marshal(DatasetRejection{
+		Dataset:    key,
+		URL:        url,
+		Reason:     reason.Error(),
+		RejectedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		if err := pd.cache.Set(ctx, "prefetch:rejections:"+key, rejection, 0); err != nil {
+			fmt.Printf("Error recording %s dataset rejection: %v\n", key, err)
+		}
+	}
+
+	lastGood, err := pd.cache.Get(ctx, lastGoodKey(key))
+	if err != nil || lastGood == nil {
+		return fmt.Errorf("rejected %s dataset and no previous version is available: %w", key, reason)
+	}
+
+	retryTTL := viper.GetDuration("prefetch.validation.retry_ttl")
+	if err := pd.cache.Set(ctx, key, lastGood, retryTTL); err != nil {
+		return fmt.Errorf("error restoring last good data in cache for key %s: %w", key, err)
+	}
+	if err := pd.cache.Set(ctx, datasetVersionKey(key), datasetVersion(lastGood), retryTTL); err != nil {
+		return fmt.Errorf("error restoring last good data version in cache for key %s: %w", key, err)
+	}
+
+	fmt.Printf("Kept last good %s dataset, retrying in %s\n", key, retryTTL)
+	return nil
+}
+
+func lastGoodKey(key string) string {
+	return key + ":last_good"
+}
+
+// datasetVersionKey holds a fingerprint of the cached dataset, written with
+// it, so readers can tell whether it changed without reading it.
+func datasetVersionKey(key string) string {
+	return key + ":version"
+}
+
+func datasetVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
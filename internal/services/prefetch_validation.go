@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidDataset = errors.New("invalid dataset")
+
+type FixerError struct {
+	Code int    `json:"code"`
+	Type string `json:"type"`
+	Info string `json:"info"`
+}
+
+type FixerResponse struct {
+	Success   *bool              `json:"success"`
+	Error     *FixerError        `json:"error"`
+	Timestamp int64              `json:"timestamp"`
+	Base      string             `json:"base"`
+	Date      string             `json:"date"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+type DatasetRejection struct {
+	Dataset    string `json:"dataset"`
+	URL        string `json:"url"`
+	Reason     string `json:"reason"`
+	RejectedAt string `json:"rejected_at"`
+}
+
+func validateDataset(key string, data []byte) error {
+	switch key {
+	case "countries":
+		return validateCountriesDataset(data)
+	case "currencies":
+		return validateCurrenciesDataset(data)
+	default:
+		return nil
+	}
+}
+
+func validateCountriesDataset(data []byte) error {
+	var countries []Country
+	if err := json.Unmarshal(data, &countries); err != nil {
+		return fmt.Errorf("%w: countries payload is not a list of countries: %v", ErrInvalidDataset, err)
+	}
+
+	minCount := viper.GetInt("prefetch.validation.countries.min_count")
+	if len(countries) < minCount {
+		return fmt.Errorf("%w: expected at least %d countries, got %d", ErrInvalidDataset, minCount, len(countries))
+	}
+
+	withCapital, withCapitalCoordinates := 0, 0
+	for i, country := range countries {
+		if len(country.Cca2) != 2 {
+			return fmt.Errorf("%w: country at index %d has invalid cca2 %q", ErrInvalidDataset, i, country.Cca2)
+		}
+		if country.Name.Common == "" {
+			return fmt.Errorf("%w: country %s has no common name", ErrInvalidDataset, country.Cca2)
+		}
+		if len(country.LatLng) < 2 {
+			return fmt.Errorf("%w: country %s has no coordinates", ErrInvalidDataset, country.Cca2)
+		}
+		if len(country.Capital) > 0 {
+			withCapital++
+		}
+		if len(country.CapitalInfo.LatLng) >= 2 {
+			withCapitalCoordinates++
+		}
+	}
+
+	// The nearest capitals need capitalInfo, which restcountries only returns
+	// when it is listed in the requested fields.
+	if withCapital > 0 && withCapitalCoordinates == 0 {
+		return fmt.Errorf("%w: none of the %d capitals has capitalInfo coordinates", ErrInvalidDataset, withCapital)
+	}
+
+	return nil
+}
+
+func validateCurrenciesDataset(data []byte) error {
+	var response FixerResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("%w: currencies payload is not a rates table: %v", ErrInvalidDataset, err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("%w: provider returned error %d (%s): %s", ErrInvalidDataset, response.Error.Code, response.Error.Type, response.Error.Info)
+	}
+
+	if response.Success != nil && !*response.Success {
+		return fmt.Errorf("%w: provider reported success=false", ErrInvalidDataset)
+	}
+
+	return validateRatesTable(response.Rates, 0)
+}
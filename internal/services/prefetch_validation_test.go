@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	giphttp "github.com/cgiraldoz/geo-ip-info/internal/http"
+	"github.com/spf13/viper"
+)
+
+// testCountries returns n valid countries, with change applied to the first.
+func testCountries(t *testing.T, n int, change func(country map[string]interface{})) []byte {
+	t.Helper()
+
+	countries := make([]map[string]interface{}, n)
+	for i := range countries {
+		countries[i] = map[string]interface{}{
+			"cca2":        fmt.Sprintf("%c%c", 'A'+i/26%26, 'A'+i%26),
+			"name":        map[string]interface{}{"common": fmt.Sprintf("Country %d", i)},
+			"latlng":      []float64{float64(i % 90), float64(i % 180)},
+			"capital":     []string{fmt.Sprintf("Capital %d", i)},
+			"capitalInfo": map[string]interface{}{"latlng": []float64{float64(i % 90), float64(i % 180)}},
+		}
+	}
+	if change != nil && n > 0 {
+		change(countries[0])
+	}
+
+	data, err := json.Marshal(countries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestValidateCountriesDataset(t *testing.T) {
+	viper.Set("prefetch.validation.countries.min_count", 3)
+	t.Cleanup(viper.Reset)
+
+	var countries []map[string]interface{}
+	if err := json.Unmarshal(testCountries(t, 3, nil), &countries); err != nil {
+		t.Fatal(err)
+	}
+	for _, country := range countries {
+		delete(country, "capitalInfo")
+	}
+	noCapitalInfo, _ := json.Marshal(countries)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid", data: testCountries(t, 3, nil)},
+		{name: "not a list", data: []byte(`{"status":404,"message":"Not Found"}`), wantErr: true},
+		{name: "not json", data: []byte(`<html>maintenance</html>`), wantErr: true},
+		{name: "too few", data: testCountries(t, 2, nil), wantErr: true},
+		{name: "empty list", data: []byte(`[]`), wantErr: true},
+		{name: "bad cca2", data: testCountries(t, 3, func(c map[string]interface{}) { c["cca2"] = "ARG" }), wantErr: true},
+		{name: "no name", data: testCountries(t, 3, func(c map[string]interface{}) { delete(c, "name") }), wantErr: true},
+		{name: "no coordinates", data: testCountries(t, 3, func(c map[string]interface{}) { c["latlng"] = []float64{} }), wantErr: true},
+		{name: "no capital coordinates", data: noCapitalInfo, wantErr: true},
+		{name: "one capital without coordinates", data: testCountries(t, 3, func(c map[string]interface{}) { delete(c, "capitalInfo") })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCountriesDataset(tt.data)
+			if tt.wantErr && !errors.Is(err, ErrInvalidDataset) {
+				t.Fatalf("expected ErrInvalidDataset, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestValidateCurrenciesDataset(t *testing.T) {
+	setupRatesValidation(t)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: fixerPayload},
+		{name: "no success flag", data: `{"rates":{"EUR":1,"USD":1.1,"ARS":890}}`},
+		{name: "provider error", data: fixerErrorPayload, wantErr: true},
+		{name: "success false", data: `{"success":false,"rates":{"EUR":1,"USD":1.1,"ARS":890}}`, wantErr: true},
+		{name: "too few rates", data: `{"success":true,"rates":{"EUR":1,"USD":1.1}}`, wantErr: true},
+		{name: "missing required", data: `{"success":true,"rates":{"EUR":1,"ARS":890,"BRL":5.3}}`, wantErr: true},
+		{name: "zero rate", data: `{"success":true,"rates":{"EUR":1,"USD":1.1,"ARS":0}}`, wantErr: true},
+		{name: "negative rate", data: `{"success":true,"rates":{"EUR":1,"USD":-1.1,"ARS":890}}`, wantErr: true},
+		{name: "not json", data: `maintenance`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCurrenciesDataset([]byte(tt.data))
+			if tt.wantErr && !errors.Is(err, ErrInvalidDataset) {
+				t.Fatalf("expected ErrInvalidDataset, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRejectDataset(t *testing.T) {
+	viper.Set("prefetch.validation.retry_ttl", time.Hour)
+	t.Cleanup(viper.Reset)
+
+	reason := fmt.Errorf("%w: expected at least 200 countries, got 3", ErrInvalidDataset)
+
+	t.Run("keeps the last good version", func(t *testing.T) {
+		server, redisCache := newTestRedis(t)
+		service := NewDefaultPrefetchDataService(redisCache, nil)
+		if err := server.Set(lastGoodKey("countries"), `[{"cca2":"AR"}]`); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := service.rejectDataset(context.Background(), "countries", "http://countries", reason); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _ := server.Get("countries"); got != `[{"cca2":"AR"}]` {
+			t.Fatalf("countries = %s, want the last good version", got)
+		}
+		if ttl := server.TTL("countries"); ttl != time.Hour {
+			t.Fatalf("restored dataset expires in %v, want the retry TTL", ttl)
+		}
+
+		var rejection DatasetRejection
+		recorded, _ := server.Get("prefetch:rejections:countries")
+		if err := json.Unmarshal([]byte(recorded), &rejection); err != nil {
+			t.Fatal(err)
+		}
+		if rejection.Dataset != "countries" || rejection.URL != "http://countries" || rejection.Reason != reason.Error() {
+			t.Fatalf("unexpected rejection %+v", rejection)
+		}
+	})
+
+	t.Run("fails without a previous version", func(t *testing.T) {
+		server, redisCache := newTestRedis(t)
+		service := NewDefaultPrefetchDataService(redisCache, nil)
+
+		err := service.rejectDataset(context.Background(), "countries", "http://countries", reason)
+		if !errors.Is(err, ErrInvalidDataset) {
+			t.Fatalf("expected the rejection reason, got %v", err)
+		}
+		if server.Exists("countries") {
+			t.Fatal("expected nothing to be cached")
+		}
+	})
+}
+
+func TestPreFetchKeepsLastGoodCountries(t *testing.T) {
+	viper.Set("prefetch.validation.countries.min_count", 3)
+	viper.Set("prefetch.validation.retry_ttl", time.Hour)
+	t.Cleanup(viper.Reset)
+
+	valid := testCountries(t, 3, nil)
+	var payload []byte
+	countriesServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(countriesServer.Close)
+	viper.Set("prefetch.urls", map[string]interface{}{
+		"countries": map[string]interface{}{"url": countriesServer.URL, "ttl": "168h"},
+	})
+
+	server, redisCache := newTestRedis(t)
+	service := NewDefaultPrefetchDataService(redisCache, giphttp.NewDefaultHttpClient(time.Second))
+	ctx := context.Background()
+
+	payload = valid
+	if err := service.PreFetchData(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists(lastGoodKey("countries")) {
+		t.Fatal("expected a valid dataset to be kept as the last good version")
+	}
+
+	// Once the cached copy expires, an invalid payload is replaced by the
+	// last good version instead of being cached.
+	server.Del("countries")
+	payload = []byte(`[]`)
+	if err := service.PreFetchData(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, _ := server.Get("countries")
+	var countries []Country
+	if err := json.Unmarshal([]byte(cached), &countries); err != nil || len(countries) != 3 {
+		t.Fatalf("expected the last good countries, got %s (%v)", cached, err)
+	}
+	if !server.Exists("prefetch:rejections:countries") {
+		t.Fatal("expected the rejection to be recorded")
+	}
+}
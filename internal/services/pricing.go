@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
+	"github.com/spf13/viper"
+)
+
+var ErrNoLocalCurrency = errors.New("no local currency with a known exchange rate")
+
+type PricingRequest struct {
+	IP           string
+	CountryCode  string
+	Prices       []money.Decimal
+	BaseCurrency string
+	Rounding     string
+}
+
+type LocalizedPrices struct {
+	CountryName  string
+	Cca2         string
+	Locale       string
+	BaseCurrency string
+	Rounding     string
+	Quotes       []PriceQuote
+	RatesInfo    RatesInfo
+}
+
+type PriceQuote struct {
+	BasePrice money.Decimal
+	Currency  string
+	Converted money.Decimal
+	Price     money.Decimal
+	Formatted string
+}
+
+func GetLocalizedPrices(redisCache interfaces.Cache, httpClient interfaces.Client, request PricingRequest) (*LocalizedPrices, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	countryCode := strings.ToUpper(request.CountryCode)
+	if countryCode == "" {
+		ipLocation, err := NewIPLocation(httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("error creating IP location service: %w", err)
+		}
+
+		info, err := ipLocation.GetIPLocation(ctx, request.IP)
+		if err != nil {
+			return nil, fmt.Errorf("error getting IP location: %w", err)
+		}
+		countryCode = info.IsoCode
+	}
+
+	country, err := getCountryFromCache(ctx, redisCache, countryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	ratesData, err := getRatesDataFromCache(ctx, redisCache)
+	if err != nil {
+		return nil, err
+	}
+
+	rounding := request.Rounding
+	if rounding == "" {
+		rounding = viper.GetString("pricing.rounding")
+	}
+
+	baseCurrency := request.BaseCurrency
+	if baseCurrency == "" {
+		baseCurrency = viper.GetString("pricing.base_currency")
+	}
+
+	locale := money.LocaleForCountry(country.Cca2, country.Languages)
+
+	codes := make([]string, 0, len(country.Currencies))
+	for code := range country.Currencies {
+		if _, exists := ratesData.Rates[code]; exists {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoLocalCurrency, country.Cca2)
+	}
+	sort.Strings(codes)
+
+	result := &LocalizedPrices{
+		CountryName:  country.Name.Common,
+		Cca2:         country.Cca2,
+		Locale:       locale.Locale,
+		BaseCurrency: strings.ToUpper(baseCurrency),
+		Rounding:     rounding,
+		RatesInfo:    newRatesInfo(ratesData),
+	}
+
+	for _, code := range codes {
+		for _, price := range request.Prices {
+			conversion, err := convertWithRates(ratesData, baseCurrency, code, price)
+			if err != nil {
+				return nil, err
+			}
+
+			rounded, err := money.ApplyRounding(conversion.Result, rounding, code)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Quotes = append(result.Quotes, PriceQuote{
+				BasePrice: price,
+				Currency:  code,
+				Converted: conversion.Result,
+				Price:     rounded,
+				Formatted: locale.Format(rounded, country.Currencies[code].Symbol, code),
+			})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+const (
+	rateAlertDeliveriesKey = "rates:alerts:deliveries"
+	rateAlertRatesKey      = "rates:alerts:evaluated"
+)
+
+type RateAlertRule struct {
+	Name             string  `mapstructure:"name"`
+	Base             string  `mapstructure:"base"`
+	Quote            string  `mapstructure:"quote"`
+	ThresholdPercent float64 `mapstructure:"threshold_percent"`
+	Webhook          struct {
+		URL    string `mapstructure:"url"`
+		Secret string `mapstructure:"secret"`
+	} `mapstructure:"webhook"`
+}
+
+type RateAlert struct {
+	Rule          string  `json:"rule"`
+	Base          string  `json:"base"`
+	Quote         string  `json:"quote"`
+	PreviousRate  float64 `json:"previous_rate"`
+	CurrentRate   float64 `json:"current_rate"`
+	ChangePercent float64 `json:"change_percent"`
+	Threshold     float64 `json:"threshold_percent"`
+	PreviousDate  string  `json:"previous_date"`
+	CurrentDate   string  `json:"current_date"`
+	Triggered     bool    `json:"triggered"`
+	TriggeredAt   string  `json:"triggered_at"`
+}
+
+type RateAlertDelivery struct {
+	Rule        string `json:"rule"`
+	URL         string `json:"url"`
+	Attempts    int    `json:"attempts"`
+	StatusCode  int    `json:"status_code"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+	DeliveredAt string `json:"delivered_at"`
+}
+
+func LoadRateAlertRules() ([]RateAlertRule, error) {
+	path := viper.GetString("rates.alerts.rules_file")
+	if path == "" {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	rulesConfig := viper.New()
+	rulesConfig.SetConfigFile(path)
+	if err := rulesConfig.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading alert rules from %s: %w", path, err)
+	}
+
+	var rules []RateAlertRule
+	if err := rulesConfig.UnmarshalKey("rules", &rules); err != nil {
+		return nil, fmt.Errorf("error decoding alert rules from %s: %w", path, err)
+	}
+
+	for i, rule := range rules {
+		if rule.Base == "" || rule.Quote == "" || rule.ThresholdPercent <= 0 {
+			return nil, fmt.Errorf("alert rule %d (%s) needs base, quote and a positive threshold_percent", i, rule.Name)
+		}
+		rules[i].Base = strings.ToUpper(rule.Base)
+		rules[i].Quote = strings.ToUpper(rule.Quote)
+		rules[i].Webhook.Secret = os.ExpandEnv(rule.Webhook.Secret)
+		if rules[i].Name == "" {
+			rules[i].Name = rules[i].Base + "/" + rules[i].Quote
+		}
+	}
+
+	return rules, nil
+}
+
+func EvaluateRateAlerts(rules []RateAlertRule, previous, current RatesData) []RateAlert {
+	now := time.Now().UTC().Format(time.RFC3339)
+	alerts := make([]RateAlert, 0, len(rules))
+
+	for _, rule := range rules {
+		previousRate, err := pairRate(previous, rule.Base, rule.Quote)
+		if err != nil {
+			fmt.Printf("Skipping alert rule %s: %v\n", rule.Name, err)
+			continue
+		}
+
+		currentRate, err := pairRate(current, rule.Base, rule.Quote)
+		if err != nil {
+			fmt.Printf("Skipping alert rule %s: %v\n", rule.Name, err)
+			continue
+		}
+
+		change := (currentRate - previousRate) / previousRate * 100
+		alerts = append(alerts, RateAlert{
+			Rule:          rule.Name,
+			Base:          rule.Base,
+			Quote:         rule.Quote,
+			PreviousRate:  previousRate,
+			CurrentRate:   currentRate,
+			ChangePercent: change,
+			Threshold:     rule.ThresholdPercent,
+			PreviousDate:  previous.Date,
+			CurrentDate:   current.Date,
+			Triggered:     math.Abs(change) >= rule.ThresholdPercent,
+			TriggeredAt:   now,
+		})
+	}
+
+	return alerts
+}
+
+func pairRate(ratesData RatesData, base, quote string) (float64, error) {
+	baseRate, exists := ratesData.Rates[base]
+	if !exists || baseRate <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrCurrencyNotFound, base)
+	}
+
+	quoteRate, exists := ratesData.Rates[quote]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrCurrencyNotFound, quote)
+	}
+
+	return quoteRate / baseRate, nil
+}
+
+// rateAlertJob is a triggered alert waiting for webhook delivery.
+type rateAlertJob struct {
+	cache      interfaces.Cache
+	httpClient interfaces.Client
+	rule       RateAlertRule
+	alert      RateAlert
+}
+
+var (
+	rateAlertJobs    = make(chan rateAlertJob, 64)
+	rateAlertPending sync.WaitGroup
+	rateAlertWorker  sync.Once
+)
+
+// notifyRateAlerts compares the current rate table with the one the alerts
+// were last evaluated against and queues a webhook for every triggered rule.
+// Delivery happens in the background so slow webhooks never delay startup.
+func notifyRateAlerts(ctx context.Context, cache interfaces.Cache, httpClient interfaces.Client, currentData []byte) error {
+	rules, err := LoadRateAlertRules()
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	// Swapping in the current table atomically means that, across processes,
+	// only the first to see a new table gets the old one back and evaluates.
+	previousData, err := cache.GetSet(ctx, rateAlertRatesKey, currentData)
+	if errors.Is(err, interfaces.ErrCacheMiss) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error storing evaluated rates: %w", err)
+	}
+	if bytes.Equal(previousData, currentData) {
+		return nil
+	}
+
+	var previous, current RatesData
+	if err := json.Unmarshal(previousData, &previous); err != nil {
+		return fmt.Errorf("error decoding previous rates: %w", err)
+	}
+	if err := json.Unmarshal(currentData, &current); err != nil {
+		return fmt.Errorf("error decoding current rates: %w", err)
+	}
+
+	rulesByName := make(map[string]RateAlertRule, len(rules))
+	for _, rule := range rules {
+		rulesByName[rule.Name] = rule
+	}
+
+	for _, alert := range EvaluateRateAlerts(rules, previous, current) {
+		if !alert.Triggered {
+			continue
+		}
+
+		rule := rulesByName[alert.Rule]
+		if rule.Webhook.URL == "" {
+			fmt.Printf("Alert %s triggered (%.2f%%) but has no webhook configured\n", alert.Rule, alert.ChangePercent)
+			continue
+		}
+
+		enqueueRateAlert(rateAlertJob{cache: cache, httpClient: httpClient, rule: rule, alert: alert})
+	}
+
+	return nil
+}
+
+func enqueueRateAlert(job rateAlertJob) {
+	rateAlertWorker.Do(func() {
+		go deliverRateAlerts()
+	})
+
+	rateAlertPending.Add(1)
+	select {
+	case rateAlertJobs <- job:
+	default:
+		rateAlertPending.Done()
+		fmt.Printf("Dropping alert %s: the delivery queue is full\n", job.rule.Name)
+	}
+}
+
+func deliverRateAlerts() {
+	for job := range rateAlertJobs {
+		delivery := deliverRateAlert(job.httpClient, job.rule, job.alert)
+
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+		if err := appendRateAlertDelivery(ctx, job.cache, delivery); err != nil {
+			fmt.Printf("Error recording alert delivery: %v\n", err)
+		}
+		cancel()
+
+		rateAlertPending.Done()
+	}
+}
+
+// WaitRateAlerts waits up to timeout for the queued webhook deliveries, so a
+// short-lived command does not exit before sending them. It reports whether
+// the queue was drained.
+func WaitRateAlerts(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		rateAlertPending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func deliverRateAlert(httpClient interfaces.Client, rule RateAlertRule, alert RateAlert) RateAlertDelivery {
+	delivery := RateAlertDelivery{Rule: rule.Name, URL: rule.Webhook.URL}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	maxAttempts := max(viper.GetInt("rates.alerts.max_attempts"), 1)
+	backoff := viper.GetDuration("rates.alerts.retry_backoff")
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		delivery.Attempts = attempt
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers := map[string]string{
+			"Content-Type":    "application/json",
+			"X-GIP-Event":     "rate_alert",
+			"X-GIP-Timestamp": timestamp,
+		}
+		if rule.Webhook.Secret != "" {
+			headers["X-GIP-Signature"] = "sha256=" + signWebhookPayload(rule.Webhook.Secret, timestamp, body)
+		}
+
+		statusCode, err := postWebhook(httpClient, rule.Webhook.URL, headers, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+
+		delivery.Error = err.Error()
+		fmt.Printf("Alert %s delivery attempt %d failed: %v\n", rule.Name, attempt, err)
+		if attempt < maxAttempts {
+			time.Sleep(backoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	delivery.DeliveredAt = time.Now().UTC().Format(time.RFC3339)
+	return delivery
+}
+
+func postWebhook(httpClient interfaces.Client, url string, headers map[string]string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("http.timeout"))
+	defer cancel()
+
+	resp, err := httpClient.Post(ctx, url, headers, body)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Println("Error closing response body")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("non-2xx HTTP status: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func appendRateAlertDelivery(ctx context.Context, cache interfaces.Cache, delivery RateAlertDelivery) error {
+	deliveries, err := GetRateAlertDeliveries(ctx, cache)
+	if err != nil {
+		return err
+	}
+
+	deliveries = append(deliveries, delivery)
+	if size := viper.GetInt("rates.alerts.log_size"); size > 0 && len(deliveries) > size {
+		deliveries = deliveries[len(deliveries)-size:]
+	}
+
+	data, err := json.Marshal(deliveries)
+	if err != nil {
+		return fmt.Errorf("error marshalling alert deliveries: %w", err)
+	}
+
+	return cache.Set(ctx, rateAlertDeliveriesKey, data, 0)
+}
+
+func GetRateAlertDeliveries(ctx context.Context, cache interfaces.Cache) ([]RateAlertDelivery, error) {
+	data, err := cache.Get(ctx, rateAlertDeliveriesKey)
+	if err != nil || data == nil {
+		return nil, nil
+	}
+
+	var deliveries []RateAlertDelivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		return nil, fmt.Errorf("error unmarshalling alert deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func DryRunRateAlerts(redisCache interfaces.Cache, previousDate string) ([]RateAlert, error) {
+	rules, err := LoadRateAlertRules()
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("no alert rules configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	current, err := getRatesDataFromCache(ctx, redisCache)
+	if err != nil {
+		return nil, err
+	}
+
+	if previousDate == "" {
+		currentDate, err := time.Parse(ratesDateLayout, current.Date)
+		if err != nil {
+			currentDate = time.Now().UTC()
+		}
+		previousDate = currentDate.AddDate(0, 0, -1).Format(ratesDateLayout)
+	}
+
+	previous, err := getRatesData(ctx, redisCache, previousDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return EvaluateRateAlerts(rules, previous, current), nil
+}
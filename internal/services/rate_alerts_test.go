@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	giphttp "github.com/cgiraldoz/geo-ip-info/internal/http"
+	"github.com/spf13/viper"
+)
+
+func TestEvaluateRateAlerts(t *testing.T) {
+	previous := RatesData{Date: "2024-01-01", Rates: map[string]float64{"EUR": 1, "USD": 1.10, "ARS": 880}}
+	current := RatesData{Date: "2024-01-02", Rates: map[string]float64{"EUR": 1, "USD": 1.10, "ARS": 968}}
+
+	tests := []struct {
+		name      string
+		rule      RateAlertRule
+		skipped   bool
+		change    float64
+		triggered bool
+	}{
+		{name: "rise above threshold", rule: RateAlertRule{Name: "usd-ars", Base: "USD", Quote: "ARS", ThresholdPercent: 5}, change: 10, triggered: true},
+		{name: "rise below threshold", rule: RateAlertRule{Name: "usd-ars", Base: "USD", Quote: "ARS", ThresholdPercent: 15}, change: 10},
+		{name: "fall counts too", rule: RateAlertRule{Name: "ars-usd", Base: "ARS", Quote: "USD", ThresholdPercent: 5}, change: -9.090909, triggered: true},
+		{name: "exactly at threshold", rule: RateAlertRule{Name: "usd-ars", Base: "USD", Quote: "ARS", ThresholdPercent: 10}, change: 10, triggered: true},
+		{name: "unchanged pair", rule: RateAlertRule{Name: "eur-usd", Base: "EUR", Quote: "USD", ThresholdPercent: 1}, change: 0},
+		{name: "unknown currency", rule: RateAlertRule{Name: "usd-xxx", Base: "USD", Quote: "XXX", ThresholdPercent: 1}, skipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := EvaluateRateAlerts([]RateAlertRule{tt.rule}, previous, current)
+			if tt.skipped {
+				if len(alerts) != 0 {
+					t.Fatalf("expected the rule to be skipped, got %+v", alerts)
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("expected one alert, got %+v", alerts)
+			}
+
+			alert := alerts[0]
+			if diff := alert.ChangePercent - tt.change; diff > 1e-6 || diff < -1e-6 {
+				t.Fatalf("change = %v, want %v", alert.ChangePercent, tt.change)
+			}
+			if alert.Triggered != tt.triggered {
+				t.Fatalf("triggered = %v, want %v", alert.Triggered, tt.triggered)
+			}
+			if alert.PreviousDate != "2024-01-01" || alert.CurrentDate != "2024-01-02" {
+				t.Fatalf("unexpected dates %s -> %s", alert.PreviousDate, alert.CurrentDate)
+			}
+		})
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	got := signWebhookPayload("secret", "1700000000", []byte(`{"rule":"x"}`))
+	want := "20e96dabbc2c4d4bdf9e954655aa44d18dc4b4e4395f09197db4a85f70d83dc6"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+// newWebhookServer fails the first failures requests and, when a secret is
+// given, verifies the signature of every request it receives.
+func newWebhookServer(t *testing.T, secret string, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := requests.Add(1)
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-GIP-Event") != "rate_alert" {
+			t.Errorf("missing event header: %v", r.Header)
+		}
+		if secret != "" {
+			expected := "sha256=" + signWebhookPayload(secret, r.Header.Get("X-GIP-Timestamp"), body)
+			if r.Header.Get("X-GIP-Signature") != expected {
+				t.Errorf("bad signature headers: %v", r.Header)
+			}
+		}
+
+		var alert RateAlert
+		if err := json.Unmarshal(body, &alert); err != nil {
+			t.Errorf("bad webhook body: %v", err)
+		}
+
+		if attempt <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func setupRateAlertDelivery(t *testing.T) {
+	viper.Set("rates.alerts.max_attempts", 3)
+	viper.Set("rates.alerts.retry_backoff", time.Millisecond)
+	viper.Set("http.timeout", time.Second)
+	viper.Set("context.timeout", time.Second)
+	t.Cleanup(viper.Reset)
+}
+
+func TestDeliverRateAlertRetries(t *testing.T) {
+	setupRateAlertDelivery(t)
+
+	tests := []struct {
+		name         string
+		failures     int32
+		wantSuccess  bool
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"first attempt", 0, true, 1, http.StatusNoContent},
+		{"after retries", 2, true, 3, http.StatusNoContent},
+		{"gives up", 5, false, 3, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newWebhookServer(t, "secret", tt.failures)
+
+			rule := RateAlertRule{Name: "usd-ars"}
+			rule.Webhook.URL = server.URL
+			rule.Webhook.Secret = "secret"
+
+			delivery := deliverRateAlert(giphttp.NewDefaultHttpClient(time.Second), rule, RateAlert{Rule: "usd-ars", Triggered: true})
+			if delivery.Success != tt.wantSuccess || delivery.Attempts != tt.wantAttempts || delivery.StatusCode != tt.wantStatus {
+				t.Fatalf("got %+v", delivery)
+			}
+			if int(requests.Load()) != tt.wantAttempts {
+				t.Fatalf("server saw %d requests, want %d", requests.Load(), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNotifyRateAlertsEvaluatesEachTableOnce(t *testing.T) {
+	setupRateAlertDelivery(t)
+	server, requests := newWebhookServer(t, "", 0)
+
+	rulesFile := filepath.Join(t.TempDir(), "alerts.yaml")
+	rules := "rules:\n  - name: usd-ars\n    base: USD\n    quote: ARS\n    threshold_percent: 5\n    webhook:\n      url: " + server.URL + "\n"
+	if err := os.WriteFile(rulesFile, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("rates.alerts.rules_file", rulesFile)
+
+	redisCache := newTestCache(t)
+	httpClient := giphttp.NewDefaultHttpClient(time.Second)
+	ctx := context.Background()
+
+	table := func(ars float64) []byte {
+		data, _ := json.Marshal(RatesData{Rates: map[string]float64{"EUR": 1, "USD": 1.1, "ARS": ars}})
+		return data
+	}
+
+	steps := []struct {
+		name  string
+		rates []byte
+		want  int32
+	}{
+		{"first table is the baseline", table(880), 0},
+		{"same table again", table(880), 0},
+		{"large move", table(968), 1},
+		{"same table from another process", table(968), 1},
+		{"small move", table(970), 1},
+	}
+
+	for _, step := range steps {
+		if err := notifyRateAlerts(ctx, redisCache, httpClient, step.rates); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !WaitRateAlerts(time.Second) {
+			t.Fatalf("%s: deliveries did not finish", step.name)
+		}
+		if requests.Load() != step.want {
+			t.Fatalf("%s: %d webhooks sent, want %d", step.name, requests.Load(), step.want)
+		}
+	}
+
+	deliveries, err := GetRateAlertDeliveries(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || !deliveries[0].Success {
+		t.Fatalf("unexpected delivery log %+v", deliveries)
+	}
+}
+
+func TestRefreshPeriodicallyEvaluatesRateAlerts(t *testing.T) {
+	setupRateAlertDelivery(t)
+	server, requests := newWebhookServer(t, "", 0)
+
+	rulesFile := filepath.Join(t.TempDir(), "alerts.yaml")
+	rules := "rules:\n  - name: usd-ars\n    base: USD\n    quote: ARS\n    threshold_percent: 5\n    webhook:\n      url: " + server.URL + "\n"
+	if err := os.WriteFile(rulesFile, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("rates.alerts.rules_file", rulesFile)
+
+	redisCache := newTestCache(t)
+	service := NewDefaultPrefetchDataService(redisCache, giphttp.NewDefaultHttpClient(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	table := func(ars float64) []byte {
+		data, _ := json.Marshal(RatesData{Rates: map[string]float64{"EUR": 1, "USD": 1.1, "ARS": ars}})
+		return data
+	}
+
+	// The tables are stored as another process refreshing the rates would.
+	if err := redisCache.Set(ctx, "currencies", table(880), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.PreFetchData(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go service.RefreshPeriodically(ctx, 10*time.Millisecond)
+
+	if err := redisCache.Set(ctx, "currencies", table(968), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !WaitRateAlerts(time.Second) {
+		t.Fatal("deliveries did not finish")
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("%d webhooks sent after the refresh, want 1", requests.Load())
+	}
+}
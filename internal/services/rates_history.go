@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/money"
+	"github.com/spf13/viper"
+)
+
+const ratesDateLayout = "2006-01-02"
+
+var (
+	ErrRatesNotFound = errors.New("exchange rates not found for date")
+	ErrInvalidDate   = errors.New("invalid date")
+)
+
+type RatesHistoryStore interface {
+	Save(ctx context.Context, date string, data RatesData) error
+	Load(ctx context.Context, date string) (RatesData, error)
+}
+
+type CacheRatesHistoryStore struct {
+	cache interfaces.Cache
+}
+
+type RatePoint struct {
+	Date string
+	Rate float64
+}
+
+func NewCacheRatesHistoryStore(cache interfaces.Cache) *CacheRatesHistoryStore {
+	return &CacheRatesHistoryStore{cache: cache}
+}
+
+func (s *CacheRatesHistoryStore) Save(ctx context.Context, date string, data RatesData) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshalling rates for %s: %w", date, err)
+	}
+
+	return s.cache.Set(ctx, ratesHistoryKey(date), jsonData, viper.GetDuration("rates.history.retention"))
+}
+
+func (s *CacheRatesHistoryStore) Load(ctx context.Context, date string) (RatesData, error) {
+	data, err := s.cache.Get(ctx, ratesHistoryKey(date))
+	if err != nil || data == nil {
+		return RatesData{}, fmt.Errorf("%w: %s", ErrRatesNotFound, date)
+	}
+
+	var ratesData RatesData
+	if err := json.Unmarshal(data, &ratesData); err != nil {
+		return RatesData{}, fmt.Errorf("error unmarshalling rates for %s: %w", date, err)
+	}
+
+	return ratesData, nil
+}
+
+func ratesHistoryKey(date string) string {
+	return "currencies:history:" + date
+}
+
+func ParseRatesDate(value string) (time.Time, error) {
+	date, err := time.Parse(ratesDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s (expected YYYY-MM-DD)", ErrInvalidDate, value)
+	}
+	if date.After(time.Now().UTC()) {
+		return time.Time{}, fmt.Errorf("%w: %s is in the future", ErrInvalidDate, value)
+	}
+	return date, nil
+}
+
+func getRatesData(ctx context.Context, cache interfaces.Cache, date string) (RatesData, error) {
+	if date == "" {
+		return getRatesDataFromCache(ctx, cache)
+	}
+
+	if _, err := ParseRatesDate(date); err != nil {
+		return RatesData{}, err
+	}
+
+	return NewCacheRatesHistoryStore(cache).Load(ctx, date)
+}
+
+func recordRatesHistory(ctx context.Context, store RatesHistoryStore, data []byte) error {
+	var ratesData RatesData
+	if err := json.Unmarshal(data, &ratesData); err != nil {
+		return fmt.Errorf("error decoding rates for history: %w", err)
+	}
+
+	date := ratesData.Date
+	if date == "" {
+		date = time.Now().UTC().Format(ratesDateLayout)
+	}
+
+	return store.Save(ctx, date, ratesData)
+}
+
+func GetRatesHistory(redisCache interfaces.Cache, currency, base string, from, to time.Time) ([]RatePoint, error) {
+	if err := validateRatesRange(from, to); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	currency = strings.ToUpper(currency)
+	base = strings.ToUpper(base)
+	store := NewCacheRatesHistoryStore(redisCache)
+
+	var points []RatePoint
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := date.Format(ratesDateLayout)
+		ratesData, err := store.Load(ctx, day)
+		if errors.Is(err, ErrRatesNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		conversion, err := convertWithRates(ratesData, base, currency, money.One)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", day, err)
+		}
+
+		points = append(points, RatePoint{Date: day, Rate: conversion.Rate})
+	}
+
+	return points, nil
+}
+
+// validateRatesRange rejects reversed ranges and ranges spanning more than
+// rates.history.max_days days.
+func validateRatesRange(from, to time.Time) error {
+	if to.Before(from) {
+		return fmt.Errorf("%w: range end is before range start", ErrInvalidDate)
+	}
+
+	maxDays := viper.GetInt("rates.history.max_days")
+	if maxDays > 0 && int(to.Sub(from).Hours()/24) >= maxDays {
+		return fmt.Errorf("%w: range is longer than %d days", ErrInvalidDate, maxDays)
+	}
+	return nil
+}
+
+func BackfillRatesHistory(redisCache interfaces.Cache, httpClient interfaces.Client, from, to time.Time) (int, error) {
+	historyURL := viper.GetString("rates.history.url")
+	if historyURL == "" {
+		return 0, errors.New("rates history URL not configured")
+	}
+
+	// Each day is a separate paid request to the history provider.
+	if err := validateRatesRange(from, to); err != nil {
+		return 0, err
+	}
+
+	store := NewCacheRatesHistoryStore(redisCache)
+	saved := 0
+
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := date.Format(ratesDateLayout)
+		data, err := fetchHistoricalRates(httpClient, strings.Replace(historyURL, "{date}", day, 1))
+		if err != nil {
+			return saved, fmt.Errorf("error backfilling rates for %s: %w", day, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+		err = recordRatesHistory(ctx, store, data)
+		cancel()
+		if err != nil {
+			return saved, fmt.Errorf("error saving rates for %s: %w", day, err)
+		}
+		saved++
+	}
+
+	return saved, nil
+}
+
+func BackfillRatesHistoryFromFiles(redisCache interfaces.Cache, paths []string) (int, error) {
+	store := NewCacheRatesHistoryStore(redisCache)
+	saved := 0
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return saved, fmt.Errorf("error reading rates file %s: %w", path, err)
+		}
+
+		if err := validateCurrenciesDataset(data); err != nil {
+			return saved, fmt.Errorf("rates file %s: %w", path, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+		err = recordRatesHistory(ctx, store, data)
+		cancel()
+		if err != nil {
+			return saved, fmt.Errorf("error saving rates from %s: %w", path, err)
+		}
+		saved++
+	}
+
+	return saved, nil
+}
+
+func fetchHistoricalRates(httpClient interfaces.Client, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	resp, err := httpClient.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Println("Error closing response body")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK HTTP status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCurrenciesDataset(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
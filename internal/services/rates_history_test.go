@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type failingClient struct {
+	t *testing.T
+}
+
+func (c failingClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	c.t.Fatalf("unexpected request to %s", url)
+	return nil, nil
+}
+
+func (c failingClient) Post(ctx context.Context, url string, headers map[string]string, body []byte) (*http.Response, error) {
+	c.t.Fatalf("unexpected request to %s", url)
+	return nil, nil
+}
+
+func TestBackfillRatesHistoryRejectsLongRanges(t *testing.T) {
+	viper.Set("rates.history.url", "http://rates.invalid/{date}")
+	viper.Set("rates.history.max_days", 31)
+	t.Cleanup(viper.Reset)
+
+	day := func(value string) time.Time {
+		parsed, err := time.Parse(ratesDateLayout, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"decades", "2000-01-01", "2024-01-01"},
+		{"one day over", "2024-01-01", "2024-02-01"},
+		{"reversed", "2024-02-01", "2024-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BackfillRatesHistory(nil, failingClient{t}, day(tt.from), day(tt.to))
+			if !errors.Is(err, ErrInvalidDate) {
+				t.Fatalf("expected ErrInvalidDate, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRatesRange(t *testing.T) {
+	viper.Set("rates.history.max_days", 31)
+	t.Cleanup(viper.Reset)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := validateRatesRange(from, from.AddDate(0, 0, 30)); err != nil {
+		t.Fatalf("31 days should be allowed: %v", err)
+	}
+	if err := validateRatesRange(from, from.AddDate(0, 0, 31)); err == nil {
+		t.Fatal("32 days should be rejected")
+	}
+}
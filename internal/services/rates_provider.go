@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+// defaultCrossCheckTolerance is used when rates.cross_check.tolerance is not
+// set, so that rounding differences between providers are not reported.
+const defaultCrossCheckTolerance = 0.02
+
+type RatesProvider interface {
+	Name() string
+	FetchLatest(ctx context.Context) (RatesData, error)
+}
+
+type RatesProviderConfig struct {
+	Name     string `mapstructure:"name"`
+	Type     string `mapstructure:"type"`
+	URL      string `mapstructure:"url"`
+	MinCount int    `mapstructure:"min_count"`
+}
+
+type RateDiscrepancy struct {
+	Currency   string  `json:"currency"`
+	Provider   string  `json:"provider"`
+	Rate       float64 `json:"rate"`
+	Reference  float64 `json:"reference"`
+	Difference float64 `json:"difference"`
+}
+
+type RatesProviderChain struct {
+	providers []RatesProvider
+}
+
+func NewRatesProvider(httpClient interfaces.Client, config RatesProviderConfig) (RatesProvider, error) {
+	providerType := config.Type
+	if providerType == "" {
+		providerType = config.Name
+	}
+
+	switch providerType {
+	case "fixer":
+		return NewFixerRatesProvider(httpClient, config), nil
+	case "ecb":
+		return NewECBRatesProvider(httpClient, config), nil
+	case "openexchangerates":
+		return NewOpenExchangeRatesProvider(httpClient, config), nil
+	default:
+		return nil, fmt.Errorf("unknown rates provider type %q", providerType)
+	}
+}
+
+func NewRatesProviderChain(httpClient interfaces.Client) *RatesProviderChain {
+	var configs []RatesProviderConfig
+	if err := viper.UnmarshalKey("rates.providers", &configs); err != nil {
+		fmt.Printf("Error reading rates providers configuration: %v\n", err)
+	}
+
+	if len(configs) == 0 {
+		configs = append(configs, RatesProviderConfig{
+			Name: "fixer",
+			URL:  viper.GetString("prefetch.urls.currencies.url"),
+		})
+	}
+
+	var providers []RatesProvider
+	for _, config := range configs {
+		provider, err := NewRatesProvider(httpClient, config)
+		if err != nil {
+			fmt.Printf("Skipping rates provider %s: %v\n", config.Name, err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	return &RatesProviderChain{providers: providers}
+}
+
+func (rc *RatesProviderChain) FetchLatest(ctx context.Context) (RatesData, error) {
+	var errs []error
+	var ratesData RatesData
+	primary := -1
+
+	for i, provider := range rc.providers {
+		data, err := provider.FetchLatest(ctx)
+		if err != nil {
+			fmt.Printf("Rates provider %s failed: %v\n", provider.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		ratesData = data
+		primary = i
+		break
+	}
+
+	if primary < 0 {
+		return RatesData{}, fmt.Errorf("%w: all rates providers failed: %v", ErrInvalidDataset, errors.Join(errs...))
+	}
+
+	if viper.GetBool("rates.cross_check.enabled") {
+		tolerance := viper.GetFloat64("rates.cross_check.tolerance")
+		if tolerance <= 0 {
+			tolerance = defaultCrossCheckTolerance
+		}
+		for _, provider := range rc.providers[primary+1:] {
+			other, err := provider.FetchLatest(ctx)
+			if err != nil {
+				fmt.Printf("Rates provider %s unavailable for cross-check: %v\n", provider.Name(), err)
+				continue
+			}
+			discrepancies := crossCheckRates(ratesData, other, tolerance)
+			for _, discrepancy := range discrepancies {
+				fmt.Printf("Rate for %s from %s differs by %.2f%% from %s\n",
+					discrepancy.Currency, discrepancy.Provider, discrepancy.Difference*100, ratesData.Provider)
+			}
+			ratesData.Discrepancies = append(ratesData.Discrepancies, discrepancies...)
+		}
+	}
+
+	return ratesData, nil
+}
+
+func crossCheckRates(reference, other RatesData, tolerance float64) []RateDiscrepancy {
+	referenceEUR, referenceOK := reference.Rates["EUR"]
+	otherEUR, otherOK := other.Rates["EUR"]
+	if !referenceOK || !otherOK {
+		return nil
+	}
+
+	var discrepancies []RateDiscrepancy
+	for code, rate := range other.Rates {
+		referenceRate, exists := reference.Rates[code]
+		if !exists {
+			continue
+		}
+
+		expected := referenceRate / referenceEUR
+		actual := rate / otherEUR
+		difference := math.Abs(actual-expected) / expected
+		if difference > tolerance {
+			discrepancies = append(discrepancies, RateDiscrepancy{
+				Currency:   code,
+				Provider:   other.Provider,
+				Rate:       actual,
+				Reference:  expected,
+				Difference: difference,
+			})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].Currency < discrepancies[j].Currency
+	})
+	return discrepancies
+}
+
+func validateRatesTable(rates map[string]float64, minCount int) error {
+	if minCount == 0 {
+		minCount = viper.GetInt("prefetch.validation.currencies.min_count")
+	}
+	if len(rates) < minCount {
+		return fmt.Errorf("%w: expected at least %d rates, got %d", ErrInvalidDataset, minCount, len(rates))
+	}
+
+	for _, code := range viper.GetStringSlice("prefetch.validation.currencies.required") {
+		if _, exists := rates[strings.ToUpper(code)]; !exists {
+			return fmt.Errorf("%w: required currency %s is missing", ErrInvalidDataset, code)
+		}
+	}
+
+	for code, rate := range rates {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return fmt.Errorf("%w: currency %s has invalid rate %v", ErrInvalidDataset, code, rate)
+		}
+	}
+
+	return nil
+}
+
+func fetchProviderBody(ctx context.Context, httpClient interfaces.Client, url string) ([]byte, error) {
+	resp, err := httpClient.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rates from %s: %w", url, err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			fmt.Println("Error closing response body")
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rates from %s: %w", url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+)
+
+type ECBRatesProvider struct {
+	httpClient interfaces.Client
+	config     RatesProviderConfig
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func NewECBRatesProvider(httpClient interfaces.Client, config RatesProviderConfig) *ECBRatesProvider {
+	if config.Name == "" {
+		config.Name = "ecb"
+	}
+	return &ECBRatesProvider{httpClient: httpClient, config: config}
+}
+
+func (p *ECBRatesProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *ECBRatesProvider) FetchLatest(ctx context.Context) (RatesData, error) {
+	body, err := fetchProviderBody(ctx, p.httpClient, p.config.URL)
+	if err != nil {
+		return RatesData{}, err
+	}
+
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return RatesData{}, fmt.Errorf("%w: ECB payload is not a rates feed: %v", ErrInvalidDataset, err)
+	}
+
+	date, err := time.Parse(ratesDateLayout, envelope.Cube.Cube.Time)
+	if err != nil {
+		return RatesData{}, fmt.Errorf("%w: ECB feed has invalid date %q", ErrInvalidDataset, envelope.Cube.Cube.Time)
+	}
+
+	rates := map[string]float64{"EUR": 1}
+	for _, rate := range envelope.Cube.Cube.Rates {
+		rates[rate.Currency] = rate.Rate
+	}
+
+	if err := validateRatesTable(rates, p.config.MinCount); err != nil {
+		return RatesData{}, err
+	}
+
+	return RatesData{
+		Provider:  p.Name(),
+		Base:      "EUR",
+		Date:      envelope.Cube.Cube.Time,
+		Timestamp: date.Unix(),
+		Rates:     rates,
+	}, nil
+}
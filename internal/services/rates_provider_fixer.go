@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+)
+
+type FixerRatesProvider struct {
+	httpClient interfaces.Client
+	config     RatesProviderConfig
+}
+
+func NewFixerRatesProvider(httpClient interfaces.Client, config RatesProviderConfig) *FixerRatesProvider {
+	if config.Name == "" {
+		config.Name = "fixer"
+	}
+	return &FixerRatesProvider{httpClient: httpClient, config: config}
+}
+
+func (p *FixerRatesProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *FixerRatesProvider) FetchLatest(ctx context.Context) (RatesData, error) {
+	body, err := fetchProviderBody(ctx, p.httpClient, p.config.URL)
+	if err != nil {
+		return RatesData{}, err
+	}
+
+	var response FixerResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return RatesData{}, fmt.Errorf("%w: fixer payload is not a rates table: %v", ErrInvalidDataset, err)
+	}
+
+	if response.Error != nil {
+		return RatesData{}, fmt.Errorf("%w: fixer returned error %d (%s): %s", ErrInvalidDataset, response.Error.Code, response.Error.Type, response.Error.Info)
+	}
+
+	if response.Success != nil && !*response.Success {
+		return RatesData{}, fmt.Errorf("%w: fixer reported success=false", ErrInvalidDataset)
+	}
+
+	if err := validateRatesTable(response.Rates, p.config.MinCount); err != nil {
+		return RatesData{}, err
+	}
+
+	return RatesData{
+		Provider:  p.Name(),
+		Base:      response.Base,
+		Date:      response.Date,
+		Timestamp: response.Timestamp,
+		Rates:     response.Rates,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+)
+
+type OpenExchangeRatesProvider struct {
+	httpClient interfaces.Client
+	config     RatesProviderConfig
+}
+
+type openExchangeRatesResponse struct {
+	Error       bool               `json:"error"`
+	Status      int                `json:"status"`
+	Message     string             `json:"message"`
+	Description string             `json:"description"`
+	Timestamp   int64              `json:"timestamp"`
+	Base        string             `json:"base"`
+	Rates       map[string]float64 `json:"rates"`
+}
+
+func NewOpenExchangeRatesProvider(httpClient interfaces.Client, config RatesProviderConfig) *OpenExchangeRatesProvider {
+	if config.Name == "" {
+		config.Name = "openexchangerates"
+	}
+	return &OpenExchangeRatesProvider{httpClient: httpClient, config: config}
+}
+
+func (p *OpenExchangeRatesProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *OpenExchangeRatesProvider) FetchLatest(ctx context.Context) (RatesData, error) {
+	body, err := fetchProviderBody(ctx, p.httpClient, p.config.URL)
+	if err != nil {
+		return RatesData{}, err
+	}
+
+	var response openExchangeRatesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return RatesData{}, fmt.Errorf("%w: openexchangerates payload is not a rates table: %v", ErrInvalidDataset, err)
+	}
+
+	if response.Error {
+		return RatesData{}, fmt.Errorf("%w: openexchangerates returned error %d (%s): %s", ErrInvalidDataset, response.Status, response.Message, response.Description)
+	}
+
+	if err := validateRatesTable(response.Rates, p.config.MinCount); err != nil {
+		return RatesData{}, err
+	}
+
+	return RatesData{
+		Provider:  p.Name(),
+		Base:      response.Base,
+		Date:      time.Unix(response.Timestamp, 0).UTC().Format(ratesDateLayout),
+		Timestamp: response.Timestamp,
+		Rates:     response.Rates,
+	}, nil
+}
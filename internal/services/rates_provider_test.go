@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	giphttp "github.com/cgiraldoz/geo-ip-info/internal/http"
+	"github.com/spf13/viper"
+)
+
+const (
+	fixerPayload = `{"success":true,"timestamp":1704110400,"base":"EUR","date":"2024-01-01",
+		"rates":{"EUR":1,"USD":1.10,"ARS":890.5,"BRL":5.36}}`
+	fixerErrorPayload = `{"success":false,"error":{"code":101,"type":"invalid_access_key","info":"No API Key was specified."}}`
+	ecbPayload        = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-01">
+			<Cube currency="USD" rate="1.1050"/>
+			<Cube currency="BRL" rate="5.36"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+	openExchangeRatesPayload = `{"timestamp":1704110400,"base":"USD","rates":{"USD":1,"EUR":0.909,"ARS":817,"BRL":4.98}}`
+)
+
+// newRatesServer serves each payload under its own path, standing in for the
+// real providers.
+func newRatesServer(t *testing.T, payloads map[string]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := payloads[r.URL.Path]
+		if !ok {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func setupRatesValidation(t *testing.T) {
+	viper.Set("prefetch.validation.currencies.min_count", 3)
+	viper.Set("prefetch.validation.currencies.required", []string{"USD", "EUR"})
+	t.Cleanup(viper.Reset)
+}
+
+func TestRatesProviders(t *testing.T) {
+	setupRatesValidation(t)
+	server := newRatesServer(t, map[string]string{
+		"/fixer":       fixerPayload,
+		"/fixer-error": fixerErrorPayload,
+		"/ecb":         ecbPayload,
+		"/oxr":         openExchangeRatesPayload,
+		"/garbage":     `<html>maintenance</html>`,
+	})
+	client := giphttp.NewDefaultHttpClient(time.Second)
+
+	tests := []struct {
+		name     string
+		config   RatesProviderConfig
+		wantErr  bool
+		wantBase string
+		wantUSD  float64
+		wantDate string
+	}{
+		{name: "fixer", config: RatesProviderConfig{Type: "fixer", URL: server.URL + "/fixer"}, wantBase: "EUR", wantUSD: 1.10, wantDate: "2024-01-01"},
+		{name: "fixer error payload", config: RatesProviderConfig{Type: "fixer", URL: server.URL + "/fixer-error"}, wantErr: true},
+		{name: "ecb", config: RatesProviderConfig{Type: "ecb", URL: server.URL + "/ecb"}, wantBase: "EUR", wantUSD: 1.105, wantDate: "2024-01-01"},
+		{name: "openexchangerates", config: RatesProviderConfig{Type: "openexchangerates", URL: server.URL + "/oxr"}, wantBase: "USD", wantUSD: 1, wantDate: "2024-01-01"},
+		{name: "http error", config: RatesProviderConfig{Type: "fixer", URL: server.URL + "/missing"}, wantErr: true},
+		{name: "not json", config: RatesProviderConfig{Type: "fixer", URL: server.URL + "/garbage"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewRatesProvider(client, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := provider.FetchLatest(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Base != tt.wantBase || data.Rates["USD"] != tt.wantUSD || data.Date != tt.wantDate {
+				t.Fatalf("got base %s, USD %v, date %s", data.Base, data.Rates["USD"], data.Date)
+			}
+		})
+	}
+}
+
+func TestRatesProviderChainFallsBack(t *testing.T) {
+	setupRatesValidation(t)
+	server := newRatesServer(t, map[string]string{
+		"/fixer-error": fixerErrorPayload,
+		"/ecb":         ecbPayload,
+	})
+	client := giphttp.NewDefaultHttpClient(time.Second)
+
+	chain := &RatesProviderChain{providers: []RatesProvider{
+		NewFixerRatesProvider(client, RatesProviderConfig{URL: server.URL + "/fixer-error"}),
+		NewECBRatesProvider(client, RatesProviderConfig{URL: server.URL + "/ecb"}),
+	}}
+
+	data, err := chain.FetchLatest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Provider != "ecb" {
+		t.Fatalf("expected the ecb fallback, got %s", data.Provider)
+	}
+
+	chain.providers = chain.providers[:1]
+	if _, err := chain.FetchLatest(context.Background()); !errors.Is(err, ErrInvalidDataset) {
+		t.Fatalf("expected ErrInvalidDataset when every provider fails, got %v", err)
+	}
+}
+
+func TestRatesProviderChainCrossCheck(t *testing.T) {
+	setupRatesValidation(t)
+	viper.Set("rates.cross_check.enabled", true)
+	server := newRatesServer(t, map[string]string{
+		"/fixer": fixerPayload,
+		"/oxr":   openExchangeRatesPayload,
+	})
+	client := giphttp.NewDefaultHttpClient(time.Second)
+
+	chain := &RatesProviderChain{providers: []RatesProvider{
+		NewFixerRatesProvider(client, RatesProviderConfig{URL: server.URL + "/fixer"}),
+		NewOpenExchangeRatesProvider(client, RatesProviderConfig{URL: server.URL + "/oxr"}),
+	}}
+
+	// Without a configured tolerance ARS, about 1% off, is within the
+	// default while BRL, about 2.2% off, is reported.
+	data, err := chain.FetchLatest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Discrepancies) != 1 || data.Discrepancies[0].Currency != "BRL" {
+		t.Fatalf("expected only BRL to be reported, got %+v", data.Discrepancies)
+	}
+
+	viper.Set("rates.cross_check.tolerance", 0.05)
+	data, err = chain.FetchLatest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Discrepancies) != 0 {
+		t.Fatalf("expected no discrepancies within 5%%, got %+v", data.Discrepancies)
+	}
+}
+
+func TestCrossCheckRates(t *testing.T) {
+	reference := RatesData{Provider: "a", Rates: map[string]float64{"EUR": 1, "USD": 1.1, "JPY": 160}}
+
+	tests := []struct {
+		name      string
+		rates     map[string]float64
+		tolerance float64
+		want      []string
+	}{
+		{"identical", map[string]float64{"EUR": 1, "USD": 1.1, "JPY": 160}, 0.01, nil},
+		{"different base", map[string]float64{"EUR": 0.5, "USD": 0.55, "JPY": 80}, 0.01, nil},
+		{"one off", map[string]float64{"EUR": 1, "USD": 1.1, "JPY": 170}, 0.01, []string{"JPY"}},
+		{"no EUR", map[string]float64{"USD": 1.1}, 0.01, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := crossCheckRates(reference, RatesData{Provider: "b", Rates: tt.rates}, tt.tolerance)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Currency != tt.want[i] {
+					t.Fatalf("got %+v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
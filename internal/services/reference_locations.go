@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidReference = errors.New("invalid reference location")
+
+type ReferenceLocation struct {
+	Key       string
+	Name      string  `mapstructure:"name"`
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+}
+
+type ReferenceDistance struct {
+	Reference  ReferenceLocation
+	DistanceKm float64
+	MinKm      float64
+	MaxKm      float64
+}
+
+// LoadReferenceLocations reads reference_locations from the configuration,
+// sorted by key. When none are configured the legacy fixed_location.argentina
+// entry is used as "buenos_aires".
+func LoadReferenceLocations() ([]ReferenceLocation, error) {
+	var configured map[string]ReferenceLocation
+	if err := viper.UnmarshalKey("reference_locations", &configured); err != nil {
+		return nil, fmt.Errorf("error decoding reference locations: %w", err)
+	}
+
+	if len(configured) == 0 {
+		lat, lng, err := getBuenosAiresLatLng()
+		if err != nil {
+			return nil, err
+		}
+		return []ReferenceLocation{{Key: "buenos_aires", Name: "Buenos Aires", Latitude: lat, Longitude: lng}}, nil
+	}
+
+	references := make([]ReferenceLocation, 0, len(configured))
+	for key, reference := range configured {
+		reference.Key = key
+		if reference.Name == "" {
+			reference.Name = key
+		}
+		references = append(references, reference)
+	}
+	sort.Slice(references, func(i, j int) bool { return references[i].Key < references[j].Key })
+
+	return references, nil
+}
+
+// ResolveReferenceLocations turns each value, either a configured reference
+// name or a "lat,lng" pair, into a reference location. No values selects every
+// configured reference.
+func ResolveReferenceLocations(values []string) ([]ReferenceLocation, error) {
+	configured, err := LoadReferenceLocations()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return configured, nil
+	}
+
+	references := make([]ReferenceLocation, 0, len(values))
+	for _, value := range values {
+		reference, err := resolveReferenceLocation(configured, strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, reference)
+	}
+
+	return references, nil
+}
+
+func resolveReferenceLocation(configured []ReferenceLocation, value string) (ReferenceLocation, error) {
+	for _, reference := range configured {
+		if strings.EqualFold(reference.Key, value) {
+			return reference, nil
+		}
+	}
+
+	lat, lng, err := ParseLatLng(value)
+	if err != nil {
+		return ReferenceLocation{}, fmt.Errorf("%w: %q is neither a configured location nor a lat,lng pair", ErrInvalidReference, value)
+	}
+
+	return ReferenceLocation{Key: value, Name: value, Latitude: lat, Longitude: lng}, nil
+}
+
+func ParseLatLng(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: expected lat,lng", ErrInvalidReference)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("%w: invalid latitude %q", ErrInvalidReference, parts[0])
+	}
+
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, fmt.Errorf("%w: invalid longitude %q", ErrInvalidReference, parts[1])
+	}
+
+	return lat, lng, nil
+}
+
+func calculateReferenceDistances(references []ReferenceLocation, coordinates Coordinates) []ReferenceDistance {
+	if !coordinates.valid() {
+		return nil
+	}
+
+	distances := make([]ReferenceDistance, 0, len(references))
+	for _, reference := range references {
+		distance := calculateDistance(reference.Latitude, reference.Longitude, coordinates.Latitude, coordinates.Longitude)
+		minKm, maxKm := distanceBand(distance, coordinates.AccuracyRadiusKm)
+		distances = append(distances, ReferenceDistance{
+			Reference:  reference,
+			DistanceKm: distance,
+			MinKm:      minKm,
+			MaxKm:      maxKm,
+		})
+	}
+	return distances
+}
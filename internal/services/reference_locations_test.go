@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseLatLng(t *testing.T) {
+	tests := []struct {
+		value    string
+		lat, lng float64
+		wantErr  bool
+	}{
+		{value: "-34.61,-58.38", lat: -34.61, lng: -58.38},
+		{value: " 40.4168 , -3.7038 ", lat: 40.4168, lng: -3.7038},
+		{value: "90,180", lat: 90, lng: 180},
+		{value: "-90,-180", lat: -90, lng: -180},
+		{value: "90.1,0", wantErr: true},
+		{value: "0,-180.5", wantErr: true},
+		{value: "north,west", wantErr: true},
+		{value: "1,2,3", wantErr: true},
+		{value: "12.5", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			lat, lng, err := ParseLatLng(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReference) {
+					t.Fatalf("expected ErrInvalidReference, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lat != tt.lat || lng != tt.lng {
+				t.Fatalf("got %v,%v, want %v,%v", lat, lng, tt.lat, tt.lng)
+			}
+		})
+	}
+}
+
+func TestResolveReferenceLocations(t *testing.T) {
+	viper.Set("reference_locations", map[string]interface{}{
+		"madrid":       map[string]interface{}{"name": "Madrid", "latitude": 40.4168, "longitude": -3.7038},
+		"buenos_aires": map[string]interface{}{"name": "Buenos Aires", "latitude": -34.61, "longitude": -58.38},
+		"tokyo":        map[string]interface{}{"latitude": 35.6762, "longitude": 139.6503},
+	})
+	t.Cleanup(viper.Reset)
+
+	tests := []struct {
+		name     string
+		values   []string
+		wantKeys []string
+		wantErr  bool
+	}{
+		{name: "all configured, sorted by key", wantKeys: []string{"buenos_aires", "madrid", "tokyo"}},
+		{name: "by name", values: []string{"madrid"}, wantKeys: []string{"madrid"}},
+		{name: "case insensitive", values: []string{" Madrid "}, wantKeys: []string{"madrid"}},
+		{name: "lat,lng pair", values: []string{"10,20", "tokyo"}, wantKeys: []string{"10,20", "tokyo"}},
+		{name: "unknown name", values: []string{"paris"}, wantErr: true},
+		{name: "out of range", values: []string{"100,20"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			references, err := ResolveReferenceLocations(tt.values)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReference) {
+					t.Fatalf("expected ErrInvalidReference, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(references) != len(tt.wantKeys) {
+				t.Fatalf("got %+v, want keys %v", references, tt.wantKeys)
+			}
+			for i, reference := range references {
+				if reference.Key != tt.wantKeys[i] {
+					t.Fatalf("got %+v, want keys %v", references, tt.wantKeys)
+				}
+			}
+		})
+	}
+
+	references, _ := ResolveReferenceLocations([]string{"tokyo"})
+	if references[0].Name != "tokyo" {
+		t.Fatalf("expected the key as the default name, got %q", references[0].Name)
+	}
+}
+
+func TestReferenceLocationsFallBackToFixedLocation(t *testing.T) {
+	viper.Set("fixed_location.argentina.latitude", -34.61)
+	viper.Set("fixed_location.argentina.longitude", -58.38)
+	t.Cleanup(viper.Reset)
+
+	references, err := LoadReferenceLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ReferenceLocation{Key: "buenos_aires", Name: "Buenos Aires", Latitude: -34.61, Longitude: -58.38}
+	if len(references) != 1 || references[0] != want {
+		t.Fatalf("got %+v, want %+v", references, want)
+	}
+
+	viper.Reset()
+	if _, err := LoadReferenceLocations(); err == nil {
+		t.Fatal("expected an error without reference_locations or fixed_location")
+	}
+}
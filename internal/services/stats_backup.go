@@ -0,0 +1,571 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidStatsBackup = errors.New("invalid stats backup")
+
+const (
+	StatsFormatJSON   = "json"
+	StatsFormatNDJSON = "ndjson"
+	StatsFormatCSV    = "csv"
+
+	StatsImportMerge   = "merge"
+	StatsImportReplace = "replace"
+
+	statsRecordTotal       = "total"
+	statsRecordExtreme     = "extreme"
+	statsRecordCountry     = "country"
+	statsRecordHistogram   = "histogram"
+	statsRecordLeaderboard = "leaderboard"
+	statsRecordUnique      = "unique"
+
+	statsKeyPattern = "stats:*"
+	// Staging keys live outside stats:* so that a reset during an import
+	// does not delete them.
+	statsImportKeyPrefix  = "import:stats:"
+	statsImportStagingTTL = time.Hour
+)
+
+var statsCSVHeader = []string{"type", "name", "member", "value", "data"}
+
+// mergeExtremeScript keeps the farthest or closest distance of the stored and
+// the imported statistics.
+const mergeExtremeScript = `
+local distance = tonumber(ARGV[2])
+local current = tonumber(redis.call('HGET', KEYS[1], ARGV[1] .. '_distance'))
+if not current or (ARGV[1] == 'farthest' and distance > current) or (ARGV[1] == 'closest' and distance < current) then
+	redis.call('HSET', KEYS[1], ARGV[1] .. '_distance', ARGV[2], ARGV[1] .. '_country', ARGV[3])
+end
+return 1
+`
+
+// StatsRecord is one value of the all-time statistics:
+//
+//	total        name is total_distance or total_requests
+//	extreme      name is farthest or closest, member the country
+//	country      name is the country, member distance or requests
+//	histogram    name is the country (empty for all), member the bucket index
+//	leaderboard  name is the dimension, member the network, ASN or caller
+//	unique       name is the country (empty for all), data the HyperLogLog
+//
+// The minute, hour and day buckets are not exported since they expire anyway.
+type StatsRecord struct {
+	Type   string  `json:"type"`
+	Name   string  `json:"name,omitempty"`
+	Member string  `json:"member,omitempty"`
+	Value  float64 `json:"value"`
+	Data   string  `json:"data,omitempty"`
+}
+
+func ExportStats(redisCache interfaces.Cache) ([]StatsRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	fields, err := redisCache.HGetAll(ctx, distanceStatsKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading distance stats: %w", err)
+	}
+
+	records := []StatsRecord{
+		{Type: statsRecordTotal, Name: "total_distance", Value: parseStatFloat(fields["total_distance"])},
+		{Type: statsRecordTotal, Name: "total_requests", Value: parseStatFloat(fields["total_requests"])},
+	}
+	for _, extreme := range []string{"farthest", "closest"} {
+		if country, ok := fields[extreme+"_country"]; ok {
+			records = append(records, StatsRecord{
+				Type:   statsRecordExtreme,
+				Name:   extreme,
+				Member: country,
+				Value:  parseStatFloat(fields[extreme+"_distance"]),
+			})
+		}
+	}
+
+	countries := make(map[string]bool)
+	for member, key := range map[string]string{"distance": countryDistanceStatsKey, "requests": countryRequestsStatsKey} {
+		scores, err := redisCache.ZRangeWithScores(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading country stats: %w", err)
+		}
+		for _, score := range scores {
+			countries[score.Member] = true
+			records = append(records, StatsRecord{Type: statsRecordCountry, Name: score.Member, Member: member, Value: score.Score})
+		}
+	}
+
+	names := []string{""}
+	for country := range countries {
+		names = append(names, country)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		histogramKey, uniqueKey := distanceHistogramKey, uniqueVisitorsKey
+		if name != "" {
+			histogramKey, uniqueKey = countryHistogramPrefix+name, countryUniqueVisitorsKey(name)
+		}
+
+		buckets, err := redisCache.HGetAll(ctx, histogramKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading distance histogram %s: %w", histogramKey, err)
+		}
+		for index, count := range buckets {
+			records = append(records, StatsRecord{Type: statsRecordHistogram, Name: name, Member: index, Value: parseStatFloat(count)})
+		}
+
+		exists, err := redisCache.Exists(ctx, uniqueKey)
+		if err != nil {
+			return nil, fmt.Errorf("error checking existence in cache: %w", err)
+		}
+		if exists == 0 {
+			continue
+		}
+		sketch, err := redisCache.Get(ctx, uniqueKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading unique visitors %s: %w", uniqueKey, err)
+		}
+		records = append(records, StatsRecord{Type: statsRecordUnique, Name: name, Data: base64.StdEncoding.EncodeToString(sketch)})
+	}
+
+	for _, dimension := range leaderboardDimensions {
+		if dimension == LeaderboardCountry {
+			continue
+		}
+		scores, err := redisCache.ZRangeWithScores(ctx, leaderboardKey(dimension))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s leaderboard: %w", dimension, err)
+		}
+		for _, score := range scores {
+			records = append(records, StatsRecord{Type: statsRecordLeaderboard, Name: dimension, Member: score.Member, Value: score.Score})
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Type != b.Type {
+			return statsRecordOrder(a.Type) < statsRecordOrder(b.Type)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Member < b.Member
+	})
+
+	return records, nil
+}
+
+func statsRecordOrder(recordType string) int {
+	for i, t := range []string{statsRecordTotal, statsRecordExtreme, statsRecordCountry, statsRecordHistogram, statsRecordLeaderboard, statsRecordUnique} {
+		if t == recordType {
+			return i
+		}
+	}
+	return -1
+}
+
+func WriteStatsRecords(w io.Writer, format string, records []StatsRecord) error {
+	switch strings.ToLower(format) {
+	case "", StatsFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case StatsFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case StatsFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(statsCSVHeader); err != nil {
+			return err
+		}
+		for _, record := range records {
+			row := []string{record.Type, record.Name, record.Member, strconv.FormatFloat(record.Value, 'f', -1, 64), record.Data}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("%w: unknown format %q (use json, ndjson or csv)", ErrInvalidStatsBackup, format)
+	}
+}
+
+func ReadStatsRecords(r io.Reader, format string) ([]StatsRecord, error) {
+	var records []StatsRecord
+
+	switch strings.ToLower(format) {
+	case "", StatsFormatJSON:
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStatsBackup, err)
+		}
+	case StatsFormatNDJSON:
+		decoder := json.NewDecoder(r)
+		for {
+			var record StatsRecord
+			err := decoder.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidStatsBackup, len(records)+1, err)
+			}
+			records = append(records, record)
+		}
+	case StatsFormatCSV:
+		rows, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStatsBackup, err)
+		}
+		for i, row := range rows {
+			if len(row) != len(statsCSVHeader) {
+				return nil, fmt.Errorf("%w: row %d must have %s", ErrInvalidStatsBackup, i+1, strings.Join(statsCSVHeader, ","))
+			}
+			if i == 0 && strings.EqualFold(row[0], "type") {
+				continue
+			}
+			value, err := strconv.ParseFloat(row[3], 64)
+			if err != nil && row[3] != "" {
+				return nil, fmt.Errorf("%w: row %d: invalid value %q", ErrInvalidStatsBackup, i+1, row[3])
+			}
+			records = append(records, StatsRecord{Type: row[0], Name: row[1], Member: row[2], Value: value, Data: row[4]})
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q (use json, ndjson or csv)", ErrInvalidStatsBackup, format)
+	}
+
+	return records, nil
+}
+
+// applyStatsImportScript moves the staged keys into place in one atomic step.
+// KEYS are pairs of staged and target keys, followed in replace mode by the
+// current keys of the exported families, which are deleted first.
+// ARGV: mode, then the kind of each pair: totals, hash, zset or hll.
+const applyStatsImportScript = `
+local mode = ARGV[1]
+local count = #ARGV - 1
+
+if mode == 'replace' then
+	for i = 2 * count + 1, #KEYS do
+		redis.call('DEL', KEYS[i])
+	end
+end
+
+for i = 1, count do
+	local staged, target, kind = KEYS[2 * i - 1], KEYS[2 * i], ARGV[i + 1]
+	if redis.call('EXISTS', staged) == 1 then
+		if mode == 'replace' then
+			redis.call('RENAME', staged, target)
+			redis.call('PERSIST', target)
+		else
+			if kind == 'zset' then
+				redis.call('ZUNIONSTORE', target, 2, target, staged)
+			elseif kind == 'hll' then
+				redis.call('PFMERGE', target, staged)
+			elseif kind == 'hash' then
+				local fields = redis.call('HGETALL', staged)
+				for j = 1, #fields, 2 do
+					redis.call('HINCRBYFLOAT', target, fields[j], fields[j + 1])
+				end
+			elseif kind == 'totals' then
+				for _, field in ipairs({'total_distance', 'total_requests'}) do
+					local value = redis.call('HGET', staged, field)
+					if value then
+						redis.call('HINCRBYFLOAT', target, field, value)
+					end
+				end
+				for _, extreme in ipairs({'farthest', 'closest'}) do
+					local raw = redis.call('HGET', staged, extreme .. '_distance')
+					local distance = tonumber(raw)
+					local current = tonumber(redis.call('HGET', target, extreme .. '_distance'))
+					if distance and (not current or (extreme == 'farthest' and distance > current) or (extreme == 'closest' and distance < current)) then
+						redis.call('HSET', target, extreme .. '_distance', raw, extreme .. '_country', redis.call('HGET', staged, extreme .. '_country'))
+					end
+				end
+			end
+			redis.call('DEL', staged)
+		end
+	end
+end
+return 1
+`
+
+// statsImport stages the records under keys of its own, so that a failed
+// import leaves the live statistics untouched.
+type statsImport struct {
+	prefix string
+	// kinds maps each target key to how it is applied.
+	kinds map[string]string
+}
+
+func (si *statsImport) staged(target, kind string) string {
+	si.kinds[target] = kind
+	return si.prefix + target
+}
+
+// ImportStats adds the records to the stored statistics, or replaces them
+// when mode is replace. Every record is validated and written to staging keys
+// first, and the staged keys are then moved into place in a single script,
+// so readers never see a partial import and a failure changes nothing.
+// Replace only deletes the families an export contains; the time-windowed
+// buckets are kept.
+func ImportStats(redisCache interfaces.Cache, records []StatsRecord, mode string) error {
+	if mode == "" {
+		mode = StatsImportMerge
+	}
+	if mode != StatsImportMerge && mode != StatsImportReplace {
+		return fmt.Errorf("%w: unknown mode %q (use merge or replace)", ErrInvalidStatsBackup, mode)
+	}
+
+	sketches := make([][]byte, len(records))
+	for i, record := range records {
+		sketch, err := validateStatsRecord(record)
+		if err != nil {
+			return fmt.Errorf("%w: record %d: %v", ErrInvalidStatsBackup, i+1, err)
+		}
+		sketches[i] = sketch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	si := &statsImport{
+		prefix: fmt.Sprintf("%s%d:", statsImportKeyPrefix, time.Now().UnixNano()),
+		kinds:  make(map[string]string),
+	}
+
+	if err := si.stage(ctx, redisCache, records, sketches); err != nil {
+		si.discard(redisCache)
+		return err
+	}
+	if err := si.apply(ctx, redisCache, mode); err != nil {
+		si.discard(redisCache)
+		return err
+	}
+	return nil
+}
+
+func (si *statsImport) stage(ctx context.Context, cache interfaces.Cache, records []StatsRecord, sketches [][]byte) error {
+	for i, record := range records {
+		if err := si.stageRecord(ctx, cache, record, sketches[i]); err != nil {
+			return fmt.Errorf("error importing record %d: %w", i+1, err)
+		}
+	}
+
+	// The staged keys expire in case the import is interrupted.
+	for target := range si.kinds {
+		if err := cache.Expire(ctx, si.prefix+target, statsImportStagingTTL); err != nil {
+			return fmt.Errorf("error staging %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func (si *statsImport) apply(ctx context.Context, cache interfaces.Cache, mode string) error {
+	targets := make([]string, 0, len(si.kinds))
+	for target := range si.kinds {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	keys := make([]string, 0, 2*len(targets))
+	args := []interface{}{mode}
+	for _, target := range targets {
+		keys = append(keys, si.prefix+target, target)
+		args = append(args, si.kinds[target])
+	}
+
+	if mode == StatsImportReplace {
+		current, err := exportedStatsKeys(ctx, cache)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, current...)
+	}
+
+	if _, err := cache.Eval(ctx, applyStatsImportScript, keys, args...); err != nil {
+		return fmt.Errorf("error applying imported stats: %w", err)
+	}
+
+	if mode == StatsImportReplace {
+		localVisitors.Lock()
+		for _, key := range keys {
+			delete(localVisitors.sketches, key)
+		}
+		localVisitors.Unlock()
+	}
+	return nil
+}
+
+// discard removes the staged keys of a failed import with a fresh context,
+// since the import's own may be what expired.
+func (si *statsImport) discard(cache interfaces.Cache) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	keys, err := cache.Keys(ctx, si.prefix+"*")
+	if err == nil && len(keys) > 0 {
+		err = cache.Del(ctx, keys...)
+	}
+	if err != nil {
+		fmt.Printf("Error removing staged stats import %s: %v\n", si.prefix, err)
+	}
+}
+
+// exportedStatsKeys lists the current keys of the families ExportStats
+// writes, which are the ones a replace import deletes.
+func exportedStatsKeys(ctx context.Context, cache interfaces.Cache) ([]string, error) {
+	keys := []string{distanceStatsKey, countryDistanceStatsKey, countryRequestsStatsKey, distanceHistogramKey, uniqueVisitorsKey}
+	for _, dimension := range leaderboardDimensions {
+		if dimension != LeaderboardCountry {
+			keys = append(keys, leaderboardKey(dimension))
+		}
+	}
+
+	for _, prefix := range []string{countryHistogramPrefix, countryUniqueKeyPrefix} {
+		matches, err := cache.Keys(ctx, prefix+"*")
+		if err != nil {
+			return nil, fmt.Errorf("error listing stats keys: %w", err)
+		}
+		keys = append(keys, matches...)
+	}
+	return keys, nil
+}
+
+func validateStatsRecord(record StatsRecord) ([]byte, error) {
+	switch record.Type {
+	case statsRecordTotal:
+		if record.Name != "total_distance" && record.Name != "total_requests" {
+			return nil, fmt.Errorf("unknown total %q", record.Name)
+		}
+	case statsRecordExtreme:
+		if record.Name != "farthest" && record.Name != "closest" {
+			return nil, fmt.Errorf("unknown extreme %q", record.Name)
+		}
+	case statsRecordCountry:
+		if record.Name == "" || (record.Member != "distance" && record.Member != "requests") {
+			return nil, errors.New("country records need a country and distance or requests")
+		}
+	case statsRecordHistogram:
+		if _, err := strconv.Atoi(record.Member); err != nil {
+			return nil, fmt.Errorf("invalid histogram bucket %q", record.Member)
+		}
+	case statsRecordLeaderboard:
+		if !isLeaderboardDimension(record.Name) || record.Name == LeaderboardCountry || record.Member == "" {
+			return nil, fmt.Errorf("invalid leaderboard %q", record.Name)
+		}
+	case statsRecordUnique:
+		sketch, err := base64.StdEncoding.DecodeString(record.Data)
+		if err != nil || len(sketch) == 0 {
+			return nil, errors.New("unique records need a base64 HyperLogLog in data")
+		}
+		return sketch, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", record.Type)
+	}
+	// Counts and distances are never negative, and NaN or infinite values
+	// would poison every sum they are added to.
+	if math.IsNaN(record.Value) || math.IsInf(record.Value, 0) || record.Value < 0 {
+		return nil, fmt.Errorf("invalid value %v for a %s record", record.Value, record.Type)
+	}
+	return nil, nil
+}
+
+func (si *statsImport) stageRecord(ctx context.Context, cache interfaces.Cache, record StatsRecord, sketch []byte) error {
+	switch record.Type {
+	case statsRecordTotal:
+		_, err := cache.HIncrByFloat(ctx, si.staged(distanceStatsKey, "totals"), record.Name, record.Value)
+		return err
+	case statsRecordExtreme:
+		_, err := cache.Eval(ctx, mergeExtremeScript, []string{si.staged(distanceStatsKey, "totals")},
+			record.Name, strconv.FormatFloat(record.Value, 'f', -1, 64), record.Member)
+		return err
+	case statsRecordCountry:
+		key := countryDistanceStatsKey
+		if record.Member == "requests" {
+			key = countryRequestsStatsKey
+		}
+		_, err := cache.ZIncrBy(ctx, si.staged(key, "zset"), record.Value, record.Name)
+		return err
+	case statsRecordHistogram:
+		key := distanceHistogramKey
+		if record.Name != "" {
+			key = countryHistogramPrefix + record.Name
+		}
+		_, err := cache.HIncrByFloat(ctx, si.staged(key, "hash"), record.Member, record.Value)
+		return err
+	case statsRecordLeaderboard:
+		_, err := cache.ZIncrBy(ctx, si.staged(leaderboardKey(record.Name), "zset"), record.Value, record.Member)
+		return err
+	case statsRecordUnique:
+		key := uniqueVisitorsKey
+		if record.Name != "" {
+			key = countryUniqueVisitorsKey(record.Name)
+		}
+		// PFMERGE keeps the union of the sketches, so merging is exact.
+		sketchKey := si.prefix + "sketch"
+		if err := cache.Set(ctx, sketchKey, sketch, statsImportStagingTTL); err != nil {
+			return err
+		}
+		if err := cache.PFMerge(ctx, si.staged(key, "hll"), sketchKey); err != nil {
+			return err
+		}
+		return cache.Del(ctx, sketchKey)
+	}
+	return nil
+}
+
+// ResetStats deletes every statistics key, including the windowed buckets,
+// the leaderboards and the legacy distance_stats blob, and returns how many
+// keys were removed.
+func ResetStats(redisCache interfaces.Cache) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	keys, err := redisCache.Keys(ctx, statsKeyPattern)
+	if err != nil {
+		return 0, fmt.Errorf("error listing stats keys: %w", err)
+	}
+	for _, key := range []string{legacyDistanceStatsKey, migratedDistanceStatsKey} {
+		exists, err := redisCache.Exists(ctx, key)
+		if err != nil {
+			return 0, fmt.Errorf("error checking existence in cache: %w", err)
+		}
+		if exists > 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	const batch = 500
+	for start := 0; start < len(keys); start += batch {
+		if err := redisCache.Del(ctx, keys[start:min(start+batch, len(keys))]...); err != nil {
+			return 0, fmt.Errorf("error deleting stats keys: %w", err)
+		}
+	}
+
+	localVisitors.Lock()
+	localVisitors.sketches = make(map[string]*localSketch)
+	localVisitors.Unlock()
+
+	return len(keys), nil
+}
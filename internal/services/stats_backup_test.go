@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var testStatsRecords = []StatsRecord{
+	{Type: statsRecordTotal, Name: "total_distance", Value: 3000.5},
+	{Type: statsRecordTotal, Name: "total_requests", Value: 2},
+	{Type: statsRecordExtreme, Name: "farthest", Member: "Japan", Value: 18000.25},
+	{Type: statsRecordExtreme, Name: "closest", Member: "Uruguay", Value: 200},
+	{Type: statsRecordCountry, Name: "Japan", Member: "distance", Value: 18000.25},
+	{Type: statsRecordCountry, Name: "Japan", Member: "requests", Value: 1},
+	{Type: statsRecordHistogram, Name: "Japan", Member: "226", Value: 1},
+	{Type: statsRecordLeaderboard, Name: LeaderboardCaller, Member: "1.1.1.1", Value: 4},
+}
+
+func TestReadStatsRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []StatsRecord
+		wantErr bool
+	}{
+		{
+			name:   "json",
+			format: "json",
+			input:  `[{"type":"total","name":"total_requests","value":2},{"type":"extreme","name":"farthest","member":"Japan","value":18000.25}]`,
+			want:   []StatsRecord{{Type: "total", Name: "total_requests", Value: 2}, {Type: "extreme", Name: "farthest", Member: "Japan", Value: 18000.25}},
+		},
+		{
+			name:  "default is json",
+			input: `[{"type":"unique","data":"AAE="}]`,
+			want:  []StatsRecord{{Type: "unique", Data: "AAE="}},
+		},
+		{
+			name:   "ndjson",
+			format: "NDJSON",
+			input:  "{\"type\":\"country\",\"name\":\"Japan\",\"member\":\"requests\",\"value\":1}\n\n{\"type\":\"leaderboard\",\"name\":\"caller\",\"member\":\"1.1.1.1\",\"value\":4}\n",
+			want:   []StatsRecord{{Type: "country", Name: "Japan", Member: "requests", Value: 1}, {Type: "leaderboard", Name: "caller", Member: "1.1.1.1", Value: 4}},
+		},
+		{
+			name:   "csv with header",
+			format: "csv",
+			input:  "type,name,member,value,data\nhistogram,,226,3,\nunique,Japan,,,AAE=\n",
+			want:   []StatsRecord{{Type: "histogram", Member: "226", Value: 3}, {Type: "unique", Name: "Japan", Data: "AAE="}},
+		},
+		{
+			name:   "csv without header",
+			format: "csv",
+			input:  "total,total_distance,,3000.5,\n",
+			want:   []StatsRecord{{Type: "total", Name: "total_distance", Value: 3000.5}},
+		},
+		{name: "empty json", format: "json", input: "[]", want: nil},
+		{name: "broken json", format: "json", input: `[{"type":`, wantErr: true},
+		{name: "broken ndjson line", format: "ndjson", input: "{\"type\":\"total\"}\nnope\n", wantErr: true},
+		{name: "csv missing columns", format: "csv", input: "total,total_distance,,1\n", wantErr: true},
+		{name: "csv bad value", format: "csv", input: "total,total_distance,,many,\n", wantErr: true},
+		{name: "unknown format", format: "xml", input: "<stats/>", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadStatsRecords(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStatsBackup) {
+					t.Fatalf("expected ErrInvalidStatsBackup, got %v (%+v)", err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("record %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsRecordsRoundTrip(t *testing.T) {
+	for _, format := range []string{StatsFormatJSON, StatsFormatNDJSON, StatsFormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := WriteStatsRecords(&buffer, format, testStatsRecords); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadStatsRecords(&buffer, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(testStatsRecords) {
+				t.Fatalf("got %d records, want %d", len(got), len(testStatsRecords))
+			}
+			for i := range got {
+				if got[i] != testStatsRecords[i] {
+					t.Fatalf("record %d = %+v, want %+v", i, got[i], testStatsRecords[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImportStats(t *testing.T) {
+	viper.Set("context.timeout", time.Second)
+	t.Cleanup(viper.Reset)
+
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	server.HSet(distanceStatsKey, "total_distance", "1000", "total_requests", "1",
+		"farthest_distance", "1000", "farthest_country", "Brazil", "closest_distance", "1000", "closest_country", "Brazil")
+	if _, err := server.ZAdd(countryRequestsStatsKey, 1, "Brazil"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ZAdd(leaderboardKey(LeaderboardNetwork), 7, "190.2.1.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	bucket := distanceBucketKey(GranularityHour, time.Now().UTC().Truncate(time.Hour))
+	server.HSet(bucket, "total_requests", "1")
+
+	// An invalid record aborts the import before anything is written.
+	invalid := append([]StatsRecord{}, testStatsRecords...)
+	invalid = append(invalid, StatsRecord{Type: "planet"})
+	if err := ImportStats(redisCache, invalid, StatsImportReplace); !errors.Is(err, ErrInvalidStatsBackup) {
+		t.Fatalf("expected ErrInvalidStatsBackup, got %v", err)
+	}
+	if stats, _ := GetDistanceStatsFromCache(ctx, redisCache); stats.TotalRequests != 1 {
+		t.Fatalf("invalid import changed the stats to %d requests", stats.TotalRequests)
+	}
+
+	if err := ImportStats(redisCache, testStatsRecords, StatsImportMerge); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := GetDistanceStatsFromCache(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 3 || stats.TotalDistance != 4000.5 {
+		t.Fatalf("merge gave %d requests and %v km", stats.TotalRequests, stats.TotalDistance)
+	}
+	if stats.FarthestCountryName != "Japan" || stats.ClosestCountryName != "Uruguay" || stats.FarthestDistance != 18000.25 {
+		t.Fatalf("merge kept the wrong extremes: %+v", stats)
+	}
+	if stats.CountryDistances["Brazil"].Requests != 1 || stats.CountryDistances["Japan"].Requests != 1 {
+		t.Fatalf("merge lost countries: %+v", stats.CountryDistances)
+	}
+
+	if err := ImportStats(redisCache, testStatsRecords, StatsImportReplace); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = GetDistanceStatsFromCache(ctx, redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 2 || stats.TotalDistance != 3000.5 || stats.ClosestCountryName != "Uruguay" {
+		t.Fatalf("replace gave %+v", stats)
+	}
+	if _, exists := stats.CountryDistances["Brazil"]; exists {
+		t.Fatal("replace kept a country the import does not have")
+	}
+	if server.Exists(leaderboardKey(LeaderboardNetwork)) {
+		t.Fatal("replace kept an exported leaderboard the import does not have")
+	}
+	if !server.Exists(bucket) {
+		t.Fatal("replace deleted a time-windowed bucket")
+	}
+	if ttl := server.TTL(distanceStatsKey); ttl != 0 {
+		t.Fatalf("replaced stats expire in %v", ttl)
+	}
+
+	if keys, _ := redisCache.Keys(ctx, statsImportKeyPrefix+"*"); len(keys) != 0 {
+		t.Fatalf("staging keys were left behind: %v", keys)
+	}
+}
+
+func TestValidateStatsRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  StatsRecord
+		wantErr bool
+	}{
+		{name: "total", record: testStatsRecords[0]},
+		{name: "zero count", record: StatsRecord{Type: statsRecordCountry, Name: "Japan", Member: "requests"}},
+		{name: "negative total", record: StatsRecord{Type: statsRecordTotal, Name: "total_requests", Value: -2}, wantErr: true},
+		{name: "NaN total", record: StatsRecord{Type: statsRecordTotal, Name: "total_distance", Value: math.NaN()}, wantErr: true},
+		{name: "infinite extreme", record: StatsRecord{Type: statsRecordExtreme, Name: "farthest", Member: "Japan", Value: math.Inf(1)}, wantErr: true},
+		{name: "negative country count", record: StatsRecord{Type: statsRecordCountry, Name: "Japan", Member: "requests", Value: -1}, wantErr: true},
+		{name: "NaN histogram bucket", record: StatsRecord{Type: statsRecordHistogram, Member: "226", Value: math.NaN()}, wantErr: true},
+		{name: "negative leaderboard", record: StatsRecord{Type: statsRecordLeaderboard, Name: LeaderboardCaller, Member: "1.1.1.1", Value: -4}, wantErr: true},
+		{name: "unknown total", record: StatsRecord{Type: statsRecordTotal, Name: "total_time"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateStatsRecord(tt.record)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected the record to be rejected")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResetStatsKeepsStagingKeys(t *testing.T) {
+	viper.Set("context.timeout", time.Second)
+	t.Cleanup(viper.Reset)
+
+	server, redisCache := newTestRedis(t)
+
+	server.HSet(distanceStatsKey, "total_requests", "1")
+	staged := statsImportKeyPrefix + "1:" + distanceStatsKey
+	server.HSet(staged, "total_requests", "2")
+
+	deleted, err := ResetStats(redisCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 || server.Exists(distanceStatsKey) {
+		t.Fatalf("expected the stats to be deleted, got %d keys", deleted)
+	}
+	if !server.Exists(staged) {
+		t.Fatal("reset deleted the staging keys of an import in progress")
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/timezone"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidTimeOption = errors.New("invalid time option")
+
+const (
+	TimeFormatRFC1123 = "rfc1123"
+	TimeFormatRFC3339 = "rfc3339"
+	TimeFormatUnix    = "unix"
+)
+
+type businessHours struct {
+	start time.Duration
+	end   time.Duration
+	days  map[time.Weekday]bool
+}
+
+// FormatTime renders t as RFC1123 (default), RFC3339, Unix epoch seconds or,
+// for any other value, as a Go reference-time layout.
+func FormatTime(t time.Time, format string) string {
+	switch strings.ToLower(format) {
+	case "", TimeFormatRFC1123:
+		return t.Format(time.RFC1123)
+	case TimeFormatRFC3339:
+		return t.Format(time.RFC3339)
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		return t.Format(format)
+	}
+}
+
+func ValidateTimeFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", TimeFormatRFC1123, TimeFormatRFC3339, TimeFormatUnix:
+		return nil
+	}
+
+	reference := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	if reference.Format(format) == format {
+		return fmt.Errorf("%w: %q is not a time layout", ErrInvalidTimeOption, format)
+	}
+	return nil
+}
+
+// ResolveHomeLocation returns the reference time zone used for offsets: an
+// IANA name, "caller" for the time zone of callerIP, or the configured
+// time.home_timezone when value is empty.
+func ResolveHomeLocation(httpClient interfaces.Client, value, callerIP string) (*time.Location, error) {
+	if value == "" {
+		value = viper.GetString("time.home_timezone")
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.EqualFold(value, "caller") {
+		ipLocation, err := NewIPLocation(httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("error creating IP location service: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+		defer cancel()
+
+		participant, err := resolveParticipant(ctx, ipLocation, callerIP, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot resolve caller time zone: %v", ErrInvalidTimeOption, err)
+		}
+		return participant.Location, nil
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidTimeOption, value)
+	}
+	return location, nil
+}
+
+func loadBusinessHours() (businessHours, error) {
+	start, err := parseClock(viper.GetString("timeplanner.work_start"))
+	if err != nil {
+		return businessHours{}, err
+	}
+
+	end, err := parseClock(viper.GetString("timeplanner.work_end"))
+	if err != nil {
+		return businessHours{}, err
+	}
+
+	days, err := parseWorkingDays(viper.GetStringSlice("timeplanner.working_days"))
+	if err != nil {
+		return businessHours{}, err
+	}
+
+	return businessHours{start: start, end: end, days: days}, nil
+}
+
+func (bh businessHours) contains(local time.Time) bool {
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+
+	if bh.end > bh.start {
+		return bh.days[local.Weekday()] && clock >= bh.start && clock < bh.end
+	}
+
+	if clock >= bh.start {
+		return bh.days[local.Weekday()]
+	}
+	return clock < bh.end && bh.days[local.AddDate(0, 0, -1).Weekday()]
+}
+
+func applyTimezoneContext(info *timezone.Info, hours businessHours, hoursErr error, home *time.Location) {
+	if hoursErr == nil {
+		info.IsBusinessHours = hours.contains(info.CurrentTime)
+	}
+
+	if home != nil {
+		_, homeOffset := info.CurrentTime.In(home).Zone()
+		info.HomeTimeZone = home.String()
+		info.OffsetFromHomeSeconds = info.OffsetSeconds - homeOffset
+	}
+}
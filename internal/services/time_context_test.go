@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	moment := time.Date(2024, time.March, 9, 14, 30, 0, 0, time.FixedZone("ART", -3*3600))
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "Sat, 09 Mar 2024 14:30:00 ART"},
+		{"RFC1123", "Sat, 09 Mar 2024 14:30:00 ART"},
+		{"rfc3339", "2024-03-09T14:30:00-03:00"},
+		{"unix", "1710005400"},
+		{"2006-01-02 15:04", "2024-03-09 14:30"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatTime(moment, tt.format); got != tt.want {
+			t.Errorf("FormatTime(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTimeFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		valid  bool
+	}{
+		{"", true},
+		{"rfc1123", true},
+		{"RFC3339", true},
+		{"unix", true},
+		{"15:04 MST", true},
+		{"2006-01-02", true},
+		{"iso", false},
+		{"hh:mm", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTimeFormat(tt.format)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateTimeFormat(%q) = %v, want nil", tt.format, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidTimeOption) {
+			t.Errorf("ValidateTimeFormat(%q) = %v, want ErrInvalidTimeOption", tt.format, err)
+		}
+	}
+}
+
+func TestApplyTimezonesSeparatesKeys(t *testing.T) {
+	details := &IPLocationDetails{Cca2: "ES", LegacyTimezones: []string{"UTC", "UTC+01:00"}}
+	applyTimezones(details, "", IPLocationOptions{TimeFormat: "unix"})
+
+	if len(details.CurrentTimeByTimezone) != 2 {
+		t.Fatalf("expected only the legacy keys, got %v", details.CurrentTimeByTimezone)
+	}
+	for _, key := range details.LegacyTimezones {
+		if _, ok := details.CurrentTimeByTimezone[key]; !ok {
+			t.Fatalf("missing legacy key %s in %v", key, details.CurrentTimeByTimezone)
+		}
+	}
+
+	if len(details.CurrentTimeByZone) != len(details.Timezones) {
+		t.Fatalf("expected one time per IANA zone, got %v", details.CurrentTimeByZone)
+	}
+	for _, info := range details.Timezones {
+		if _, ok := details.CurrentTimeByZone[info.Name]; !ok {
+			t.Fatalf("missing zone %s in %v", info.Name, details.CurrentTimeByZone)
+		}
+	}
+	if details.TimeZone != "Europe/Madrid" {
+		t.Fatalf("time zone = %s, want the country's first zone", details.TimeZone)
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/cgiraldoz/geo-ip-info/internal/timezone"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidPlan = errors.New("invalid time plan request")
+
+type TimePlanRequest struct {
+	IPs       []string
+	Days      int
+	WorkStart string
+	WorkEnd   string
+}
+
+type PlannerParticipant struct {
+	IP          string
+	CountryName string
+	City        string
+	TimeZone    string
+	Location    *time.Location
+	CurrentTime time.Time
+}
+
+type PlannerWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+type TimePlan struct {
+	Participants []PlannerParticipant
+	Windows      []PlannerWindow
+	WorkStart    string
+	WorkEnd      string
+	Days         int
+}
+
+func PlanMeeting(httpClient interfaces.Client, request TimePlanRequest) (*TimePlan, error) {
+	if len(request.IPs) < 2 {
+		return nil, fmt.Errorf("%w: at least two IP addresses are required", ErrInvalidPlan)
+	}
+
+	if request.Days <= 0 {
+		request.Days = viper.GetInt("timeplanner.days")
+	}
+	if maxDays := viper.GetInt("timeplanner.max_days"); maxDays > 0 && request.Days > maxDays {
+		return nil, fmt.Errorf("%w: days must be at most %d", ErrInvalidPlan, maxDays)
+	}
+	if request.WorkStart == "" {
+		request.WorkStart = viper.GetString("timeplanner.work_start")
+	}
+	if request.WorkEnd == "" {
+		request.WorkEnd = viper.GetString("timeplanner.work_end")
+	}
+
+	workStart, err := parseClock(request.WorkStart)
+	if err != nil {
+		return nil, err
+	}
+	workEnd, err := parseClock(request.WorkEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	workingDays, err := parseWorkingDays(viper.GetStringSlice("timeplanner.working_days"))
+	if err != nil {
+		return nil, err
+	}
+
+	ipLocation, err := NewIPLocation(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IP location service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	now := time.Now()
+	rangeEnd := now.Add(time.Duration(request.Days) * 24 * time.Hour)
+
+	plan := &TimePlan{WorkStart: request.WorkStart, WorkEnd: request.WorkEnd, Days: request.Days}
+	var windows []PlannerWindow
+
+	for i, ip := range request.IPs {
+		participant, err := resolveParticipant(ctx, ipLocation, ip, now)
+		if err != nil {
+			return nil, err
+		}
+		plan.Participants = append(plan.Participants, participant)
+
+		hours := workingHours(participant.Location, now, rangeEnd, workStart, workEnd, workingDays)
+		if i == 0 {
+			windows = hours
+		} else {
+			windows = intersectWindows(windows, hours)
+		}
+	}
+
+	minDuration := viper.GetDuration("timeplanner.min_duration")
+	for _, window := range windows {
+		if window.End.Sub(window.Start) >= minDuration {
+			plan.Windows = append(plan.Windows, window)
+		}
+	}
+
+	return plan, nil
+}
+
+func resolveParticipant(ctx context.Context, ipLocation *IPLocation, ip string, now time.Time) (PlannerParticipant, error) {
+	info, err := ipLocation.GetIPLocation(ctx, ip)
+	if err != nil {
+		return PlannerParticipant{}, fmt.Errorf("error getting IP location for %s: %w", ip, err)
+	}
+
+	zone := info.TimeZone
+	if zone == "" {
+		zones := timezone.CountryZones(info.IsoCode)
+		if len(zones) == 0 {
+			return PlannerParticipant{}, fmt.Errorf("no time zone known for %s (%s)", ip, info.IsoCode)
+		}
+		zone = zones[0]
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return PlannerParticipant{}, fmt.Errorf("unknown time zone %s for %s: %w", zone, ip, err)
+	}
+
+	return PlannerParticipant{
+		IP:          ip,
+		CountryName: info.Name,
+		City:        info.City,
+		TimeZone:    zone,
+		Location:    location,
+		CurrentTime: now.In(location),
+	}, nil
+}
+
+// workingHours lists the participant's working intervals between from and to.
+// Each day's bounds are built with time.Date in the participant's location so
+// DST transitions shift the UTC window accordingly.
+func workingHours(location *time.Location, from, to time.Time, workStart, workEnd time.Duration, workingDays map[time.Weekday]bool) []PlannerWindow {
+	var windows []PlannerWindow
+
+	localFrom := from.In(location)
+	day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day()-1, 0, 0, 0, 0, location)
+
+	for !day.After(to) {
+		if workingDays[day.Weekday()] {
+			start := atClock(day, workStart)
+			end := atClock(day, workEnd)
+			if !end.After(start) {
+				end = atClock(day.AddDate(0, 0, 1), workEnd)
+			}
+
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if end.After(start) {
+				windows = append(windows, PlannerWindow{Start: start.UTC(), End: end.UTC()})
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, location)
+	}
+
+	return windows
+}
+
+func atClock(day time.Time, clock time.Duration) time.Time {
+	hours := int(clock / time.Hour)
+	minutes := int(clock % time.Hour / time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, 0, 0, day.Location())
+}
+
+func intersectWindows(a, b []PlannerWindow) []PlannerWindow {
+	var result []PlannerWindow
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		start := a[i].Start
+		if b[j].Start.After(start) {
+			start = b[j].Start
+		}
+		end := a[i].End
+		if b[j].End.Before(end) {
+			end = b[j].End
+		}
+		if end.After(start) {
+			result = append(result, PlannerWindow{Start: start, End: end})
+		}
+
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return result
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a HH:MM time", ErrInvalidPlan, value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+func parseWorkingDays(values []string) (map[time.Weekday]bool, error) {
+	if len(values) == 0 {
+		values = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+
+	days := make(map[time.Weekday]bool, len(values))
+	for _, value := range values {
+		name := strings.ToLower(strings.TrimSpace(value))
+		found := false
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if len(name) >= 3 && strings.HasPrefix(strings.ToLower(weekday.String()), name[:3]) {
+				days[weekday] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: unknown working day %q", ErrInvalidPlan, value)
+		}
+	}
+
+	return days, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func utc(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func assertWindows(t *testing.T, got, want []PlannerWindow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got windows %v, want %v", got, want)
+	}
+	for i := range got {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			t.Fatalf("got windows %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	weekdays, err := parseWorkingDays(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	everyDay, err := parseWorkingDays([]string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		zone       string
+		from, to   time.Time
+		start, end time.Duration
+		days       map[time.Weekday]bool
+		want       []PlannerWindow
+	}{
+		{
+			// The US springs forward on Sunday 2024-03-10, so 09:00 local moves
+			// from 14:00 UTC on Friday to 13:00 UTC on Monday.
+			name: "across spring forward",
+			zone: "America/New_York",
+			from: utc(time.March, 8, 0, 0), to: utc(time.March, 12, 0, 0),
+			start: 9 * time.Hour, end: 17 * time.Hour, days: weekdays,
+			want: []PlannerWindow{
+				{utc(time.March, 8, 14, 0), utc(time.March, 8, 22, 0)},
+				{utc(time.March, 11, 13, 0), utc(time.March, 11, 21, 0)},
+			},
+		},
+		{
+			name: "across fall back",
+			zone: "Europe/Madrid",
+			from: utc(time.October, 25, 0, 0), to: utc(time.October, 29, 0, 0),
+			start: 9 * time.Hour, end: 17 * time.Hour, days: weekdays,
+			want: []PlannerWindow{
+				{utc(time.October, 25, 7, 0), utc(time.October, 25, 15, 0)},
+				{utc(time.October, 28, 8, 0), utc(time.October, 28, 16, 0)},
+			},
+		},
+		{
+			name: "clipped to the range",
+			zone: "America/Argentina/Buenos_Aires",
+			from: utc(time.July, 15, 15, 30), to: utc(time.July, 15, 18, 0),
+			start: 9 * time.Hour, end: 18 * time.Hour, days: weekdays,
+			want: []PlannerWindow{{utc(time.July, 15, 15, 30), utc(time.July, 15, 18, 0)}},
+		},
+		{
+			name: "overnight shift",
+			zone: "UTC",
+			from: utc(time.July, 15, 0, 0), to: utc(time.July, 17, 0, 0),
+			start: 22 * time.Hour, end: 6 * time.Hour, days: everyDay,
+			want: []PlannerWindow{
+				{utc(time.July, 15, 0, 0), utc(time.July, 15, 6, 0)},
+				{utc(time.July, 15, 22, 0), utc(time.July, 16, 6, 0)},
+				{utc(time.July, 16, 22, 0), utc(time.July, 17, 0, 0)},
+			},
+		},
+		{
+			name: "weekend only",
+			zone: "UTC",
+			from: utc(time.July, 13, 0, 0), to: utc(time.July, 15, 0, 0),
+			start: 9 * time.Hour, end: 17 * time.Hour, days: weekdays,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := workingHours(loadLocation(t, tt.zone), tt.from, tt.to, tt.start, tt.end, tt.days)
+			assertWindows(t, got, tt.want)
+		})
+	}
+}
+
+func TestIntersectWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []PlannerWindow
+		want []PlannerWindow
+	}{
+		{
+			name: "overlap",
+			a:    []PlannerWindow{{utc(time.July, 15, 8, 0), utc(time.July, 15, 16, 0)}},
+			b:    []PlannerWindow{{utc(time.July, 15, 13, 0), utc(time.July, 15, 21, 0)}},
+			want: []PlannerWindow{{utc(time.July, 15, 13, 0), utc(time.July, 15, 16, 0)}},
+		},
+		{
+			name: "nested",
+			a:    []PlannerWindow{{utc(time.July, 15, 8, 0), utc(time.July, 15, 20, 0)}},
+			b:    []PlannerWindow{{utc(time.July, 15, 10, 0), utc(time.July, 15, 12, 0)}, {utc(time.July, 15, 14, 0), utc(time.July, 15, 16, 0)}},
+			want: []PlannerWindow{{utc(time.July, 15, 10, 0), utc(time.July, 15, 12, 0)}, {utc(time.July, 15, 14, 0), utc(time.July, 15, 16, 0)}},
+		},
+		{
+			name: "touching",
+			a:    []PlannerWindow{{utc(time.July, 15, 8, 0), utc(time.July, 15, 12, 0)}},
+			b:    []PlannerWindow{{utc(time.July, 15, 12, 0), utc(time.July, 15, 16, 0)}},
+		},
+		{
+			name: "disjoint",
+			a:    []PlannerWindow{{utc(time.July, 15, 0, 0), utc(time.July, 15, 6, 0)}},
+			b:    []PlannerWindow{{utc(time.July, 16, 0, 0), utc(time.July, 16, 6, 0)}},
+		},
+		{
+			name: "empty",
+			a:    []PlannerWindow{{utc(time.July, 15, 0, 0), utc(time.July, 15, 6, 0)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertWindows(t, intersectWindows(tt.a, tt.b), tt.want)
+		})
+	}
+}
+
+func TestOverlapAcrossDSTGap(t *testing.T) {
+	weekdays, err := parseWorkingDays(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	madrid := loadLocation(t, "Europe/Madrid")
+	newYork := loadLocation(t, "America/New_York")
+
+	// Between the US (March 10) and EU (March 31) transitions the offset
+	// between Madrid and New York is five hours instead of six.
+	tests := []struct {
+		name string
+		day  time.Time
+		want []PlannerWindow
+	}{
+		{"both standard time", utc(time.March, 8, 0, 0), []PlannerWindow{{utc(time.March, 8, 14, 0), utc(time.March, 8, 16, 0)}}},
+		{"only new york in DST", utc(time.March, 11, 0, 0), []PlannerWindow{{utc(time.March, 11, 13, 0), utc(time.March, 11, 16, 0)}}},
+		{"both in DST", utc(time.April, 1, 0, 0), []PlannerWindow{{utc(time.April, 1, 13, 0), utc(time.April, 1, 15, 0)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := tt.day.Add(24 * time.Hour)
+			got := intersectWindows(
+				workingHours(madrid, tt.day, to, 9*time.Hour, 17*time.Hour, weekdays),
+				workingHours(newYork, tt.day, to, 9*time.Hour, 17*time.Hour, weekdays),
+			)
+			assertWindows(t, got, tt.want)
+		})
+	}
+}
+
+func TestParsePlannerSettings(t *testing.T) {
+	if clock, err := parseClock("09:30"); err != nil || clock != 9*time.Hour+30*time.Minute {
+		t.Fatalf("parseClock(09:30) = %v, %v", clock, err)
+	}
+	if _, err := parseClock("9am"); !errors.Is(err, ErrInvalidPlan) {
+		t.Fatalf("expected ErrInvalidPlan, got %v", err)
+	}
+
+	days, err := parseWorkingDays([]string{"Monday", " sat "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || !days[time.Monday] || !days[time.Saturday] {
+		t.Fatalf("unexpected working days %v", days)
+	}
+	if _, err := parseWorkingDays([]string{"someday"}); !errors.Is(err, ErrInvalidPlan) {
+		t.Fatalf("expected ErrInvalidPlan, got %v", err)
+	}
+}
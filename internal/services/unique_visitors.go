@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/hll"
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+)
+
+const (
+	uniqueVisitorsKey      = "stats:distance:unique"
+	countryUniqueKeyPrefix = "stats:distance:unique:country:"
+	uniqueVisitorsSuffix   = ":unique"
+)
+
+// localVisitors keeps HyperLogLog sketches in memory for the keys whose
+// PFADD failed, so unique counts degrade to this process instead of being
+// lost while Redis is unreachable or lacks the HyperLogLog commands.
+var localVisitors = struct {
+	sync.Mutex
+	sketches map[string]*localSketch
+	warned   bool
+}{sketches: make(map[string]*localSketch)}
+
+type localSketch struct {
+	sketch    *hll.Sketch
+	expiresAt time.Time
+}
+
+// uniqueVisitorsPart adds the visitor to the overall, country and time
+// bucket HyperLogLogs, counting it in memory for the keys Redis rejects.
+var uniqueVisitorsPart = statsRecordPart{
+	lua: `
+local visitor = nextArg()
+local function unique(key, ttl)
+	local reply = redis.pcall('PFADD', key, visitor)
+	if type(reply) == 'table' and reply.err then
+		failed[#failed + 1] = key
+	elseif ttl then
+		redis.call('EXPIRE', key, ttl)
+	end
+end
+unique(nextKey())
+unique(nextKey())
+for _ = 1, tonumber(nextArg()) do
+	unique(nextKey(), nextArg())
+end
+`,
+	args: func(lookup statsLookup) ([]string, []interface{}) {
+		keys := []string{uniqueVisitorsKey, countryUniqueVisitorsKey(lookup.country)}
+		buckets := lookup.buckets
+		args := []interface{}{lookup.visitor, len(buckets)}
+		for _, bucket := range buckets {
+			keys = append(keys, distanceBucketKey(bucket.granularity, bucket.start)+uniqueVisitorsSuffix)
+			args = append(args, int64(bucket.ttl/time.Second))
+		}
+		return keys, args
+	},
+	fallback: func(lookup statsLookup, keys []string, err error) {
+		ttls := make(map[string]time.Duration, len(keys))
+		for _, key := range keys {
+			ttls[key] = 0
+		}
+		for _, bucket := range lookup.buckets {
+			key := distanceBucketKey(bucket.granularity, bucket.start) + uniqueVisitorsSuffix
+			if _, ok := ttls[key]; ok {
+				ttls[key] = bucket.ttl
+			}
+		}
+		recordLocalVisitors(lookup.visitor, ttls, err)
+	},
+}
+
+func countryUniqueVisitorsKey(countryName string) string {
+	return countryUniqueKeyPrefix + countryName
+}
+
+// visitorID normalizes the IP so that different spellings of the same
+// address count once.
+func visitorID(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+// recordLocalVisitors adds visitor to the in-memory sketch of each key, for
+// when Redis could not. A zero TTL keeps the sketch for the process lifetime.
+func recordLocalVisitors(visitor string, ttls map[string]time.Duration, cause error) {
+	if len(ttls) == 0 {
+		return
+	}
+
+	localVisitors.Lock()
+	defer localVisitors.Unlock()
+
+	if !localVisitors.warned {
+		fmt.Printf("Error recording unique visitors, counting in memory instead: %v\n", cause)
+		localVisitors.warned = true
+	}
+
+	now := time.Now()
+	for key, local := range localVisitors.sketches {
+		if !local.expiresAt.IsZero() && now.After(local.expiresAt) {
+			delete(localVisitors.sketches, key)
+		}
+	}
+
+	for key, ttl := range ttls {
+		local, ok := localVisitors.sketches[key]
+		if !ok {
+			local = &localSketch{sketch: hll.New()}
+			if ttl > 0 {
+				local.expiresAt = now.Add(ttl)
+			}
+			localVisitors.sketches[key] = local
+		}
+		local.sketch.Add(visitor)
+	}
+}
+
+// countUniqueVisitors estimates the distinct visitors across the union of
+// keys, falling back to the in-memory sketches when Redis cannot answer.
+func countUniqueVisitors(ctx context.Context, cache interfaces.Cache, keys ...string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+
+	count, err := cache.PFCount(ctx, keys...)
+	if err == nil {
+		return int(count)
+	}
+	return countLocalVisitors(keys...)
+}
+
+// countLocalVisitors estimates the distinct visitors across the union of the
+// in-memory sketches of keys.
+func countLocalVisitors(keys ...string) int {
+	localVisitors.Lock()
+	defer localVisitors.Unlock()
+
+	union := hll.New()
+	for _, key := range keys {
+		if local, ok := localVisitors.sketches[key]; ok {
+			union.Merge(local.sketch)
+		}
+	}
+	return int(union.Count())
+}
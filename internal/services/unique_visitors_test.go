@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/hll"
+)
+
+func resetLocalVisitors(t *testing.T) {
+	t.Cleanup(func() {
+		localVisitors.Lock()
+		defer localVisitors.Unlock()
+		localVisitors.sketches = make(map[string]*localSketch)
+		localVisitors.warned = false
+	})
+}
+
+func TestUniqueVisitorsFallback(t *testing.T) {
+	resetLocalVisitors(t)
+
+	server, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	// A key of another type makes PFADD fail for the overall visitors only.
+	if err := server.Set(uniqueVisitorsKey, "not a hyperloglog"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"190.2.1.10", "190.2.1.11", "190.2.1.10"} {
+		if err := recordDistance(ctx, redisCache, ip, 1000, "Argentina"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := countLocalVisitors(uniqueVisitorsKey); got != 2 {
+		t.Fatalf("counted %d visitors in memory, want 2", got)
+	}
+	if got := countLocalVisitors(countryUniqueVisitorsKey("Argentina")); got != 0 {
+		t.Fatalf("expected the country visitors to stay in Redis, got %d in memory", got)
+	}
+	if got := countUniqueVisitors(ctx, redisCache, uniqueVisitorsKey); got != 2 {
+		t.Fatalf("countUniqueVisitors = %d, want 2 from memory", got)
+	}
+	if got := countUniqueVisitors(ctx, redisCache, countryUniqueVisitorsKey("Argentina")); got != 2 {
+		t.Fatalf("countUniqueVisitors = %d, want 2 from Redis", got)
+	}
+
+	// With Redis gone nothing else is recorded, but visitors still are.
+	server.Close()
+	if err := recordDistance(ctx, redisCache, "190.2.1.12", 1000, "Argentina"); err == nil {
+		t.Fatal("expected an error with Redis unreachable")
+	}
+	if got := countUniqueVisitors(ctx, redisCache, uniqueVisitorsKey, countryUniqueVisitorsKey("Argentina")); got != 3 {
+		t.Fatalf("countUniqueVisitors = %d, want 3 from memory", got)
+	}
+}
+
+func TestCountLocalVisitorsUnion(t *testing.T) {
+	resetLocalVisitors(t)
+
+	a, b := hll.New(), hll.New()
+	a.Add("1.1.1.1")
+	a.Add("2.2.2.2")
+	b.Add("2.2.2.2")
+	b.Add("3.3.3.3")
+
+	localVisitors.Lock()
+	localVisitors.sketches["a"] = &localSketch{sketch: a}
+	localVisitors.sketches["b"] = &localSketch{sketch: b}
+	localVisitors.Unlock()
+
+	tests := []struct {
+		keys []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"a"}, 2},
+		{[]string{"a", "b"}, 3},
+		{[]string{"a", "missing"}, 2},
+	}
+	for _, tt := range tests {
+		if got := countLocalVisitors(tt.keys...); got != tt.want {
+			t.Errorf("countLocalVisitors(%v) = %d, want %d", tt.keys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgiraldoz/geo-ip-info/internal/interfaces"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidStatsWindow = errors.New("invalid stats window")
+
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+
+	distanceBucketKeyPrefix = "stats:distance:"
+)
+
+var statsGranularities = []struct {
+	name string
+	size time.Duration
+}{
+	{GranularityMinute, time.Minute},
+	{GranularityHour, time.Hour},
+	{GranularityDay, 24 * time.Hour},
+}
+
+type StatsBucket struct {
+	Start               time.Time
+	TotalDistance       float64
+	TotalRequests       int
+	UniqueVisitors      int
+	FarthestDistance    float64
+	FarthestCountryName string
+	ClosestDistance     float64
+	ClosestCountryName  string
+}
+
+type StatsWindow struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Retention   time.Duration
+	Total       StatsBucket
+	Buckets     []StatsBucket
+}
+
+func (b StatsBucket) AverageDistance() float64 {
+	if b.TotalRequests == 0 {
+		return 0
+	}
+	return b.TotalDistance / float64(b.TotalRequests)
+}
+
+func granularitySize(granularity string) (time.Duration, bool) {
+	for _, g := range statsGranularities {
+		if g.name == granularity {
+			return g.size, true
+		}
+	}
+	return 0, false
+}
+
+func granularityRetention(granularity string) time.Duration {
+	return viper.GetDuration("stats.retention." + granularity)
+}
+
+func distanceBucketKey(granularity string, start time.Time) string {
+	return distanceBucketKeyPrefix + granularity + ":" + strconv.FormatInt(start.Unix(), 10)
+}
+
+// statsBucketsPart keeps the totals of each time bucket.
+var statsBucketsPart = statsRecordPart{
+	lua: `
+for _ = 1, tonumber(nextArg()) do
+	local key = nextKey()
+	totals(key)
+	redis.call('EXPIRE', key, nextArg())
+end
+`,
+	args: func(lookup statsLookup) ([]string, []interface{}) {
+		buckets := lookup.buckets
+		keys := make([]string, 0, len(buckets))
+		args := []interface{}{len(buckets)}
+		for _, bucket := range buckets {
+			keys = append(keys, distanceBucketKey(bucket.granularity, bucket.start))
+			args = append(args, int64(bucket.ttl/time.Second))
+		}
+		return keys, args
+	},
+}
+
+// activeStatsBucket is the time bucket of a granularity that a lookup falls
+// in, kept until it falls out of the granularity's retention period.
+type activeStatsBucket struct {
+	granularity string
+	start       time.Time
+	ttl         time.Duration
+}
+
+func activeStatsBuckets(at time.Time) []activeStatsBucket {
+	var buckets []activeStatsBucket
+	for _, g := range statsGranularities {
+		retention := granularityRetention(g.name)
+		if retention <= 0 {
+			continue
+		}
+		buckets = append(buckets, activeStatsBucket{granularity: g.name, start: at.UTC().Truncate(g.size), ttl: retention + g.size})
+	}
+	return buckets
+}
+
+// readStatsWindowScript returns, for each bucket in KEYS, its totals hash
+// and the estimate of its unique visitors, then the estimate for the union
+// of the non-empty buckets, so a window is read in one round trip. Estimates
+// are -1 when PFCOUNT fails. ARGV[1] is the unique visitors key suffix.
+const readStatsWindowScript = `
+local function count(...)
+	local reply = redis.pcall('PFCOUNT', ...)
+	if type(reply) == 'table' and reply.err then
+		return -1
+	end
+	return reply
+end
+local result, uniqueKeys = {}, {}
+for i = 1, #KEYS do
+	local fields = redis.call('HGETALL', KEYS[i])
+	local unique = 0
+	if #fields > 0 then
+		local key = KEYS[i] .. ARGV[1]
+		uniqueKeys[#uniqueKeys + 1] = key
+		unique = count(key)
+	end
+	result[i] = {fields, unique}
+end
+local total = 0
+if #uniqueKeys > 0 then
+	total = count(unpack(uniqueKeys))
+end
+result[#KEYS + 1] = total
+return result
+`
+
+// GetStatsWindow aggregates the buckets between from and to. An empty
+// granularity picks the finest one that fits within stats.max_buckets and
+// whose retention still covers from.
+func GetStatsWindow(redisCache interfaces.Cache, from, to time.Time, granularity string) (*StatsWindow, error) {
+	granularity, starts, err := statsWindowStarts(from, to, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("context.timeout"))
+	defer cancel()
+
+	window := &StatsWindow{
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Retention:   granularityRetention(granularity),
+		Total:       StatsBucket{Start: starts[0]},
+	}
+
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = distanceBucketKey(granularity, start)
+	}
+
+	result, err := redisCache.Eval(ctx, readStatsWindowScript, keys, uniqueVisitorsSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s buckets: %w", granularity, err)
+	}
+	rows, ok := result.([]interface{})
+	if !ok || len(rows) != len(starts)+1 {
+		return nil, fmt.Errorf("unexpected %s buckets reply %T", granularity, result)
+	}
+
+	var uniqueKeys []string
+	for i, start := range starts {
+		row, _ := rows[i].([]interface{})
+		if len(row) != 2 {
+			return nil, fmt.Errorf("unexpected reply for %s bucket %s", granularity, start.Format(time.RFC3339))
+		}
+
+		fields := hashFromReply(row[0])
+		bucket := StatsBucket{
+			Start:               start,
+			TotalDistance:       parseStatFloat(fields["total_distance"]),
+			TotalRequests:       int(parseStatFloat(fields["total_requests"])),
+			FarthestDistance:    parseStatFloat(fields["farthest_distance"]),
+			FarthestCountryName: fields["farthest_country"],
+			ClosestDistance:     parseStatFloat(fields["closest_distance"]),
+			ClosestCountryName:  fields["closest_country"],
+		}
+		if len(fields) > 0 {
+			uniqueKey := keys[i] + uniqueVisitorsSuffix
+			uniqueKeys = append(uniqueKeys, uniqueKey)
+			bucket.UniqueVisitors = uniqueEstimate(row[1], uniqueKey)
+		}
+		window.Buckets = append(window.Buckets, bucket)
+		mergeStatsBucket(&window.Total, bucket)
+	}
+
+	// Unique visitors do not add up across buckets, so the total is the
+	// cardinality of the union of the bucket HyperLogLogs.
+	window.Total.UniqueVisitors = uniqueEstimate(rows[len(starts)], uniqueKeys...)
+
+	return window, nil
+}
+
+// uniqueEstimate returns a PFCOUNT reply, or the in-memory estimate for keys
+// when the script reported that PFCOUNT failed.
+func uniqueEstimate(reply interface{}, keys ...string) int {
+	if count, _ := reply.(int64); count >= 0 {
+		return int(count)
+	}
+	return countLocalVisitors(keys...)
+}
+
+// hashFromReply converts an HGETALL reply returned by a script into a map.
+func hashFromReply(reply interface{}) map[string]string {
+	fields, _ := reply.([]interface{})
+	hash := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		field, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		hash[field] = value
+	}
+	return hash
+}
+
+// statsWindowStarts validates the window and returns its granularity and the
+// start of every bucket it covers.
+func statsWindowStarts(from, to time.Time, granularity string) (string, []time.Time, error) {
+	if !from.Before(to) {
+		return "", nil, fmt.Errorf("%w: from must be before to", ErrInvalidStatsWindow)
+	}
+
+	maxBuckets := viper.GetInt("stats.max_buckets")
+	if granularity == "" {
+		granularity = chooseGranularity(from, to, maxBuckets)
+	}
+
+	size, ok := granularitySize(granularity)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown granularity %q (use minute, hour or day)", ErrInvalidStatsWindow, granularity)
+	}
+
+	// Buckets older than the retention have expired, so reading them would
+	// silently report zeros for that part of the window.
+	retention := granularityRetention(granularity)
+	if retention <= 0 {
+		return "", nil, fmt.Errorf("%w: %s buckets are disabled", ErrInvalidStatsWindow, granularity)
+	}
+	if age := time.Since(from); age > retention {
+		return "", nil, fmt.Errorf("%w: %s buckets are kept for %s but from is %s ago (use a coarser granularity)",
+			ErrInvalidStatsWindow, granularity, retention, age.Round(time.Second))
+	}
+
+	first := from.UTC().Truncate(size)
+	count := int(to.Sub(first)/size) + 1
+	if maxBuckets > 0 && count > maxBuckets {
+		return "", nil, fmt.Errorf("%w: %d %s buckets requested, at most %d allowed", ErrInvalidStatsWindow, count, granularity, maxBuckets)
+	}
+
+	starts := make([]time.Time, 0, count)
+	for start := first; start.Before(to); start = start.Add(size) {
+		starts = append(starts, start)
+	}
+	return granularity, starts, nil
+}
+
+func chooseGranularity(from, to time.Time, maxBuckets int) string {
+	age := time.Since(from)
+	for _, g := range statsGranularities {
+		retention := granularityRetention(g.name)
+		fits := maxBuckets <= 0 || int(to.Sub(from)/g.size)+1 <= maxBuckets
+		if fits && retention > 0 && age <= retention {
+			return g.name
+		}
+	}
+	return GranularityDay
+}
+
+func mergeStatsBucket(total *StatsBucket, bucket StatsBucket) {
+	if bucket.TotalRequests == 0 {
+		return
+	}
+
+	if total.TotalRequests == 0 || bucket.FarthestDistance > total.FarthestDistance {
+		total.FarthestDistance = bucket.FarthestDistance
+		total.FarthestCountryName = bucket.FarthestCountryName
+	}
+	if total.TotalRequests == 0 || bucket.ClosestDistance < total.ClosestDistance {
+		total.ClosestDistance = bucket.ClosestDistance
+		total.ClosestCountryName = bucket.ClosestCountryName
+	}
+	total.TotalDistance += bucket.TotalDistance
+	total.TotalRequests += bucket.TotalRequests
+}
+
+// ParseStatsTime accepts RFC3339, a YYYY-MM-DD date (UTC) or a duration such
+// as "24h" or "7d" meaning that long before now.
+func ParseStatsTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(ratesDateLayout, value); err == nil {
+		return t, nil
+	}
+	if ago, err := parseStatsDuration(strings.TrimPrefix(value, "-")); err == nil && ago > 0 {
+		return now.Add(-ago), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q is not RFC3339, YYYY-MM-DD or a duration", ErrInvalidStatsWindow, value)
+}
+
+// parseStatsDuration extends time.ParseDuration with a leading number of
+// days, as in "7d" or "1d12h".
+func parseStatsDuration(value string) (time.Duration, error) {
+	days, rest, found := strings.Cut(value, "d")
+	if !found {
+		return time.ParseDuration(value)
+	}
+
+	count, err := strconv.Atoi(days)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid number of days in %q", value)
+	}
+
+	duration := time.Duration(count) * 24 * time.Hour
+	if rest != "" {
+		extra, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, err
+		}
+		duration += extra
+	}
+	return duration, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseStatsTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2024-03-01T08:30:00Z", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{value: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "24h", want: now.Add(-24 * time.Hour)},
+		{value: "-90m", want: now.Add(-90 * time.Minute)},
+		{value: " 7d ", want: now.Add(-7 * 24 * time.Hour)},
+		{value: "1d12h", want: now.Add(-36 * time.Hour)},
+		{value: "0d", wantErr: true},
+		{value: "xd", wantErr: true},
+		{value: "7d3", wantErr: true},
+		{value: "0s", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseStatsTime(tt.value, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStatsWindow) {
+					t.Fatalf("expected ErrInvalidStatsWindow, got %v (%v)", err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsWindowStarts(t *testing.T) {
+	viper.Set("stats.retention.minute", 3*time.Hour)
+	viper.Set("stats.retention.hour", 8*24*time.Hour)
+	viper.Set("stats.retention.day", 400*24*time.Hour)
+	viper.Set("stats.max_buckets", 500)
+	t.Cleanup(viper.Reset)
+
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		ago             time.Duration
+		granularity     string
+		wantGranularity string
+		wantErr         bool
+	}{
+		{name: "finest that fits", ago: 90 * time.Minute, wantGranularity: GranularityMinute},
+		{name: "minutes exceed max buckets", ago: 2*24*time.Hour + time.Hour, wantGranularity: GranularityHour},
+		{name: "beyond hour retention", ago: 30 * 24 * time.Hour, wantGranularity: GranularityDay},
+		{name: "explicit within retention", ago: 24 * time.Hour, granularity: GranularityHour, wantGranularity: GranularityHour},
+		{name: "explicit beyond retention", ago: 30 * 24 * time.Hour, granularity: GranularityHour, wantErr: true},
+		{name: "too many buckets", ago: 2 * time.Hour * 24, granularity: GranularityMinute, wantErr: true},
+		{name: "unknown granularity", ago: time.Hour, granularity: "week", wantErr: true},
+		{name: "beyond every retention", ago: 500 * 24 * time.Hour, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granularity, starts, err := statsWindowStarts(now.Add(-tt.ago), now, tt.granularity)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStatsWindow) {
+					t.Fatalf("expected ErrInvalidStatsWindow, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if granularity != tt.wantGranularity {
+				t.Fatalf("granularity = %s, want %s", granularity, tt.wantGranularity)
+			}
+			if len(starts) == 0 || starts[0].After(now.Add(-tt.ago)) || !starts[len(starts)-1].Before(now) {
+				t.Fatalf("buckets %v do not cover the window", starts)
+			}
+		})
+	}
+
+	if _, _, err := statsWindowStarts(now, now.Add(-time.Hour), ""); !errors.Is(err, ErrInvalidStatsWindow) {
+		t.Fatalf("expected a reversed window to be rejected, got %v", err)
+	}
+}
+
+func TestGetStatsWindow(t *testing.T) {
+	viper.Set("stats.retention.minute", 3*time.Hour)
+	viper.Set("stats.retention.hour", 0)
+	viper.Set("stats.retention.day", 0)
+	viper.Set("stats.max_buckets", 500)
+	viper.Set("context.timeout", time.Second)
+	t.Cleanup(viper.Reset)
+
+	_, redisCache := newTestRedis(t)
+	ctx := context.Background()
+
+	lookups := []struct {
+		ip       string
+		distance float64
+		country  string
+	}{
+		{"190.2.1.10", 1000, "Argentina"},
+		{"190.2.1.10", 1000, "Argentina"},
+		{"8.8.8.8", 9000, "United States"},
+	}
+	for _, lookup := range lookups {
+		if err := recordDistance(ctx, redisCache, lookup.ip, lookup.distance, lookup.country); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	window, err := GetStatsWindow(redisCache, now.Add(-time.Hour), now.Add(time.Minute), GranularityMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window.Buckets) < 60 {
+		t.Fatalf("got %d buckets, want one per minute", len(window.Buckets))
+	}
+
+	var filled []StatsBucket
+	for _, bucket := range window.Buckets {
+		if bucket.TotalRequests > 0 {
+			filled = append(filled, bucket)
+		} else if bucket.UniqueVisitors != 0 {
+			t.Fatalf("empty bucket %v has %d unique visitors", bucket.Start, bucket.UniqueVisitors)
+		}
+	}
+	// The lookups may straddle a minute boundary.
+	if len(filled) == 0 || len(filled) > 2 {
+		t.Fatalf("expected the lookups in one or two buckets, got %d", len(filled))
+	}
+
+	total := window.Total
+	if total.TotalRequests != 3 || total.TotalDistance != 11000 || total.UniqueVisitors != 2 {
+		t.Fatalf("unexpected window total %+v", total)
+	}
+	if total.FarthestCountryName != "United States" || total.ClosestCountryName != "Argentina" {
+		t.Fatalf("got farthest %s and closest %s", total.FarthestCountryName, total.ClosestCountryName)
+	}
+}
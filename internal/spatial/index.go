@@ -0,0 +1,138 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+type Point struct {
+	ID        string
+	Name      string
+	Kind      string
+	Latitude  float64
+	Longitude float64
+}
+
+type Neighbor struct {
+	Point Point
+	// Chord is the straight-line distance on the unit sphere; it orders
+	// neighbors exactly like the great-circle distance.
+	Chord float64
+}
+
+// Index is a static 3-d tree over points projected onto the unit sphere.
+type Index struct {
+	root *node
+	size int
+}
+
+type node struct {
+	point       Point
+	vector      [3]float64
+	axis        int
+	left, right *node
+}
+
+func NewIndex(points []Point) *Index {
+	entries := make([]*node, len(points))
+	for i, point := range points {
+		entries[i] = &node{point: point, vector: unitVector(point.Latitude, point.Longitude)}
+	}
+	return &Index{root: build(entries, 0), size: len(points)}
+}
+
+func (idx *Index) Len() int {
+	return idx.size
+}
+
+// Nearest returns up to n points closest to the given coordinates, nearest
+// first.
+func (idx *Index) Nearest(latitude, longitude float64, n int) []Neighbor {
+	if n <= 0 || idx.root == nil {
+		return nil
+	}
+
+	search := &nearestSearch{target: unitVector(latitude, longitude), limit: n}
+	search.visit(idx.root)
+
+	sort.Slice(search.found, func(i, j int) bool { return search.found[i].Chord < search.found[j].Chord })
+	return search.found
+}
+
+func build(entries []*node, depth int) *node {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(entries, func(i, j int) bool { return entries[i].vector[axis] < entries[j].vector[axis] })
+
+	median := len(entries) / 2
+	root := entries[median]
+	root.axis = axis
+	root.left = build(entries[:median], depth+1)
+	root.right = build(entries[median+1:], depth+1)
+	return root
+}
+
+type nearestSearch struct {
+	target [3]float64
+	limit  int
+	found  []Neighbor
+}
+
+func (s *nearestSearch) visit(n *node) {
+	if n == nil {
+		return
+	}
+
+	s.offer(n)
+
+	diff := s.target[n.axis] - n.vector[n.axis]
+	near, far := n.left, n.right
+	if diff > 0 {
+		near, far = n.right, n.left
+	}
+
+	s.visit(near)
+	if len(s.found) < s.limit || math.Abs(diff) < s.worst() {
+		s.visit(far)
+	}
+}
+
+func (s *nearestSearch) offer(n *node) {
+	chord := distance(s.target, n.vector)
+	if len(s.found) < s.limit {
+		s.found = append(s.found, Neighbor{Point: n.point, Chord: chord})
+		return
+	}
+
+	worst := 0
+	for i := range s.found {
+		if s.found[i].Chord > s.found[worst].Chord {
+			worst = i
+		}
+	}
+	if chord < s.found[worst].Chord {
+		s.found[worst] = Neighbor{Point: n.point, Chord: chord}
+	}
+}
+
+func (s *nearestSearch) worst() float64 {
+	worst := 0.0
+	for _, neighbor := range s.found {
+		worst = math.Max(worst, neighbor.Chord)
+	}
+	return worst
+}
+
+func unitVector(latitude, longitude float64) [3]float64 {
+	phi := latitude * math.Pi / 180
+	lambda := longitude * math.Pi / 180
+	return [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+}
+
+func distance(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
@@ -0,0 +1,86 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestNearestMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	points := make([]Point, 500)
+	for i := range points {
+		points[i] = Point{ID: string(rune('a' + i%26)), Latitude: random.Float64()*180 - 90, Longitude: random.Float64()*360 - 180}
+	}
+	index := NewIndex(points)
+	if index.Len() != len(points) {
+		t.Fatalf("Len() = %d, want %d", index.Len(), len(points))
+	}
+
+	for query := 0; query < 50; query++ {
+		latitude, longitude := random.Float64()*180-90, random.Float64()*360-180
+		target := unitVector(latitude, longitude)
+
+		expected := make([]float64, len(points))
+		for i, point := range points {
+			expected[i] = distance(target, unitVector(point.Latitude, point.Longitude))
+		}
+		sort.Float64s(expected)
+
+		for _, n := range []int{1, 5, 20} {
+			neighbors := index.Nearest(latitude, longitude, n)
+			if len(neighbors) != n {
+				t.Fatalf("got %d neighbors, want %d", len(neighbors), n)
+			}
+			for i, neighbor := range neighbors {
+				if neighbor.Chord != expected[i] {
+					t.Fatalf("query (%f, %f) n=%d: neighbor %d has chord %f, want %f", latitude, longitude, n, i, neighbor.Chord, expected[i])
+				}
+			}
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	index := NewIndex([]Point{
+		{ID: "AR", Name: "Buenos Aires", Latitude: -34.60, Longitude: -58.38},
+		{ID: "UY", Name: "Montevideo", Latitude: -34.90, Longitude: -56.16},
+		{ID: "ES", Name: "Madrid", Latitude: 40.42, Longitude: -3.70},
+		{ID: "FJ", Name: "Suva", Latitude: -18.14, Longitude: 178.44},
+		{ID: "WS", Name: "Apia", Latitude: -13.83, Longitude: -171.76},
+	})
+
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		n         int
+		want      []string
+	}{
+		{"closest first", -34.9, -57.9, 2, []string{"AR", "UY"}},
+		{"across the antimeridian", -16, 179.9, 2, []string{"FJ", "WS"}},
+		{"n larger than the index", 40, -4, 10, []string{"ES", "UY", "AR", "WS", "FJ"}},
+		{"zero", 0, 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			neighbors := index.Nearest(tt.latitude, tt.longitude, tt.n)
+			if len(neighbors) != len(tt.want) {
+				t.Fatalf("got %d neighbors, want %v", len(neighbors), tt.want)
+			}
+			for i, neighbor := range neighbors {
+				if neighbor.Point.ID != tt.want[i] {
+					t.Fatalf("neighbor %d = %s, want %v", i, neighbor.Point.ID, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNearestEmptyIndex(t *testing.T) {
+	if neighbors := NewIndex(nil).Nearest(0, 0, 3); neighbors != nil {
+		t.Fatalf("expected no neighbors, got %v", neighbors)
+	}
+}
@@ -0,0 +1,65 @@
+package timezone
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+type Info struct {
+	Name          string
+	LegacyKey     string
+	UTCOffset     string
+	OffsetSeconds int
+	Abbreviation  string
+	IsDST         bool
+	CurrentTime   time.Time
+
+	Weekday               string
+	IsBusinessHours       bool
+	HomeTimeZone          string
+	OffsetFromHomeSeconds int
+}
+
+func CountryZones(countryCode string) []string {
+	return countryZones[strings.ToUpper(countryCode)]
+}
+
+func Describe(name string, now time.Time) (Info, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return Info{}, fmt.Errorf("unknown time zone %s: %w", name, err)
+	}
+
+	local := now.In(location)
+	abbreviation, offset := local.Zone()
+
+	return Info{
+		Name:          name,
+		LegacyKey:     LegacyKey(offset),
+		UTCOffset:     FormatOffset(offset),
+		OffsetSeconds: offset,
+		Abbreviation:  abbreviation,
+		IsDST:         local.IsDST(),
+		CurrentTime:   local,
+		Weekday:       local.Weekday().String(),
+	}, nil
+}
+
+// LegacyKey renders an offset in the "UTC±hh:mm" form used by restcountries.
+func LegacyKey(offsetSeconds int) string {
+	if offsetSeconds == 0 {
+		return "UTC"
+	}
+	return "UTC" + FormatOffset(offsetSeconds)
+}
+
+func FormatOffset(offsetSeconds int) string {
+	sign := '+'
+	if offsetSeconds < 0 {
+		sign = '-'
+		offsetSeconds = -offsetSeconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offsetSeconds/3600, offsetSeconds%3600/60)
+}
@@ -0,0 +1,79 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDescribeAcrossDST(t *testing.T) {
+	tests := []struct {
+		name         string
+		zone         string
+		at           time.Time
+		offset       string
+		legacyKey    string
+		abbreviation string
+		isDST        bool
+	}{
+		{"madrid before spring forward", "Europe/Madrid", time.Date(2024, 3, 31, 0, 59, 59, 0, time.UTC), "+01:00", "UTC+01:00", "CET", false},
+		{"madrid after spring forward", "Europe/Madrid", time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), "+02:00", "UTC+02:00", "CEST", true},
+		{"madrid before fall back", "Europe/Madrid", time.Date(2024, 10, 27, 0, 59, 59, 0, time.UTC), "+02:00", "UTC+02:00", "CEST", true},
+		{"madrid after fall back", "Europe/Madrid", time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC), "+01:00", "UTC+01:00", "CET", false},
+		{"new york before spring forward", "America/New_York", time.Date(2024, 3, 10, 6, 59, 59, 0, time.UTC), "-05:00", "UTC-05:00", "EST", false},
+		{"new york after spring forward", "America/New_York", time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), "-04:00", "UTC-04:00", "EDT", true},
+		{"sydney southern summer", "Australia/Sydney", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), "+11:00", "UTC+11:00", "AEDT", true},
+		{"sydney after fall back", "Australia/Sydney", time.Date(2024, 4, 7, 0, 0, 0, 0, time.UTC), "+10:00", "UTC+10:00", "AEST", false},
+		{"lord howe half hour shift", "Australia/Lord_Howe", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), "+11:00", "UTC+11:00", "+11", true},
+		{"lord howe winter", "Australia/Lord_Howe", time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), "+10:30", "UTC+10:30", "+1030", false},
+		{"buenos aires without dst", "America/Argentina/Buenos_Aires", time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), "-03:00", "UTC-03:00", "-03", false},
+		{"utc", "UTC", time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), "+00:00", "UTC", "UTC", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Describe(tt.zone, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.UTCOffset != tt.offset || info.LegacyKey != tt.legacyKey || info.Abbreviation != tt.abbreviation || info.IsDST != tt.isDST {
+				t.Fatalf("got offset %s, legacy key %s, abbreviation %s, DST %v", info.UTCOffset, info.LegacyKey, info.Abbreviation, info.IsDST)
+			}
+			if !info.CurrentTime.Equal(tt.at) {
+				t.Fatalf("current time %v is not the same instant as %v", info.CurrentTime, tt.at)
+			}
+		})
+	}
+}
+
+func TestDescribeUnknownZone(t *testing.T) {
+	if _, err := Describe("Mars/Olympus_Mons", time.Now()); err == nil {
+		t.Fatal("expected an unknown zone to be rejected")
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{0, "+00:00"},
+		{-3 * 3600, "-03:00"},
+		{5*3600 + 45*60, "+05:45"},
+		{-(9*3600 + 30*60), "-09:30"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatOffset(tt.seconds); got != tt.want {
+			t.Errorf("FormatOffset(%d) = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestCountryZones(t *testing.T) {
+	if zones := CountryZones("es"); len(zones) == 0 || zones[0] != "Europe/Madrid" {
+		t.Fatalf("CountryZones(es) = %v", zones)
+	}
+	if zones := CountryZones("XX"); zones != nil {
+		t.Fatalf("expected no zones for an unknown country, got %v", zones)
+	}
+}
@@ -29,9 +29,15 @@ func main() {
 		log.Fatalf("Error prefetching data: %v", err)
 	}
 
+	migrateStats(redisCache)
+
 	if err := cli.Execute(redisCache, httpClient); err != nil {
 		log.Fatalf("Error executing CLI: %v", err)
 	}
+
+	if !services.WaitRateAlerts(viper.GetDuration("rates.alerts.flush_timeout")) {
+		fmt.Println("Exiting before all rate alerts were delivered")
+	}
 }
 
 func createContext() (context.Context, context.CancelFunc) {
@@ -39,6 +45,18 @@ func createContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), contextTimeout)
 }
 
+// migrateStats runs with its own context, after the prefetch has used up part
+// of its own, and only logs failures: the legacy stats stay in place and the
+// migration is retried on the next start.
+func migrateStats(redisCache *cache.RedisCache) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	if err := services.MigrateDistanceStats(ctx, redisCache); err != nil {
+		fmt.Printf("Error migrating stats: %v\n", err)
+	}
+}
+
 func createRedisCache() *cache.RedisCache {
 	redisHost := viper.GetString("redis.host")
 	redisPort := viper.GetInt("redis.port")